@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/schema"
+	"github.com/hyperledger/fabric/core/chaincode/lib/cid"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"github.com/pkg/errors"
+)
+
+// checkACL fails unless the ACL of config allows function to the
+// transaction creator. It runs before the function, which may check more,
+// e.g. the admin functions still require an admin MSP. setACL is always
+// allowed to the admins, so that no rule can lock them out of the ACL.
+func checkACL(stub shim.ChaincodeStubInterface, config *ccConfig, function string) error {
+	rule, ok := config.ACL.Rule(function)
+	if !ok {
+		return nil
+	}
+	if function == "setACL" && checkCreatorMSP(stub, "an admin", config.Admins) == nil {
+		return nil
+	}
+	id, err := cid.New(stub)
+	if err != nil {
+		return errors.WithMessage(err, "get identity of the creator failed.")
+	}
+	mspID, err := id.GetMSPID()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	cert, err := id.GetX509Certificate()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	role, _, err := id.GetAttributeValue(schema.RoleAttribute)
+	if err != nil {
+		return errors.WithMessage(err, "get attributes of the creator failed.")
+	}
+	if !rule.Allows(mspID, cert.Subject.OrganizationalUnit, role) {
+		return errors.Errorf("%s (OUs %v, role %q) is not allowed to call %s", mspID, cert.Subject.OrganizationalUnit, role, function)
+	}
+	return nil
+}
+
+// setACL replaces the access control list of the functions, admins only.
+// arg0 is the schema.ACL, e.g.
+// {"transfer":{"msps":["Org1MSP","Org2MSP"],"ous":["client"]},"*":{"msps":["Org1MSP"]}}
+// An empty ACL {} opens every function to the channel members again.
+func (t *Paymentcc) setACL(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1 (acl)")
+	}
+	if err := t.checkAdmin(stub); err != nil {
+		return shim.Error(fmt.Sprintf("setACL denied, err %+v", err))
+	}
+
+	acl, err := schema.DecodeACL([]byte(args[0]))
+	if err != nil {
+		return shim.Error(fmt.Sprintf("invalid acl, err %+v", err))
+	}
+	config, err := t.getConfig(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("get chaincode configuration failed, err %+v", err))
+	}
+	config.ACL = acl
+	if len(acl) == 0 {
+		config.ACL = nil
+	}
+	if err := t.putConfig(stub, config); err != nil {
+		return shim.Error(err.Error())
+	}
+	return shim.Success(nil)
+}
+
+// acl returns the access control list of the functions, {} when every
+// function is open.
+func (t *Paymentcc) acl(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 0 {
+		return shim.Error("Incorrect number of arguments. Expecting 0")
+	}
+	config, err := t.getConfig(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("get chaincode configuration failed, err %+v", err))
+	}
+	acl := config.ACL
+	if acl == nil {
+		acl = schema.ACL{}
+	}
+	value, err := acl.ToBytes()
+	if err != nil {
+		return shim.Error(errors.WithStack(err).Error())
+	}
+	return shim.Success(value)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/schema"
+)
+
+func TestACL(t *testing.T) {
+	client := identity{msp: "Org2MSP", name: "User1@org2", ous: []string{"client"}, attrs: map[string]string{schema.RoleAttribute: "client"}}
+	peer := identity{msp: "Org2MSP", name: "peer0.org2", ous: []string{"peer"}, attrs: map[string]string{schema.RoleAttribute: "peer"}}
+	s := newTestStub(t)
+	s.create("1", 1000)
+	s.create("2", 1000)
+
+	s.mustFail("setACL", `{"transfer":{"msps":[]}}`)
+	s.mustInvoke("setACL", `{"transfer":{"msps":["Org2MSP"],"ous":["client"],"roles":["client"]},"*":{"msps":["Org2MSP"]}}`)
+
+	s.as(client)
+	s.mustInvoke("transfer", s.transfer("1", "2", 10))
+	s.mustInvoke("query", "1")
+	s.as(peer)
+	s.mustFail("transfer", s.transfer("1", "2", 10))
+	s.mustInvoke("query", "1")
+	// the "*" rule denies every other function to Org1MSP, setACL aside
+	s.as(admin)
+	s.mustFail("query", "1")
+	s.mustFail("transfer", s.transfer("1", "2", 10))
+	s.mustFail("acl")
+	s.mustInvoke("setACL", `{}`)
+	s.mustInvoke("transfer", s.transfer("1", "2", 10))
+	s.expectBalances(map[string]int64{"1": 980, "2": 1020})
+	if got := string(s.mustInvoke("acl")); got != "{}" {
+		t.Fatalf("acl() = %s, want {}", got)
+	}
+
+	// setACL stays admins only
+	s.as(client)
+	s.mustFail("setACL", `{}`)
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/schema"
+	"github.com/hyperledger/fabric/core/chaincode/lib/cid"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"github.com/pkg/errors"
+)
+
+// aliasType is the composite key object type of the alias registry.
+const aliasType = "alias"
+
+// getAlias returns nil if name is not registered.
+func (t *Paymentcc) getAlias(stub shim.ChaincodeStubInterface, name string) (*schema.Alias, error) {
+	key, err := stub.CreateCompositeKey(aliasType, []string{name})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	value, err := stub.GetState(key)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if value == nil {
+		return nil, nil
+	}
+	var alias schema.Alias
+	if err := alias.FromBytes(value); err != nil {
+		return nil, err
+	}
+	return &alias, nil
+}
+
+func (t *Paymentcc) putAlias(stub shim.ChaincodeStubInterface, alias *schema.Alias) error {
+	key, err := stub.CreateCompositeKey(aliasType, []string{alias.Name})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	value, err := alias.ToBytes()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := stub.PutState(key, value); err != nil {
+		return errors.WithMessage(err, fmt.Sprintf("put alias %s failed.", alias.Name))
+	}
+	return nil
+}
+
+// resolveAccount returns the account key of name, an alias or a key.
+func (t *Paymentcc) resolveAccount(stub shim.ChaincodeStubInterface, name string) (string, error) {
+	if !schema.IsAlias(name) {
+		return name, nil
+	}
+	alias, err := t.getAlias(stub, name)
+	if err != nil {
+		return "", err
+	}
+	if alias == nil {
+		return "", errors.Errorf("alias %s is not registered", name)
+	}
+	return alias.Account, nil
+}
+
+// registerAlias maps the unused alias in arg0 to the account key in arg1 and
+// makes the creator its owner. The account must belong to the creator's
+// organization, an account created before the org was recorded can only be
+// named by admins.
+func (t *Paymentcc) registerAlias(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 2 (alias, account)")
+	}
+	name, account := args[0], args[1]
+	if err := schema.ValidateAlias(name); err != nil {
+		return shim.Error(err.Error())
+	}
+	existing, err := t.getAlias(stub, name)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if existing != nil {
+		return shim.Error(fmt.Sprintf("alias %s is already registered", name))
+	}
+
+	info, err := t.getAccountInfo(stub, account)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if info.Org == "" {
+		err = t.checkAdmin(stub)
+	} else {
+		err = checkCreatorMSP(stub, "the account's", []string{info.Org})
+	}
+	if err != nil {
+		return shim.Error(fmt.Sprintf("registerAlias denied, err %+v", err))
+	}
+	owner, err := cid.GetID(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("get ID of the creator failed, err %+v", err))
+	}
+
+	alias := &schema.Alias{Name: name, Account: account, Owner: owner}
+	if err := t.putAlias(stub, alias); err != nil {
+		return shim.Error(err.Error())
+	}
+	logger.Infof("alias %s registered for account %s", name, account)
+	return shim.Success(nil)
+}
+
+// transferAlias gives the alias in arg0 to the identity in arg1, as returned
+// by whoami. Only the owner of the alias can transfer it.
+func (t *Paymentcc) transferAlias(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 2 (alias, owner)")
+	}
+	if args[1] == "" {
+		return shim.Error("missing new owner")
+	}
+	alias, err := t.getAlias(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if alias == nil {
+		return shim.Error(fmt.Sprintf("alias %s is not registered", args[0]))
+	}
+	id, err := cid.GetID(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("get ID of the creator failed, err %+v", err))
+	}
+	if id != alias.Owner {
+		return shim.Error(fmt.Sprintf("transferAlias denied, the creator does not own alias %s", alias.Name))
+	}
+
+	alias.Owner = args[1]
+	if err := t.putAlias(stub, alias); err != nil {
+		return shim.Error(err.Error())
+	}
+	logger.Infof("alias %s transferred", alias.Name)
+	return shim.Success(nil)
+}
+
+// alias returns the schema.Alias registered as arg0.
+func (t *Paymentcc) alias(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1 (alias)")
+	}
+	alias, err := t.getAlias(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if alias == nil {
+		return shim.Error(fmt.Sprintf("alias %s is not registered", args[0]))
+	}
+	value, err := alias.ToBytes()
+	if err != nil {
+		return shim.Error(errors.WithStack(err).Error())
+	}
+	return shim.Success(value)
+}
+
+// whoami returns the schema.Identity of the creator, the owner of the
+// aliases it registers.
+func (t *Paymentcc) whoami(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 0 {
+		return shim.Error("Incorrect number of arguments. Expecting 0")
+	}
+	id, err := cid.GetID(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("get ID of the creator failed, err %+v", err))
+	}
+	mspID, err := cid.GetMSPID(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("get MSP ID of the creator failed, err %+v", err))
+	}
+	value, err := json.Marshal(&schema.Identity{ID: id, MSPID: mspID})
+	if err != nil {
+		return shim.Error(errors.WithStack(err).Error())
+	}
+	return shim.Success(value)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/schema"
+)
+
+func (s *testStub) alias(name string) *schema.Alias {
+	s.t.Helper()
+	var a schema.Alias
+	if err := a.FromBytes(s.mustInvoke("alias", name)); err != nil {
+		s.t.Fatal(err)
+	}
+	return &a
+}
+
+func (s *testStub) whoami() string {
+	s.t.Helper()
+	var id schema.Identity
+	if err := json.Unmarshal(s.mustInvoke("whoami"), &id); err != nil {
+		s.t.Fatal(err)
+	}
+	return id.ID
+}
+
+func TestAliases(t *testing.T) {
+	alice := identity{msp: "Org1MSP", name: "alice@org1"}
+	bob := identity{msp: "Org1MSP", name: "bob@org1"}
+	org2 := identity{msp: "Org2MSP", name: "User1@org2"}
+	s := newTestStub(t)
+	s.create("1", 100)
+	s.create("2", 100)
+
+	s.mustFail("create", s.payload(&schema.Payload{To: "carol@bank1", Amount: 1}))
+	s.mustFail("registerAlias", "Alice@Bank1", "1")
+	// an account can only be named by its org
+	s.as(org2)
+	s.mustFail("registerAlias", "alice@bank1", "1")
+	s.as(alice)
+	s.mustInvoke("registerAlias", "alice@bank1", "1")
+	s.mustFail("registerAlias", "alice@bank1", "2")
+	if a := s.alias("alice@bank1"); a.Account != "1" || a.Owner != s.whoami() {
+		t.Errorf("alias = %+v, want account 1 owned by alice", *a)
+	}
+
+	s.mustInvoke("transfer", s.transfer("alice@bank1", "2", 10))
+	s.mustFail("transfer", s.transfer("alice@bank1", "1", 10))
+	s.mustFail("transfer", s.transfer("bob@bank1", "1", 10))
+	schedule := &schema.Schedule{From: "2", To: "alice@bank1", Amount: 5, First: s.now.Unix(), Interval: 60, Count: 1}
+	d, err := schedule.ToBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := string(s.mustInvoke("schedulePayment", string(d)))
+	var payment schema.ScheduledPayment
+	if err := payment.FromBytes(s.mustInvoke("scheduledPayment", id)); err != nil {
+		t.Fatal(err)
+	}
+	if payment.Schedule.To != "1" {
+		t.Errorf("scheduled payment to %s, want the key 1", payment.Schedule.To)
+	}
+
+	// only the owner transfers the alias
+	s.as(bob)
+	owner := s.whoami()
+	s.mustFail("transferAlias", "alice@bank1", owner)
+	s.as(alice)
+	s.mustInvoke("transferAlias", "alice@bank1", owner)
+	s.mustFail("transferAlias", "alice@bank1", s.whoami())
+	if a := s.alias("alice@bank1"); a.Owner != owner {
+		t.Errorf("alias owner = %s, want bob", a.Owner)
+	}
+	s.expectBalances(map[string]int64{"1": 90, "2": 110})
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/schema"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"github.com/pkg/errors"
+)
+
+// Composite key object types of the assets. The default asset, with an empty
+// code, keeps its balances under the plain account key so accounts created
+// before the registry are unchanged; every other balance is an Account
+// stored under (account, asset).
+const (
+	assetType   = "asset"
+	balanceType = "balance"
+)
+
+// balanceKey returns the state key of the asset balance of account.
+func (t *Paymentcc) balanceKey(stub shim.ChaincodeStubInterface, account, asset string) (string, error) {
+	if asset == "" {
+		return account, nil
+	}
+	key, err := stub.CreateCompositeKey(balanceType, []string{account, asset})
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return key, nil
+}
+
+// assetBalanceKey is balanceKey for a new balance, the asset must be registered.
+func (t *Paymentcc) assetBalanceKey(stub shim.ChaincodeStubInterface, account, asset string) (string, error) {
+	if asset != "" {
+		registered, err := t.getAsset(stub, asset)
+		if err != nil {
+			return "", err
+		}
+		if registered == nil {
+			return "", errors.Errorf("asset %s is not registered", asset)
+		}
+	}
+	return t.balanceKey(stub, account, asset)
+}
+
+// missingBalance rejects a payment involving an account without a balance in asset.
+func missingBalance(account, asset string) error {
+	if asset == "" {
+		return reject("account %s does not exist", account)
+	}
+	return reject("account %s has no %s balance", account, asset)
+}
+
+// getAssetAccount returns the asset balance of account.
+func (t *Paymentcc) getAssetAccount(stub shim.ChaincodeStubInterface, account, asset string) (*schema.Account, error) {
+	if asset == "" {
+		return t.getAccountInfo(stub, account)
+	}
+	key, err := t.balanceKey(stub, account, asset)
+	if err != nil {
+		return nil, err
+	}
+	value, err := stub.GetState(key)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(value) == 0 {
+		return nil, errors.Errorf("account %s has no %s balance", account, asset)
+	}
+	balance, _, err := t.openAccount(stub, key, value)
+	return balance, err
+}
+
+// getAsset returns nil if code is not registered.
+func (t *Paymentcc) getAsset(stub shim.ChaincodeStubInterface, code string) (*schema.Asset, error) {
+	key, err := stub.CreateCompositeKey(assetType, []string{code})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	value, err := stub.GetState(key)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if value == nil {
+		return nil, nil
+	}
+	var asset schema.Asset
+	if err := json.Unmarshal(value, &asset); err != nil {
+		return nil, errors.Wrapf(err, "malformed asset %s", code)
+	}
+	return &asset, nil
+}
+
+// registerAsset adds an asset to the registry or updates its metadata, admins only.
+// arg0 is the schema.Asset, e.g. {"code":"EUR","symbol":"€","name":"Euro","decimals":2}.
+// The decimals of a registered asset cannot change, its balances depend on them.
+func (t *Paymentcc) registerAsset(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1 (asset)")
+	}
+	if err := t.checkAdmin(stub); err != nil {
+		return shim.Error(fmt.Sprintf("registerAsset denied, err %+v", err))
+	}
+
+	asset, err := schema.DecodeAsset([]byte(args[0]))
+	if err != nil {
+		return shim.Error(fmt.Sprintf("invalid asset, err %+v", err))
+	}
+	existing, err := t.getAsset(stub, asset.Code)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("get asset %s failed, err %+v", asset.Code, err))
+	}
+	if existing != nil && existing.Decimals != asset.Decimals {
+		return shim.Error(fmt.Sprintf("asset %s is registered with %d decimals", asset.Code, existing.Decimals))
+	}
+
+	key, err := stub.CreateCompositeKey(assetType, []string{asset.Code})
+	if err != nil {
+		return shim.Error(errors.WithStack(err).Error())
+	}
+	value, err := asset.ToBytes()
+	if err != nil {
+		return shim.Error(errors.WithStack(err).Error())
+	}
+	if err := stub.PutState(key, value); err != nil {
+		return shim.Error(errors.WithMessage(err, fmt.Sprintf("put asset %s failed.", asset.Code)).Error())
+	}
+
+	logger.Infof("asset registered: %s", value)
+	return shim.Success(nil)
+}
+
+// asset returns the registry entry of the asset code in arg0.
+func (t *Paymentcc) asset(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1 (asset code)")
+	}
+	asset, err := t.getAsset(stub, args[0])
+	if err != nil {
+		return shim.Error(fmt.Sprintf("get asset %s failed, err %+v", args[0], err))
+	}
+	if asset == nil {
+		return shim.Error(fmt.Sprintf("asset %s is not registered", args[0]))
+	}
+	value, err := asset.ToBytes()
+	if err != nil {
+		return shim.Error(errors.WithStack(err).Error())
+	}
+	return shim.Success(value)
+}
+
+// assets returns the JSON array of the registered assets.
+func (t *Paymentcc) assets(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 0 {
+		return shim.Error("Incorrect number of arguments. Expecting 0")
+	}
+	return t.listJSON(stub, assetType, []string{})
+}
+
+// swap exchanges two assets between two accounts in one transaction.
+// arg0 is the schema.Swap, e.g.
+// {"version":2,"a":"1","assetA":"EUR","amountA":100,"b":"2","assetB":"USD","amountB":110}
+// Each account must hold a balance of both assets, fees and spending limits do
+// not apply, the KYC level of the creator must allow both amounts.
+func (t *Paymentcc) swap(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+	swap, err := schema.DecodeSwap([]byte(args[0]))
+	if err != nil {
+		return shim.Error(fmt.Sprintf("invalid swap, err %+v", err))
+	}
+	// both legs are debits signed by the creator
+	config, err := t.getConfig(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("get chaincode configuration failed, err %+v", err))
+	}
+	for _, amount := range []int64{swap.AmountA, swap.AmountB} {
+		if err := checkKYC(stub, config, amount); err != nil {
+			return shim.Error(fmt.Sprintf("swap denied, err %+v", err))
+		}
+	}
+
+	type leg struct {
+		account, asset string
+		delta          int64
+	}
+	legs := []leg{
+		{swap.A, swap.AssetA, -swap.AmountA},
+		{swap.B, swap.AssetA, swap.AmountA},
+		{swap.B, swap.AssetB, -swap.AmountB},
+		{swap.A, swap.AssetB, swap.AmountB},
+	}
+
+	// check every leg before updating any, a failed check leaves the state untouched
+	cache := t.newAccountCache(stub)
+	keys := make([]string, len(legs))
+	balances := make([]*schema.Account, len(legs))
+	for i, l := range legs {
+		if keys[i], err = t.balanceKey(stub, l.account, l.asset); err != nil {
+			return shim.Error(err.Error())
+		}
+		if balances[i], err = cache.get(keys[i]); err != nil {
+			return shim.Error(errors.WithMessage(err, "swap failed.").Error())
+		}
+		if balances[i] == nil {
+			return shim.Error(missingBalance(l.account, l.asset).Error())
+		}
+		if l.delta < 0 && balances[i].Balance < -l.delta {
+			return shim.Error(fmt.Sprintf("account %s has not enough %s balance (%d) to swap %d.", l.account, l.asset, balances[i].Balance, -l.delta))
+		}
+		if l.delta > 0 {
+			if err := checkCredit(l.account, balances[i], l.delta); err != nil {
+				return shim.Error(errors.WithMessage(err, "swap failed.").Error())
+			}
+		}
+	}
+	for i, l := range legs {
+		balances[i].Balance += l.delta
+		cache.update(keys[i], balances[i])
+	}
+	cache.flow(balances[0].Org, balances[1].Org, swap.AssetA, swap.AmountA)
+	cache.flow(balances[2].Org, balances[3].Org, swap.AssetB, swap.AmountB)
+	// each balance keeps the encoding it is stored with
+	if err := cache.flushStored(); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	logger.Infof("swapped %d %s of %s for %d %s of %s", swap.AmountA, swap.AssetA, swap.A, swap.AmountB, swap.AssetB, swap.B)
+	return shim.Success(nil)
+}
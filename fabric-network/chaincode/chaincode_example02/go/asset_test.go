@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/schema"
+)
+
+func (s *testStub) assetBalance(account, asset string) int64 {
+	s.t.Helper()
+	var a schema.Account
+	if err := a.FromBytes(s.mustInvoke("query", account, "json", asset)); err != nil {
+		s.t.Fatal(err)
+	}
+	return a.Balance
+}
+
+func (s *testStub) swap(swap *schema.Swap) string {
+	s.t.Helper()
+	d, err := swap.ToBytes()
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return string(d)
+}
+
+func TestSwap(t *testing.T) {
+	s := newTestStub(t)
+	s.mustInvoke("registerAsset", `{"code":"EUR","decimals":2}`)
+	s.mustFail("create", s.payload(&schema.Payload{To: "1", Amount: 10, Asset: "USD"}))
+
+	// account 1 stores its default balance with protobuf
+	d, err := (&schema.Payload{To: "1", Amount: 100}).Encode(schema.Proto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.mustInvoke("create", string(d))
+	s.create("2", 100)
+	s.mustInvoke("create", s.payload(&schema.Payload{To: "1", Amount: 1, Asset: "EUR"}))
+	s.mustInvoke("create", s.payload(&schema.Payload{To: "2", Amount: 50, Asset: "EUR"}))
+
+	s.mustInvoke("swap", s.swap(&schema.Swap{A: "1", AmountA: 30, B: "2", AssetB: "EUR", AmountB: 20}))
+	s.expectBalances(map[string]int64{"1": 70, "2": 130})
+	if a1, a2 := s.assetBalance("1", "EUR"), s.assetBalance("2", "EUR"); a1 != 21 || a2 != 30 {
+		t.Errorf("EUR balances after the swap = %d and %d, want 21 and 30", a1, a2)
+	}
+	if enc := schema.EncodingOf(s.State["1"]); enc != schema.Proto {
+		t.Errorf("account 1 is stored with %s after the swap, want %s", enc, schema.Proto)
+	}
+
+	// the second leg fails, so the first one is not applied either
+	s.mustFail("swap", s.swap(&schema.Swap{A: "1", AmountA: 10, B: "2", AssetB: "EUR", AmountB: 31}))
+	// account 3 has no EUR balance to be credited
+	s.create("3", 10)
+	s.mustFail("swap", s.swap(&schema.Swap{A: "3", AmountA: 10, B: "2", AssetB: "EUR", AmountB: 1}))
+	s.expectBalances(map[string]int64{"1": 70, "2": 130, "3": 10})
+	if a1, a2 := s.assetBalance("1", "EUR"), s.assetBalance("2", "EUR"); a1 != 21 || a2 != 30 {
+		t.Errorf("EUR balances after the failed swaps = %d and %d, want 21 and 30", a1, a2)
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/blob"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"github.com/pkg/errors"
+)
+
+// Composite key object types of the blob storage. The chunk data and a small
+// marker per chunk are stored separately so that getBlob can report which
+// chunks are present without reading the data back.
+const (
+	blobManifestType = "blobManifest"
+	blobChunkType    = "blobChunk"
+	blobStoredType   = "blobStored"
+)
+
+func chunkIndexKey(index int) string {
+	return fmt.Sprintf("%08d", index)
+}
+
+// putBlob declares or fills a blob.
+// With 2 args: arg0 is the blob id, arg1 the JSON manifest.
+// With 3 args: arg0 is the blob id, arg1 the chunk index, arg2 the chunk data.
+func (t *Paymentcc) putBlob(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	switch len(args) {
+	case 2:
+		return t.putBlobManifest(stub, args[0], []byte(args[1]))
+	case 3:
+		index, err := strconv.Atoi(args[1])
+		if err != nil {
+			return shim.Error(fmt.Sprintf("Expecting integer chunk index, got %s", args[1]))
+		}
+		return t.putBlobChunk(stub, args[0], index, []byte(args[2]))
+	default:
+		return shim.Error("Incorrect number of arguments. Expecting 2 (id, manifest) or 3 (id, index, chunk)")
+	}
+}
+
+func (t *Paymentcc) putBlobManifest(stub shim.ChaincodeStubInterface, id string, d []byte) pb.Response {
+	var manifest blob.Manifest
+	if err := manifest.FromBytes(d); err != nil {
+		return shim.Error(fmt.Sprintf("invalid manifest, err %+v", err))
+	}
+	if manifest.ID != id {
+		return shim.Error(fmt.Sprintf("manifest id %s does not match blob id %s", manifest.ID, id))
+	}
+
+	existing, err := t.getBlobManifest(stub, id)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("get manifest for blob %s failed, err %+v", id, err))
+	}
+	if existing != nil {
+		// declaring the same blob again is how a client resumes an upload
+		if existing.Equal(&manifest) {
+			return shim.Success(nil)
+		}
+		return shim.Error(fmt.Sprintf("blob %s already exists with root %s", id, existing.Root))
+	}
+
+	key, err := stub.CreateCompositeKey(blobManifestType, []string{id})
+	if err != nil {
+		return shim.Error(errors.WithStack(err).Error())
+	}
+	value, err := manifest.ToBytes()
+	if err != nil {
+		return shim.Error(errors.WithStack(err).Error())
+	}
+	if err := stub.PutState(key, value); err != nil {
+		return shim.Error(errors.WithMessage(err, fmt.Sprintf("put manifest for blob %s failed.", id)).Error())
+	}
+
+	logger.Infof("blob %s declared: %d bytes in %d chunks, root %s", id, manifest.Size, len(manifest.Chunks), manifest.Root)
+	return shim.Success(nil)
+}
+
+func (t *Paymentcc) putBlobChunk(stub shim.ChaincodeStubInterface, id string, index int, data []byte) pb.Response {
+	manifest, err := t.getBlobManifest(stub, id)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("get manifest for blob %s failed, err %+v", id, err))
+	}
+	if manifest == nil {
+		return shim.Error(fmt.Sprintf("blob %s has no manifest, put the manifest first", id))
+	}
+	if err := manifest.VerifyChunk(index, data); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	attrs := []string{id, chunkIndexKey(index)}
+	chunkKey, err := stub.CreateCompositeKey(blobChunkType, attrs)
+	if err != nil {
+		return shim.Error(errors.WithStack(err).Error())
+	}
+	storedKey, err := stub.CreateCompositeKey(blobStoredType, attrs)
+	if err != nil {
+		return shim.Error(errors.WithStack(err).Error())
+	}
+
+	if err := stub.PutState(chunkKey, data); err != nil {
+		return shim.Error(errors.WithMessage(err, fmt.Sprintf("put chunk %d of blob %s failed.", index, id)).Error())
+	}
+	if err := stub.PutState(storedKey, []byte(manifest.Chunks[index])); err != nil {
+		return shim.Error(errors.WithMessage(err, fmt.Sprintf("put chunk marker %d of blob %s failed.", index, id)).Error())
+	}
+
+	logger.Infof("blob %s: stored chunk %d (%d bytes)", id, index, len(data))
+	return shim.Success(nil)
+}
+
+// getBlob reads a blob.
+// With 1 arg: arg0 is the blob id, returns the manifest and the missing chunks.
+// With 2 args: arg0 is the blob id, arg1 the chunk index, returns the verified chunk data.
+func (t *Paymentcc) getBlob(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 && len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 1 (id) or 2 (id, index)")
+	}
+
+	id := args[0]
+	manifest, err := t.getBlobManifest(stub, id)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("get manifest for blob %s failed, err %+v", id, err))
+	}
+	if manifest == nil {
+		return shim.Error(fmt.Sprintf("blob %s does not exist", id))
+	}
+
+	if len(args) == 1 {
+		status, err := t.getBlobStatus(stub, manifest)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("get status of blob %s failed, err %+v", id, err))
+		}
+		value, err := status.ToBytes()
+		if err != nil {
+			return shim.Error(errors.WithStack(err).Error())
+		}
+		return shim.Success(value)
+	}
+
+	index, err := strconv.Atoi(args[1])
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Expecting integer chunk index, got %s", args[1]))
+	}
+	if index < 0 || index >= len(manifest.Chunks) {
+		return shim.Error(fmt.Sprintf("blob %s: chunk %d out of range [0, %d)", id, index, len(manifest.Chunks)))
+	}
+	key, err := stub.CreateCompositeKey(blobChunkType, []string{id, chunkIndexKey(index)})
+	if err != nil {
+		return shim.Error(errors.WithStack(err).Error())
+	}
+	data, err := stub.GetState(key)
+	if err != nil {
+		return shim.Error(errors.WithMessage(err, fmt.Sprintf("get chunk %d of blob %s failed.", index, id)).Error())
+	}
+	if data == nil {
+		return shim.Error(fmt.Sprintf("blob %s: chunk %d is missing", id, index))
+	}
+	if err := manifest.VerifyChunk(index, data); err != nil {
+		return shim.Error(err.Error())
+	}
+	return shim.Success(data)
+}
+
+func (t *Paymentcc) getBlobManifest(stub shim.ChaincodeStubInterface, id string) (*blob.Manifest, error) {
+	key, err := stub.CreateCompositeKey(blobManifestType, []string{id})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	value, err := stub.GetState(key)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if value == nil {
+		return nil, nil
+	}
+
+	var manifest blob.Manifest
+	if err := manifest.FromBytes(value); err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+func (t *Paymentcc) getBlobStatus(stub shim.ChaincodeStubInterface, manifest *blob.Manifest) (*blob.Status, error) {
+	iter, err := stub.GetStateByPartialCompositeKey(blobStoredType, []string{manifest.ID})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer iter.Close()
+
+	stored := make([]bool, len(manifest.Chunks))
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		_, attrs, err := stub.SplitCompositeKey(kv.Key)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		index, err := strconv.Atoi(attrs[1])
+		if err != nil || index < 0 || index >= len(stored) {
+			return nil, errors.Errorf("blob %s: malformed chunk marker %s", manifest.ID, kv.Key)
+		}
+		stored[index] = string(kv.Value) == manifest.Chunks[index]
+	}
+
+	status := &blob.Status{Manifest: manifest, Missing: []int{}}
+	for i, ok := range stored {
+		if ok {
+			status.Stored++
+		} else {
+			status.Missing = append(status.Missing, i)
+		}
+	}
+	return status, nil
+}
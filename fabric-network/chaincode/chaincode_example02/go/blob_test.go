@@ -0,0 +1,71 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/blob"
+)
+
+func (s *testStub) blobStatus(id string) *blob.Status {
+	s.t.Helper()
+	var status blob.Status
+	if err := status.FromBytes(s.mustInvoke("getBlob", id)); err != nil {
+		s.t.Fatal(err)
+	}
+	return &status
+}
+
+func TestBlob(t *testing.T) {
+	s := newTestStub(t)
+	const data = "abcdefghij"
+	manifest, err := blob.NewManifest("doc", strings.NewReader(data), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := manifest.ToBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.mustFail("putBlob", "doc", "0", "abcd")
+	s.mustFail("putBlob", "other", string(d))
+	s.mustInvoke("putBlob", "doc", string(d))
+	if status := s.blobStatus("doc"); status.Stored != 0 || !reflect.DeepEqual(status.Missing, []int{0, 1, 2}) {
+		t.Errorf("status of a declared blob = %d stored, %v missing", status.Stored, status.Missing)
+	}
+
+	s.mustInvoke("putBlob", "doc", "1", "efgh")
+	s.mustFail("putBlob", "doc", "0", "abcX")
+	s.mustFail("putBlob", "doc", "3", "")
+	// declaring the blob again resumes the upload, another manifest is rejected
+	s.mustInvoke("putBlob", "doc", string(d))
+	other, err := blob.NewManifest("doc", strings.NewReader("0123456789"), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	od, err := other.ToBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.mustFail("putBlob", "doc", string(od))
+	if status := s.blobStatus("doc"); status.Stored != 1 || !reflect.DeepEqual(status.Missing, []int{0, 2}) {
+		t.Errorf("status after chunk 1 = %d stored, %v missing", status.Stored, status.Missing)
+	}
+
+	s.mustInvoke("putBlob", "doc", "0", "abcd")
+	s.mustInvoke("putBlob", "doc", "2", "ij")
+	if status := s.blobStatus("doc"); !status.Complete() {
+		t.Errorf("status after every chunk = %d stored, %v missing", status.Stored, status.Missing)
+	}
+	var got string
+	for i := range manifest.Chunks {
+		got += string(s.mustInvoke("getBlob", "doc", chunkIndexKey(i)))
+	}
+	if got != data {
+		t.Errorf("blob read back as %q, want %q", got, data)
+	}
+	s.mustFail("getBlob", "doc", "3")
+	s.mustFail("getBlob", "missing")
+}
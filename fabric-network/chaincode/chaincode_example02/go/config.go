@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/schema"
+	"github.com/hyperledger/fabric/core/chaincode/lib/cid"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+)
+
+// configType is the composite key object type of the chaincode configuration.
+const configType = "paymentConfig"
+
+// ccConfig is the chaincode configuration, set by Init.
+// Admins are the MSP IDs allowed to call the admin functions such as createBatch.
+// Fees is the fee schedule of transfers, nil when transfers are free.
+// Tickers and Operators are the MSP IDs allowed to call tick and settle
+// besides the admins. KYC caps transfers by the KYC level of the creator,
+// nil when there is no cap. ACL restricts the functions to some MSPs, OUs
+// and roles, nil when every function is open.
+type ccConfig struct {
+	Admins    []string            `json:"admins"`
+	Fees      *schema.FeeSchedule `json:"fees,omitempty"`
+	Tickers   []string            `json:"tickers,omitempty"`
+	Operators []string            `json:"operators,omitempty"`
+	KYC       *schema.KYCPolicy   `json:"kyc,omitempty"`
+	ACL       schema.ACL          `json:"acl,omitempty"`
+}
+
+// defaultConfig is used when Init gets no configuration, e.g. the legacy
+// `init a 100 b 200` arguments of the network scripts.
+var defaultConfig = ccConfig{Admins: []string{"Org1MSP"}}
+
+// initConfig stores the configuration passed as the only Init argument.
+// Without one, the stored configuration is kept, or the default one is
+// stored on first instantiation.
+func (t *Paymentcc) initConfig(stub shim.ChaincodeStubInterface, args []string) error {
+	if len(args) == 1 && strings.HasPrefix(strings.TrimSpace(args[0]), "{") {
+		var config ccConfig
+		if err := json.Unmarshal([]byte(args[0]), &config); err != nil {
+			return errors.Wrap(err, "malformed chaincode configuration")
+		}
+		if config.Fees != nil {
+			if err := config.Fees.Validate(); err != nil {
+				return err
+			}
+		}
+		if config.KYC != nil {
+			if err := config.KYC.Validate(); err != nil {
+				return err
+			}
+		}
+		if err := config.ACL.Validate(); err != nil {
+			return err
+		}
+		return t.putConfig(stub, &config)
+	}
+
+	existing, err := t.getStoredConfig(stub)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return nil
+	}
+	config := defaultConfig
+	return t.putConfig(stub, &config)
+}
+
+func (t *Paymentcc) putConfig(stub shim.ChaincodeStubInterface, config *ccConfig) error {
+	key, err := stub.CreateCompositeKey(configType, []string{})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	value, err := json.Marshal(config)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := stub.PutState(key, value); err != nil {
+		return errors.WithMessage(err, "put chaincode configuration failed.")
+	}
+	logger.Infof("chaincode configuration: %s", value)
+	return nil
+}
+
+// getStoredConfig returns nil if Init has not stored a configuration yet.
+func (t *Paymentcc) getStoredConfig(stub shim.ChaincodeStubInterface) (*ccConfig, error) {
+	key, err := stub.CreateCompositeKey(configType, []string{})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	value, err := stub.GetState(key)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if value == nil {
+		return nil, nil
+	}
+	var config ccConfig
+	if err := json.Unmarshal(value, &config); err != nil {
+		return nil, errors.Wrap(err, "malformed stored chaincode configuration")
+	}
+	return &config, nil
+}
+
+func (t *Paymentcc) getConfig(stub shim.ChaincodeStubInterface) (*ccConfig, error) {
+	config, err := t.getStoredConfig(stub)
+	if err != nil || config != nil {
+		return config, err
+	}
+	c := defaultConfig
+	return &c, nil
+}
+
+// checkAdmin fails unless the transaction creator belongs to an admin MSP.
+func (t *Paymentcc) checkAdmin(stub shim.ChaincodeStubInterface) error {
+	config, err := t.getConfig(stub)
+	if err != nil {
+		return err
+	}
+	return checkCreatorMSP(stub, "an admin", config.Admins)
+}
+
+// checkCreatorMSP fails unless the transaction creator belongs to one of the
+// MSP lists, role describes them in the error.
+func checkCreatorMSP(stub shim.ChaincodeStubInterface, role string, lists ...[]string) error {
+	mspID, err := cid.GetMSPID(stub)
+	if err != nil {
+		return errors.WithMessage(err, "get MSP ID of the creator failed.")
+	}
+	for _, list := range lists {
+		for _, allowed := range list {
+			if allowed == mspID {
+				return nil
+			}
+		}
+	}
+	return errors.Errorf("%s is not %s MSP", mspID, role)
+}
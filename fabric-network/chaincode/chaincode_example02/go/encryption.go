@@ -0,0 +1,498 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/schema"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"github.com/pkg/errors"
+)
+
+// keyStateType is the composite key object type of the schema.KeyState.
+const keyStateType = "keyState"
+
+// Page sizes of rotate.
+const (
+	defaultRotatePage = 100
+	maxRotatePage     = 1000
+)
+
+// Bookmark prefixes of the two ranges re-encrypted by rotate: the accounts,
+// followed by the last account of the page, then the snapshot pages,
+// followed by the last id/page.
+const (
+	accountsBookmark  = "accounts/"
+	snapshotsBookmark = "snapshots/"
+)
+
+// sealedMagic starts a sealed account state, followed by the big-endian
+// uint32 key version, the nonce and the AES-GCM ciphertext. JSON and proto
+// account states never start with a zero byte.
+var sealedMagic = []byte{0, 'a', 'e', 's'}
+
+const sealedHeaderSize = 8
+
+// keyCheck identifies an AES key in the schema.KeyState without revealing it.
+func keyCheck(key []byte) string {
+	sum := sha256.Sum256(append([]byte("payment_cc key check "), key...))
+	return hex.EncodeToString(sum[:8])
+}
+
+func isSealed(value []byte) bool {
+	return len(value) >= sealedHeaderSize && bytes.HasPrefix(value, sealedMagic)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	return gcm, errors.WithStack(err)
+}
+
+// seal encrypts the clear state of stateKey with the key of version. Every
+// endorser must compute the same value, so the nonce is derived from the
+// key, stateKey and the clear state instead of being random.
+func seal(key []byte, version int, stateKey string, clear []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(stateKey))
+	mac.Write([]byte{0})
+	mac.Write(clear)
+	nonce := mac.Sum(nil)[:gcm.NonceSize()]
+
+	header := make([]byte, sealedHeaderSize, sealedHeaderSize+len(nonce)+len(clear)+gcm.Overhead())
+	copy(header, sealedMagic)
+	binary.BigEndian.PutUint32(header[len(sealedMagic):], uint32(version))
+	// the header and the state key are authenticated, a sealed state cannot
+	// be moved to another account
+	ad := append(append([]byte{}, header...), stateKey...)
+	return gcm.Seal(append(header, nonce...), nonce, clear, ad), nil
+}
+
+// sealedVersion returns the key version of a sealed state.
+func sealedVersion(value []byte) int {
+	return int(binary.BigEndian.Uint32(value[len(sealedMagic):sealedHeaderSize]))
+}
+
+// unseal decrypts the sealed state of stateKey with key.
+func unseal(key []byte, stateKey string, value []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(value) < sealedHeaderSize+gcm.NonceSize() {
+		return nil, errors.Errorf("sealed state of %s is truncated", stateKey)
+	}
+	header, nonce := value[:sealedHeaderSize], value[sealedHeaderSize:sealedHeaderSize+gcm.NonceSize()]
+	ad := append(append([]byte{}, header...), stateKey...)
+	clear, err := gcm.Open(nil, nonce, value[sealedHeaderSize+gcm.NonceSize():], ad)
+	if err != nil {
+		return nil, errors.Wrapf(err, "decrypt state of %s failed", stateKey)
+	}
+	return clear, nil
+}
+
+// keyring holds the AES keys passed in the transient map of a transaction by
+// key version.
+type keyring struct {
+	state *schema.KeyState
+	keys  map[int][]byte
+}
+
+// newKeyring matches the AESKEY and AESKEY_OLD entries of the transient map
+// with the versions of the key state. Without encryption no key is needed.
+func (t *Paymentcc) newKeyring(stub shim.ChaincodeStubInterface) (*keyring, error) {
+	state, err := t.getKeyState(stub)
+	if err != nil {
+		return nil, err
+	}
+	transient, err := stub.GetTransient()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	ring := &keyring{state: state, keys: map[int][]byte{}}
+	for _, name := range []string{AESKEY, AESKEY_OLD} {
+		key, ok := transient[name]
+		if !ok {
+			continue
+		}
+		switch check := keyCheck(key); {
+		case state.Version > 0 && check == state.Check:
+			ring.keys[state.Version] = key
+		case state.Rotating && state.Previous > 0 && check == state.PreviousCheck:
+			ring.keys[state.Previous] = key
+		default:
+			return nil, errors.Errorf("transient %s is not an account key in use", name)
+		}
+	}
+	return ring, nil
+}
+
+// seal encrypts the clear state of stateKey with the current key, the state
+// is stored in clear while encryption is not enabled.
+func (r *keyring) seal(stateKey string, clear []byte) ([]byte, error) {
+	if r.state.Version == 0 {
+		return clear, nil
+	}
+	key, ok := r.keys[r.state.Version]
+	if !ok {
+		return nil, errors.Errorf("account states are encrypted with key %d, pass it as transient %s", r.state.Version, AESKEY)
+	}
+	return seal(key, r.state.Version, stateKey, clear)
+}
+
+// open returns the clear state of stateKey, value is returned as is if it
+// is not sealed.
+func (r *keyring) open(stateKey string, value []byte) ([]byte, error) {
+	if !isSealed(value) {
+		return value, nil
+	}
+	version := sealedVersion(value)
+	key, ok := r.keys[version]
+	if !ok {
+		return nil, errors.Errorf("state of %s is encrypted with key %d, which is not in the transient map", stateKey, version)
+	}
+	return unseal(key, stateKey, value)
+}
+
+// openAccount decodes the account stored as value under stateKey and returns
+// the encoding of its clear state. Only a sealed state needs the keyring.
+func (t *Paymentcc) openAccount(stub shim.ChaincodeStubInterface, stateKey string, value []byte) (*schema.Account, schema.Encoding, error) {
+	if isSealed(value) {
+		ring, err := t.newKeyring(stub)
+		if err != nil {
+			return nil, schema.JSON, err
+		}
+		if value, err = ring.open(stateKey, value); err != nil {
+			return nil, schema.JSON, err
+		}
+	}
+	var account schema.Account
+	if err := account.FromBytes(value); err != nil {
+		return nil, schema.JSON, err
+	}
+	return &account, schema.EncodingOf(value), nil
+}
+
+// getKeyState returns the zero schema.KeyState, accounts in clear, if
+// encryption was never enabled.
+func (t *Paymentcc) getKeyState(stub shim.ChaincodeStubInterface) (*schema.KeyState, error) {
+	key, err := stub.CreateCompositeKey(keyStateType, []string{})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	value, err := stub.GetState(key)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var state schema.KeyState
+	if value == nil {
+		return &state, nil
+	}
+	if err := state.FromBytes(value); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+func (t *Paymentcc) putKeyState(stub shim.ChaincodeStubInterface, state *schema.KeyState) error {
+	key, err := stub.CreateCompositeKey(keyStateType, []string{})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	value, err := state.ToBytes()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := stub.PutState(key, value); err != nil {
+		return errors.WithMessage(err, "put key state failed.")
+	}
+	logger.Infof("key state: %s", value)
+	return nil
+}
+
+// rotate re-encrypts a page of accounts with the new key passed as transient
+// AESKEY, the old key is passed as transient AESKEY_OLD, admins only.
+// arg0 is the bookmark returned by the previous call, "" to start a rotation,
+// the optional arg1 the page size (default 100, at most 1000).
+// The first call starts the rotation: new writes are sealed with the new key
+// and reads accept both keys until the call returning done completes it.
+// Without AESKEY_OLD and while accounts are stored in clear, the first
+// rotation enables encryption.
+func (t *Paymentcc) rotate(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 && len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 1 (bookmark) or 2 (bookmark, page size)")
+	}
+	if err := t.checkAdmin(stub); err != nil {
+		return shim.Error(fmt.Sprintf("rotate denied, err %+v", err))
+	}
+	bookmark := args[0]
+	page := defaultRotatePage
+	if len(args) == 2 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 || n > maxRotatePage {
+			return shim.Error(fmt.Sprintf("page size must be between 1 and %d, got %s", maxRotatePage, args[1]))
+		}
+		page = n
+	}
+
+	transient, err := stub.GetTransient()
+	if err != nil {
+		return shim.Error(errors.WithStack(err).Error())
+	}
+	newKey, oldKey := transient[AESKEY], transient[AESKEY_OLD]
+	if _, err := newGCM(newKey); err != nil {
+		return shim.Error(fmt.Sprintf("invalid transient %s, err %+v", AESKEY, err))
+	}
+	state, err := t.getKeyState(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if !state.Rotating {
+		if bookmark != "" {
+			return shim.Error("no rotation in progress, start it with an empty bookmark")
+		}
+		if state.Version > 0 && keyCheck(oldKey) != state.Check {
+			return shim.Error(fmt.Sprintf("transient %s is not the current key %d", AESKEY_OLD, state.Version))
+		}
+		if keyCheck(newKey) == state.Check {
+			return shim.Error("the new key is the current key")
+		}
+		state = &schema.KeyState{Version: state.Version + 1, Check: keyCheck(newKey), Rotating: true,
+			Previous: state.Version, PreviousCheck: state.Check}
+		if err := t.putKeyState(stub, state); err != nil {
+			return shim.Error(err.Error())
+		}
+	} else if keyCheck(newKey) != state.Check || (state.Previous > 0 && keyCheck(oldKey) != state.PreviousCheck) {
+		return shim.Error(fmt.Sprintf("rotation from key %d to %d in progress, the transient keys do not match", state.Previous, state.Version))
+	}
+
+	// the keyring is built from the new state, the stored one is not
+	// readable in this transaction
+	ring := &keyring{state: state, keys: map[int][]byte{state.Version: newKey}}
+	if state.Previous > 0 {
+		ring.keys[state.Previous] = oldKey
+	}
+	result, err := t.rotatePage(stub, ring, bookmark, page)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if result.Done {
+		state.Rotating, state.Previous, state.PreviousCheck = false, 0, ""
+		if err := t.putKeyState(stub, state); err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	logger.Infof("rotate to key %d: %d accounts re-encrypted, bookmark %q, done %t", state.Version, result.Rotated, result.Bookmark, result.Done)
+	value, err := result.ToBytes()
+	if err != nil {
+		return shim.Error(errors.WithStack(err).Error())
+	}
+	return shim.Success(value)
+}
+
+// rotatePage re-seals the states of up to page accounts or snapshot pages
+// with the current key of ring, resuming after bookmark: the state of each
+// account with its asset balances and the states kept for the snapshots,
+// then the leaves of the snapshot pages.
+func (t *Paymentcc) rotatePage(stub shim.ChaincodeStubInterface, ring *keyring, bookmark string, page int) (*schema.RotateResult, error) {
+	result := &schema.RotateResult{Version: ring.state.Version}
+	phase, last := accountsBookmark, ""
+	switch {
+	case bookmark == "":
+	case strings.HasPrefix(bookmark, accountsBookmark):
+		last = strings.TrimPrefix(bookmark, accountsBookmark)
+	case strings.HasPrefix(bookmark, snapshotsBookmark):
+		phase, last = snapshotsBookmark, strings.TrimPrefix(bookmark, snapshotsBookmark)
+	default:
+		return nil, errors.Errorf("malformed bookmark %q", bookmark)
+	}
+
+	if phase == accountsBookmark {
+		n, err := t.rotateAccounts(stub, ring, last, page, result)
+		if err != nil || result.Bookmark != "" {
+			return result, err
+		}
+		page, last = page-n, ""
+	}
+	if err := t.rotateSnapshots(stub, ring, last, page, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// rotateAccounts re-seals up to page accounts after the key last, each with
+// the states under its key: the asset balances and the states kept for the
+// snapshots. It returns the number of accounts and sets the bookmark of
+// result if more are left.
+func (t *Paymentcc) rotateAccounts(stub shim.ChaincodeStubInterface, ring *keyring, last string, page int, result *schema.RotateResult) (int, error) {
+	// the plain keys start after the composite key namespace
+	start := "\x01"
+	if last != "" {
+		start = last + "\x00"
+	}
+	iter, err := stub.GetStateByRange(start, "")
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	defer iter.Close()
+
+	accounts := 0
+	for ; iter.HasNext(); accounts++ {
+		if accounts == page {
+			result.Bookmark = accountsBookmark + last
+			return accounts, nil
+		}
+		kv, err := iter.Next()
+		if err != nil {
+			return accounts, errors.WithStack(err)
+		}
+		if err := reseal(stub, ring, kv.Key, kv.Value, result); err != nil {
+			return accounts, err
+		}
+		for _, objectType := range []string{balanceType, snapshotBeforeType} {
+			if err := t.resealAll(stub, ring, objectType, []string{kv.Key}, result); err != nil {
+				return accounts, err
+			}
+		}
+		last = kv.Key
+	}
+	return accounts, nil
+}
+
+// resealAll re-seals the states under the partial composite key (objectType, keys).
+func (t *Paymentcc) resealAll(stub shim.ChaincodeStubInterface, ring *keyring, objectType string, keys []string, result *schema.RotateResult) error {
+	iter, err := stub.GetStateByPartialCompositeKey(objectType, keys)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer iter.Close()
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if err := reseal(stub, ring, kv.Key, kv.Value, result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rotateSnapshots re-seals the leaves of up to page snapshot pages after
+// last, id/page or "" to start with the first snapshot, and sets the bookmark
+// of result if more are left, or done. The snapshots are few, the ones up to
+// the bookmark are skipped, while their pages are read by key.
+func (t *Paymentcc) rotateSnapshots(stub shim.ChaincodeStubInterface, ring *keyring, last string, page int, result *schema.RotateResult) error {
+	lastID, lastIndex := "", -1
+	if last != "" {
+		i := strings.LastIndex(last, "/")
+		index, err := strconv.Atoi(last[i+1:])
+		if i < 0 || err != nil {
+			return errors.Errorf("malformed bookmark %q", snapshotsBookmark+last)
+		}
+		lastID, lastIndex = last[:i], index
+	}
+	iter, err := stub.GetStateByPartialCompositeKey(snapshotType, []string{})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer iter.Close()
+
+	pages := 0
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		var snap schema.Snapshot
+		if err := snap.FromBytes(kv.Value); err != nil {
+			return err
+		}
+		if snap.ID < lastID {
+			continue
+		}
+		index := 0
+		if snap.ID == lastID {
+			index = lastIndex + 1
+		}
+		for ; index < len(snap.Pages); index++ {
+			if pages == page {
+				result.Bookmark = snapshotsBookmark
+				if lastID != "" {
+					result.Bookmark += fmt.Sprintf("%s/%d", lastID, lastIndex)
+				}
+				return nil
+			}
+			key, err := snapshotLeavesKey(stub, snap.ID, index)
+			if err != nil {
+				return err
+			}
+			value, err := stub.GetState(key)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			if err := reseal(stub, ring, key, value, result); err != nil {
+				return err
+			}
+			pages++
+			lastID, lastIndex = snap.ID, index
+		}
+	}
+	result.Done = true
+	return nil
+}
+
+// reseal stores the state value of key sealed with the current key of ring,
+// unless it already is, and counts it in result.
+func reseal(stub shim.ChaincodeStubInterface, ring *keyring, key string, value []byte, result *schema.RotateResult) error {
+	if isSealed(value) && sealedVersion(value) == ring.state.Version {
+		return nil
+	}
+	clear, err := ring.open(key, value)
+	if err != nil {
+		return err
+	}
+	sealed, err := ring.seal(key, clear)
+	if err != nil {
+		return err
+	}
+	if err := stub.PutState(key, sealed); err != nil {
+		return errors.WithStack(err)
+	}
+	result.Rotated++
+	return nil
+}
+
+// keyState returns the schema.KeyState of the account encryption.
+func (t *Paymentcc) keyState(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 0 {
+		return shim.Error("Incorrect number of arguments. Expecting 0")
+	}
+	state, err := t.getKeyState(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	value, err := state.ToBytes()
+	if err != nil {
+		return shim.Error(errors.WithStack(err).Error())
+	}
+	return shim.Success(value)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/schema"
+)
+
+func (s *testStub) rotate(bookmark string, page int) *schema.RotateResult {
+	s.t.Helper()
+	var result schema.RotateResult
+	if err := result.FromBytes(s.mustInvoke("rotate", bookmark, strconv.Itoa(page))); err != nil {
+		s.t.Fatal(err)
+	}
+	return &result
+}
+
+// rotateAll runs a whole rotation in pages, it returns the bookmarks.
+func (s *testStub) rotateAll(page int) []string {
+	s.t.Helper()
+	var bookmarks []string
+	for bookmark := ""; ; {
+		result := s.rotate(bookmark, page)
+		if result.Done {
+			return bookmarks
+		}
+		bookmark = result.Bookmark
+		bookmarks = append(bookmarks, bookmark)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	key1, key2 := bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 32)
+	s := newTestStub(t)
+	s.mustInvoke("registerAsset", `{"code":"EUR","decimals":2}`)
+	for i := 1; i <= 5; i++ {
+		s.create(strconv.Itoa(i), 100)
+	}
+	s.mustInvoke("create", s.payload(&schema.Payload{To: "3", Amount: 50, Asset: "EUR"}))
+	s.mustFail("create", s.payload(&schema.Payload{To: "6", Amount: 50, Asset: "EUR"}))
+	balance3, err := s.cc.balanceKey(s, "3", "EUR")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the first rotation enables encryption
+	s.transient = map[string][]byte{AESKEY: key1}
+	if bookmarks := s.rotateAll(2); len(bookmarks) != 2 || bookmarks[0] != "accounts/2" || bookmarks[1] != "accounts/4" {
+		t.Errorf("rotation bookmarks = %q, want 2 and 4", bookmarks)
+	}
+	for _, key := range []string{"1", "5", balance3} {
+		if !isSealed(s.State[key]) || sealedVersion(s.State[key]) != 1 {
+			t.Errorf("state of %q is not sealed with key 1", key)
+		}
+	}
+	s.mustInvoke("transfer", s.transfer("1", "2", 10))
+	s.transient = nil
+	s.mustFail("transfer", s.transfer("1", "2", 10))
+	s.mustFail("query", "1")
+
+	// a rotation in progress accepts both keys and resumes from its bookmark
+	s.transient = map[string][]byte{AESKEY: key2, AESKEY_OLD: key1}
+	if result := s.rotate("", 2); result.Done || result.Bookmark != "accounts/2" || result.Rotated != 2 {
+		t.Fatalf("first page = %+v, want accounts 1 and 2", *result)
+	}
+	s.mustInvoke("transfer", s.transfer("5", "1", 10))
+	if result := s.rotate("accounts/2", 2); result.Bookmark != "accounts/4" || result.Rotated != 3 {
+		t.Fatalf("second page = %+v, want accounts 3 and 4 and the EUR balance", *result)
+	}
+	// account 5 was written with key 2 by the transfer
+	if result := s.rotate("accounts/4", 2); !result.Done || result.Rotated != 0 {
+		t.Fatalf("last page = %+v, want done", *result)
+	}
+	s.transient = map[string][]byte{AESKEY: key1}
+	s.mustFail("query", "1")
+	s.transient = map[string][]byte{AESKEY: key2}
+	s.expectBalances(map[string]int64{"1": 100, "2": 110, "3": 100, "4": 100, "5": 90})
+	if got := s.assetBalance("3", "EUR"); got != 50 {
+		t.Errorf("EUR balance of 3 = %d, want 50", got)
+	}
+
+	s.mustFail("rotate", "4", "2")
+	s.as(identity{msp: "Org2MSP", name: "Admin@org2"})
+	s.mustFail("rotate", "", "2")
+}
+
+// TestRotateKeepsEncoding checks that the cache writes a sealed account back
+// in the encoding of its clear state.
+func TestRotateKeepsEncoding(t *testing.T) {
+	s := newTestStub(t)
+	d, err := (&schema.Payload{To: "1", Amount: 25}).Encode(schema.Proto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.mustInvoke("create", string(d))
+	s.create("2", 1)
+	s.transient = map[string][]byte{AESKEY: bytes.Repeat([]byte{1}, 32)}
+	s.rotateAll(100)
+
+	schedule := &schema.Schedule{From: "1", To: "2", Amount: 10, First: s.now.Unix(), Interval: 3600, Count: 1}
+	d, err = schedule.ToBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.mustInvoke("schedulePayment", string(d))
+	s.tick()
+	ring, err := s.cc.newKeyring(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clear, err := ring.open("1", s.State["1"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if enc := schema.EncodingOf(clear); enc != schema.Proto {
+		t.Errorf("account 1 is sealed with a %s state after tick, want %s", enc, schema.Proto)
+	}
+	s.expectBalances(map[string]int64{"1": 15, "2": 11})
+}
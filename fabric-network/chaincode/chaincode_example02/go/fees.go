@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/schema"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"github.com/pkg/errors"
+)
+
+// setFees replaces the fee schedule of transfers, admins only.
+// arg0 is the schema.FeeSchedule, e.g. {"flat":1,"bps":25,"min":1,"max":500,"account":"fees"}.
+// An empty schedule {} makes transfers free.
+func (t *Paymentcc) setFees(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1 (fee schedule)")
+	}
+	if err := t.checkAdmin(stub); err != nil {
+		return shim.Error(fmt.Sprintf("setFees denied, err %+v", err))
+	}
+
+	fees, err := schema.DecodeFeeSchedule([]byte(args[0]))
+	if err != nil {
+		return shim.Error(fmt.Sprintf("invalid fee schedule, err %+v", err))
+	}
+	config, err := t.getConfig(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("get chaincode configuration failed, err %+v", err))
+	}
+	if fees.IsZero() {
+		config.Fees = nil
+	} else {
+		config.Fees = fees
+	}
+	if err := t.putConfig(stub, config); err != nil {
+		return shim.Error(err.Error())
+	}
+	return shim.Success(nil)
+}
+
+// fees returns the fee schedule of transfers, {} when transfers are free.
+func (t *Paymentcc) fees(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 0 {
+		return shim.Error("Incorrect number of arguments. Expecting 0")
+	}
+	config, err := t.getConfig(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("get chaincode configuration failed, err %+v", err))
+	}
+	fees := config.Fees
+	if fees == nil {
+		fees = &schema.FeeSchedule{}
+	}
+	value, err := fees.ToBytes()
+	if err != nil {
+		return shim.Error(errors.WithStack(err).Error())
+	}
+	return shim.Success(value)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/schema"
+)
+
+func TestTransferFees(t *testing.T) {
+	s := newTestStub(t)
+	s.create("1", 1000)
+	s.create("2", 1)
+
+	fees := `{"flat":1,"bps":100,"account":"fees"}`
+	s.as(identity{msp: "Org2MSP", name: "User1@org2"})
+	s.mustFail("setFees", fees)
+	s.as(admin)
+	s.mustFail("setFees", `{"flat":1}`)
+	s.mustInvoke("setFees", fees)
+
+	var receipt schema.TransferReceipt
+	if err := receipt.FromBytes(s.mustInvoke("transfer", s.transfer("1", "2", 500))); err != nil {
+		t.Fatal(err)
+	}
+	if receipt.Fee != 6 || receipt.FeeAccount != "fees" {
+		t.Errorf("receipt = %+v, want a fee of 6 credited to fees", receipt)
+	}
+	// the fee is paid on top of the amount, so 494 leaves too little for a fee of 5
+	s.mustFail("transfer", s.transfer("1", "2", 494))
+	s.mustInvoke("transfer", s.transfer("1", "2", 489))
+	s.expectBalances(map[string]int64{"1": 0, "2": 990, "fees": 11})
+
+	// the fee account may itself transfer, and pays the fee to itself
+	s.mustInvoke("transfer", s.transfer("fees", "1", 9))
+	s.expectBalances(map[string]int64{"1": 9, "2": 990, "fees": 2})
+
+	s.mustInvoke("setFees", `{}`)
+	s.mustInvoke("transfer", s.transfer("2", "1", 990))
+	s.expectBalances(map[string]int64{"1": 999, "2": 0, "fees": 2})
+}
+
+func TestTransferCreditOverflow(t *testing.T) {
+	s := newTestStub(t)
+	s.create("1", 10)
+	s.create("2", math.MaxInt64-5)
+	s.mustFail("transfer", s.transfer("1", "2", 6))
+	s.mustInvoke("transfer", s.transfer("1", "2", 5))
+	s.expectBalances(map[string]int64{"1": 5, "2": math.MaxInt64})
+}
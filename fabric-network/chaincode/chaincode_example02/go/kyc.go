@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/schema"
+	"github.com/hyperledger/fabric/core/chaincode/lib/cid"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"github.com/pkg/errors"
+)
+
+// kycLevel returns the KYC level of the transaction creator, read from the
+// schema.KYCAttribute of its certificate, 0 without the attribute.
+func kycLevel(stub shim.ChaincodeStubInterface) (int, error) {
+	value, found, err := cid.GetAttributeValue(stub, schema.KYCAttribute)
+	if err != nil {
+		return 0, errors.WithMessage(err, "get attributes of the creator failed.")
+	}
+	if !found {
+		return 0, nil
+	}
+	return schema.ParseKYCLevel(value)
+}
+
+// checkKYC fails unless the KYC level of the transaction creator allows a
+// transfer of amount under the policy of config.
+func checkKYC(stub shim.ChaincodeStubInterface, config *ccConfig, amount int64) error {
+	required := config.KYC.Required(amount)
+	if required == 0 {
+		return nil
+	}
+	level, err := kycLevel(stub)
+	if err != nil {
+		return err
+	}
+	if level < required {
+		return errors.Errorf("a transfer of %d requires KYC level %d, the creator has level %d", amount, required, level)
+	}
+	return nil
+}
+
+// setKYC replaces the KYC policy of transfers, admins only.
+// arg0 is the schema.KYCPolicy, e.g. {"thresholds":[{"amount":1000,"level":1},{"amount":100000,"level":2}]}.
+// An empty policy {} allows every transfer.
+func (t *Paymentcc) setKYC(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1 (kyc policy)")
+	}
+	if err := t.checkAdmin(stub); err != nil {
+		return shim.Error(fmt.Sprintf("setKYC denied, err %+v", err))
+	}
+
+	policy, err := schema.DecodeKYCPolicy([]byte(args[0]))
+	if err != nil {
+		return shim.Error(fmt.Sprintf("invalid kyc policy, err %+v", err))
+	}
+	config, err := t.getConfig(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("get chaincode configuration failed, err %+v", err))
+	}
+	if policy.IsZero() {
+		config.KYC = nil
+	} else {
+		config.KYC = policy
+	}
+	if err := t.putConfig(stub, config); err != nil {
+		return shim.Error(err.Error())
+	}
+	return shim.Success(nil)
+}
+
+// kyc returns the KYC policy of transfers, {"thresholds":null} when there is none.
+func (t *Paymentcc) kyc(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 0 {
+		return shim.Error("Incorrect number of arguments. Expecting 0")
+	}
+	config, err := t.getConfig(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("get chaincode configuration failed, err %+v", err))
+	}
+	policy := config.KYC
+	if policy == nil {
+		policy = &schema.KYCPolicy{}
+	}
+	value, err := policy.ToBytes()
+	if err != nil {
+		return shim.Error(errors.WithStack(err).Error())
+	}
+	return shim.Success(value)
+}
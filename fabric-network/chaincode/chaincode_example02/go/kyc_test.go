@@ -0,0 +1,64 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/schema"
+)
+
+func TestKYC(t *testing.T) {
+	unverified := identity{msp: "Org1MSP", name: "User1@org1"}
+	verified := identity{msp: "Org1MSP", name: "User2@org1", attrs: map[string]string{schema.KYCAttribute: "1"}}
+	s := newTestStub(t)
+	s.mustInvoke("registerAsset", `{"code":"EUR","decimals":2}`)
+	s.create("1", 1000)
+	s.create("2", 1000)
+	s.mustInvoke("create", s.payload(&schema.Payload{To: "1", Amount: 1000, Asset: "EUR"}))
+	s.mustInvoke("create", s.payload(&schema.Payload{To: "2", Amount: 1000, Asset: "EUR"}))
+
+	s.as(identity{msp: "Org2MSP", name: "Admin@org2"})
+	s.mustFail("setKYC", `{"thresholds":[{"amount":100,"level":1}]}`)
+	s.as(admin)
+	s.mustInvoke("setKYC", `{"thresholds":[{"amount":100,"level":1}]}`)
+
+	s.as(unverified)
+	s.mustInvoke("transfer", s.transfer("1", "2", 100))
+	s.mustFail("transfer", s.transfer("1", "2", 101))
+	// each amount of a swap is a debit of the creator
+	s.mustInvoke("swap", s.swap(&schema.Swap{A: "1", AmountA: 100, B: "2", AssetB: "EUR", AmountB: 100}))
+	s.mustFail("swap", s.swap(&schema.Swap{A: "1", AmountA: 10, B: "2", AssetB: "EUR", AmountB: 101}))
+	s.mustFail("swap", s.swap(&schema.Swap{A: "1", AmountA: 101, B: "2", AssetB: "EUR", AmountB: 10}))
+
+	s.as(verified)
+	s.mustInvoke("transfer", s.transfer("1", "2", 101))
+	s.mustInvoke("swap", s.swap(&schema.Swap{A: "1", AmountA: 10, B: "2", AssetB: "EUR", AmountB: 101}))
+	s.expectBalances(map[string]int64{"1": 689, "2": 1311})
+
+	s.as(admin)
+	s.mustInvoke("setKYC", `{}`)
+	s.as(unverified)
+	s.mustInvoke("transfer", s.transfer("1", "2", 500))
+}
+
+func TestKYCRepayDebt(t *testing.T) {
+	org2 := identity{msp: "Org2MSP", name: "User1@org2"}
+	s := newTestStub(t)
+	s.create("1", 1000)
+	s.as(org2)
+	s.create("2", 1)
+	s.as(admin)
+	s.mustInvoke("transfer", s.transfer("1", "2", 500))
+	id := s.txID()
+	s.mustInvoke("transfer", s.transfer("2", "1", 500))
+	s.mustInvoke("dispute", id)
+	s.mustInvoke("approveReversal", id)
+	s.mustInvoke("transfer", s.transfer("1", "2", 500))
+	s.mustInvoke("setKYC", `{"thresholds":[{"amount":100,"level":1}]}`)
+
+	s.as(org2)
+	s.mustFail("repayDebt", id)
+	s.mustInvoke("repayDebt", id, "100")
+	s.as(identity{msp: "Org2MSP", name: "User2@org2", attrs: map[string]string{schema.KYCAttribute: "1"}})
+	s.mustInvoke("repayDebt", id)
+	s.expectBalances(map[string]int64{"1": 1000, "2": 1})
+}
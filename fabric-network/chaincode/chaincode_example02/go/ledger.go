@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/schema"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/pkg/errors"
+)
+
+// accountCache holds the accounts read and updated by one transaction. Fabric
+// does not let a transaction read its own writes, so a transaction moving
+// funds of the same account more than once reads it once here and writes it
+// back with flush.
+// It also nets the cross-org flows of the transaction into position records.
+type accountCache struct {
+	t        *Paymentcc
+	stub     shim.ChaincodeStubInterface
+	accounts map[string]*schema.Account
+	// encodings are the encodings the accounts are stored with
+	encodings map[string]schema.Encoding
+	updated   []string
+	flows     map[[2]string]map[string]int64
+}
+
+func (t *Paymentcc) newAccountCache(stub shim.ChaincodeStubInterface) *accountCache {
+	return &accountCache{t: t, stub: stub, accounts: map[string]*schema.Account{},
+		encodings: map[string]schema.Encoding{}, flows: map[[2]string]map[string]int64{}}
+}
+
+// get returns the account stored under key, nil if it does not exist.
+func (c *accountCache) get(key string) (*schema.Account, error) {
+	if account, ok := c.accounts[key]; ok {
+		return account, nil
+	}
+	value, err := c.stub.GetState(key)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var account *schema.Account
+	if len(value) != 0 {
+		var enc schema.Encoding
+		if account, enc, err = c.t.openAccount(c.stub, key, value); err != nil {
+			return nil, err
+		}
+		c.encodings[key] = enc
+	}
+	c.accounts[key] = account
+	return account, nil
+}
+
+// update marks the account under key to be written by flush.
+func (c *accountCache) update(key string, account *schema.Account) {
+	c.accounts[key] = account
+	if !c.isUpdated(key) {
+		c.updated = append(c.updated, key)
+	}
+}
+
+func (c *accountCache) isUpdated(key string) bool {
+	for _, k := range c.updated {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// flow records amount of asset paid by an account of org from to an account
+// of org to. Flows within an org or involving accounts without org are not
+// interbank obligations.
+func (c *accountCache) flow(from, to, asset string, amount int64) {
+	if from == "" || to == "" || from == to || amount == 0 {
+		return
+	}
+	pair := [2]string{from, to}
+	if to < from {
+		pair, amount = [2]string{to, from}, -amount
+	}
+	if c.flows[pair] == nil {
+		c.flows[pair] = map[string]int64{}
+	}
+	c.flows[pair][asset] += amount
+}
+
+// flush writes the updated accounts with enc, the encoding negotiated by the
+// payload of the transaction, and the position records of the flows.
+func (c *accountCache) flush(enc schema.Encoding) error {
+	return c.write(&enc)
+}
+
+// flushStored is flush writing the updated accounts back in the encoding each
+// one is stored with, JSON for the accounts created by the transaction. Transactions
+// without a payload of their own, such as tick, keep the encoding chosen by
+// the clients of every account this way.
+func (c *accountCache) flushStored() error {
+	return c.write(nil)
+}
+
+func (c *accountCache) write(enc *schema.Encoding) error {
+	for _, key := range c.updated {
+		e := c.encodings[key]
+		if enc != nil {
+			e = *enc
+		}
+		if err := c.t.putAccount(c.stub, key, c.accounts[key], e); err != nil {
+			return errors.WithMessage(err, fmt.Sprintf("put balance for account %s failed.", key))
+		}
+	}
+	c.updated = nil
+
+	for pair, assets := range c.flows {
+		for asset, amount := range assets {
+			if amount == 0 {
+				continue
+			}
+			if err := c.t.putPosition(c.stub, pair, asset, amount); err != nil {
+				return err
+			}
+		}
+	}
+	c.flows = map[[2]string]map[string]int64{}
+	return nil
+}
+
+// rejectedError is a payment refused by the payment rules, such as an
+// insufficient balance, as opposed to a failure to access the ledger.
+type rejectedError string
+
+func (e rejectedError) Error() string {
+	return string(e)
+}
+
+func reject(format string, args ...interface{}) error {
+	return rejectedError(fmt.Sprintf(format, args...))
+}
+
+// checkCredit rejects a credit of amount that would overflow the balance of
+// account.
+func checkCredit(account string, a *schema.Account, amount int64) error {
+	if amount > math.MaxInt64-a.Balance {
+		return reject("account %s cannot be credited %d, its balance (%d) would overflow", account, amount, a.Balance)
+	}
+	return nil
+}
+
+// isRejected reports whether err is a rejectedError.
+func isRejected(err error) bool {
+	_, ok := errors.Cause(err).(rejectedError)
+	return ok
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/schema"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"github.com/pkg/errors"
+)
+
+// txTime returns the transaction timestamp set by the client, which is the
+// same on every endorser, unlike the local clock.
+func txTime(stub shim.ChaincodeStubInterface) (time.Time, error) {
+	ts, err := stub.GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, errors.WithStack(err)
+	}
+	return time.Unix(ts.Seconds, int64(ts.Nanos)), nil
+}
+
+// setLimits replaces the spending limits of an account, admins only.
+// arg0 is the account key, arg1 the schema.Limits, e.g. {"daily":1000,"perTx":100}.
+// Zero limits remove the limit, the spend window is kept.
+func (t *Paymentcc) setLimits(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 2 (account, limits)")
+	}
+	if err := t.checkAdmin(stub); err != nil {
+		return shim.Error(fmt.Sprintf("setLimits denied, err %+v", err))
+	}
+
+	limits, err := schema.DecodeLimits([]byte(args[1]))
+	if err != nil {
+		return shim.Error(fmt.Sprintf("invalid limits, err %+v", err))
+	}
+
+	key := args[0]
+	value, err := stub.GetState(key)
+	if err != nil {
+		return shim.Error(errors.WithStack(err).Error())
+	}
+	if len(value) == 0 {
+		return shim.Error(fmt.Sprintf("account %s does not exist", key))
+	}
+	account, enc, err := t.openAccount(stub, key, value)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("get account for %s failed, err %+v", key, err))
+	}
+
+	if limits.IsZero() {
+		account.Limits = nil
+	} else {
+		account.Limits = limits
+	}
+	if err := t.putAccount(stub, key, account, enc); err != nil {
+		return shim.Error(errors.WithMessage(err, fmt.Sprintf("put limits for account %s failed.", key)).Error())
+	}
+
+	logger.Infof("limits of %s set to daily %d, per transaction %d", key, limits.Daily, limits.PerTx)
+	return shim.Success(nil)
+}
+
+// limits returns the schema.LimitStatus of the account in arg0: its limits,
+// the amount spent in the last 24 hours and what remains.
+func (t *Paymentcc) limits(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1 (account)")
+	}
+
+	key := args[0]
+	account, err := t.getAccountInfo(stub, key)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("get account for %s failed, err %+v", key, err))
+	}
+	now, err := txTime(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("get transaction timestamp failed, err %+v", err))
+	}
+
+	value, err := account.LimitStatus(now).ToBytes()
+	if err != nil {
+		return shim.Error(errors.WithStack(err).Error())
+	}
+	return shim.Success(value)
+}
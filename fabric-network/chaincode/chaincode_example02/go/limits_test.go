@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/schema"
+)
+
+func (s *testStub) transfer(from, to string, amount int64) string {
+	return s.payload(&schema.Payload{From: from, To: to, Amount: amount})
+}
+
+func (s *testStub) limitStatus(account string) *schema.LimitStatus {
+	s.t.Helper()
+	var status schema.LimitStatus
+	if err := json.Unmarshal(s.mustInvoke("limits", account), &status); err != nil {
+		s.t.Fatal(err)
+	}
+	return &status
+}
+
+func TestSpendingLimits(t *testing.T) {
+	s := newTestStub(t)
+	s.create("1", 1000)
+	s.create("2", 1)
+	s.mustFail("create", s.payload(&schema.Payload{To: "1", Amount: 5}))
+
+	limits := `{"daily":100,"perTx":60}`
+	s.as(identity{msp: "Org2MSP", name: "User1@org2"})
+	s.mustFail("setLimits", "1", limits)
+	s.as(admin)
+	s.mustFail("setLimits", "3", limits)
+	s.mustInvoke("setLimits", "1", limits)
+
+	s.mustFail("transfer", s.transfer("1", "2", 61))
+	s.mustInvoke("transfer", s.transfer("1", "2", 60))
+	s.now = s.now.Add(time.Hour)
+	s.mustFail("transfer", s.transfer("1", "2", 41))
+	s.mustInvoke("transfer", s.transfer("1", "2", 40))
+	if status := s.limitStatus("1"); status.Spent != 100 || status.RemainingDaily != 0 || status.MaxTransfer != 0 {
+		t.Errorf("limits after spending the daily limit = %+v", *status)
+	}
+
+	// the first transfer leaves the window 24 hours after it was made
+	s.now = s.now.Add(22 * time.Hour)
+	s.mustFail("transfer", s.transfer("1", "2", 1))
+	s.now = s.now.Add(time.Hour)
+	s.mustInvoke("transfer", s.transfer("1", "2", 60))
+	s.mustFail("transfer", s.transfer("1", "2", 1))
+	s.now = s.now.Add(time.Hour)
+	s.mustInvoke("transfer", s.transfer("1", "2", 40))
+	s.expectBalances(map[string]int64{"1": 800, "2": 201})
+
+	// a new day starts with the whole limit
+	s.now = s.now.Add(24 * time.Hour)
+	if status := s.limitStatus("1"); status.Spent != 0 || status.RemainingDaily != 100 || status.MaxTransfer != 60 {
+		t.Errorf("limits a day later = %+v", *status)
+	}
+	s.mustInvoke("setLimits", "1", `{}`)
+	s.mustInvoke("transfer", s.transfer("1", "2", 500))
+	if status := s.limitStatus("1"); status.RemainingDaily != schema.Unlimited || status.MaxTransfer != schema.Unlimited {
+		t.Errorf("limits once removed = %+v", *status)
+	}
+}
@@ -1,23 +1,28 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
+	"time"
 	"github.com/hyperledger/fabric/bccsp"
 	"github.com/hyperledger/fabric/bccsp/factory"
 	"github.com/pkg/errors"
-	"strconv"
 
+	"github.com/GingerMoon/fabric_demo/payment-common/schema"
+	"github.com/hyperledger/fabric/core/chaincode/lib/cid"
 	"github.com/hyperledger/fabric/core/chaincode/shim"
 	pb "github.com/hyperledger/fabric/protos/peer"
 )
 
 const (
 	AESKEY        = "AESKEY"
+	AESKEY_OLD    = "AESKEY_OLD"
 	ECDSAKEY      = "ECDSAKEY_PRI"
 	ECDSAKEY_FROM = "ECDSAKEY_FROM"
 	ECDSAKEY_TO   = "ECDSAKEY_TO"
 	IV            = "IV"
+
+	// args longer than this, such as blob chunks, are logged by size only
+	maxLoggedArg = 256
 )
 
 var (
@@ -30,36 +35,13 @@ type Paymentcc struct {
 	bccspInst bccsp.BCCSP
 }
 
-type Payload struct {
-	From   string `json:from`
-	To     string `json:to`
-	Amount string `json:amount`
-	Blob   [2]byte `json:blob`
-}
-
-func (a *Payload) ToBytes() ([]byte, error) {
-	return json.Marshal(a)
-}
-
-func (a *Payload) FromBytes(d []byte) error {
-	return json.Unmarshal(d, a)
-}
-
-type accountInfo struct {
-	Balance string  `json: "balance"`
-	Blob    [2]byte `json: "blob"` // 1G exceeds the limitation of gRPC
-}
-
-func (a *accountInfo) ToBytes() ([]byte, error) {
-	return json.Marshal(a)
-}
-
-func (a *accountInfo) FromBytes(d []byte) error {
-	return json.Unmarshal(d, a)
-}
-
+// Init takes an optional JSON ccConfig, e.g. {"admins":["Org1MSP"]}.
 func (t *Paymentcc) Init(stub shim.ChaincodeStubInterface) pb.Response {
 	logger.Info("Init")
+	_, args := stub.GetFunctionAndParameters()
+	if err := t.initConfig(stub, args); err != nil {
+		return shim.Error(fmt.Sprintf("init failed, err %+v", err))
+	}
 	return shim.Success(nil)
 }
 
@@ -69,9 +51,21 @@ func (t *Paymentcc) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
 
 	logger.Infof("function: %s", f)
 	for i, arg := range args {
+		if len(arg) > maxLoggedArg {
+			logger.Infof("receives args[%d]: (%d bytes)", i, len(arg))
+			continue
+		}
 		logger.Infof("receives args[%d]: %s", i, arg)
 	}
 
+	config, err := t.getConfig(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("get chaincode configuration failed, err %+v", err))
+	}
+	if err := checkACL(stub, config, f); err != nil {
+		return shim.Error(fmt.Sprintf("%s denied, err %+v", f, err))
+	}
+
 	switch f {
 	case "create":
 		return t.create(stub, args)
@@ -79,6 +73,80 @@ func (t *Paymentcc) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
 		return t.query(stub, args)
 	case "transfer":
 		return t.transfer(stub, args)
+	case "setLimits":
+		return t.setLimits(stub, args)
+	case "limits":
+		return t.limits(stub, args)
+	case "setFees":
+		return t.setFees(stub, args)
+	case "fees":
+		return t.fees(stub, args)
+	case "setKYC":
+		return t.setKYC(stub, args)
+	case "kyc":
+		return t.kyc(stub, args)
+	case "setACL":
+		return t.setACL(stub, args)
+	case "acl":
+		return t.acl(stub, args)
+	case "registerAsset":
+		return t.registerAsset(stub, args)
+	case "asset":
+		return t.asset(stub, args)
+	case "assets":
+		return t.assets(stub, args)
+	case "swap":
+		return t.swap(stub, args)
+	case "schedulePayment":
+		return t.schedulePayment(stub, args)
+	case "cancelSchedule":
+		return t.cancelSchedule(stub, args)
+	case "scheduledPayment":
+		return t.scheduledPayment(stub, args)
+	case "tick":
+		return t.tick(stub, args)
+	case "positions":
+		return t.positions(stub, args)
+	case "settle":
+		return t.settle(stub, args)
+	case "settlement":
+		return t.settlement(stub, args)
+	case "dispute":
+		return t.dispute(stub, args)
+	case "approveReversal":
+		return t.approveReversal(stub, args)
+	case "repayDebt":
+		return t.repayDebt(stub, args)
+	case "transferRecord":
+		return t.transferRecord(stub, args)
+	case "history":
+		return t.history(stub, args)
+	case "debts":
+		return t.debts(stub, args)
+	case "registerAlias":
+		return t.registerAlias(stub, args)
+	case "transferAlias":
+		return t.transferAlias(stub, args)
+	case "alias":
+		return t.alias(stub, args)
+	case "whoami":
+		return t.whoami(stub, args)
+	case "rotate":
+		return t.rotate(stub, args)
+	case "keyState":
+		return t.keyState(stub, args)
+	case "snapshot":
+		return t.snapshot(stub, args)
+	case "snapshotInfo":
+		return t.snapshotInfo(stub, args)
+	case "proof":
+		return t.proof(stub, args)
+	case "createBatch":
+		return t.createBatch(stub, args)
+	case "putBlob":
+		return t.putBlob(stub, args)
+	case "getBlob":
+		return t.getBlob(stub, args)
 	default:
 		return shim.Error(fmt.Sprintf("Unsupported function %s", f))
 	}
@@ -90,49 +158,92 @@ func (t *Paymentcc) create(stub shim.ChaincodeStubInterface, args []string) pb.R
 		return shim.Error("Incorrect number of arguments. Expecting 1")
 	}
 
-	payload_str := args[0]
-
-	var payload Payload
-	payload.FromBytes([]byte(payload_str))
-
-	if _, err := strconv.Atoi(payload.Amount); err != nil {
-		return shim.Error("Expecting integer value for asset holding")
+	payload, err := schema.Decode([]byte(args[0]), schema.Create)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("invalid payload, err %+v", err))
 	}
 
-	err := t.putBalance(stub, payload.To, payload.Amount)
+	key, err := t.assetBalanceKey(stub, payload.To, payload.Asset)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("create %s balance for %s failed, err %+v", payload.Asset, payload.To, err))
+	}
+	// an asset balance belongs to an account, rotate finds it through the account
+	if payload.Asset != "" {
+		if _, err := t.getAccountInfo(stub, payload.To); err != nil {
+			return shim.Error(fmt.Sprintf("create %s balance for %s failed, err %+v", payload.Asset, payload.To, err))
+		}
+	}
+	// creating it again would reset the balance, the limits and the spend window
+	existing, err := stub.GetState(key)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("get account %s failed, err %+v", key, errors.WithStack(err)))
+	}
+	if len(existing) != 0 {
+		return shim.Error(fmt.Sprintf("account %s already exists", key))
+	}
+	// the account belongs to the organization of its creator, see settle
+	org, err := cid.GetMSPID(stub)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("put balance %s for %s failed, err %+v", args[1], args[0], err))
+		return shim.Error(fmt.Sprintf("get MSP ID of the creator failed, err %+v", err))
+	}
+	err = t.putBalance(stub, key, int(payload.Amount), org, schema.EncodingOf([]byte(args[0])))
+	if err != nil {
+		return shim.Error(fmt.Sprintf("put balance %d for %s failed, err %+v", payload.Amount, payload.To, err))
 	}
 
 	return shim.Success(nil)
 }
 
-// arg0 is the world state key
+// arg0 is the world state key or an alias, the optional arg1 is the response encoding (json or proto),
+// the optional arg2 the asset code, the default asset if empty
 func (t *Paymentcc) query(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	if len(args) != 1 {
+	if len(args) < 1 || len(args) > 3 {
 		return shim.Error("Incorrect number of arguments. Expecting name of the person to query")
 	}
 
-	key := args[0]
-	account, err := t.getAccountInfo(stub, key)
-	cleartextValue, err := account.ToBytes()
+	enc := schema.JSON
+	if len(args) >= 2 {
+		var err error
+		if enc, err = schema.ParseEncoding(args[1]); err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+	asset := ""
+	if len(args) == 3 {
+		asset = args[2]
+	}
+
+	key, err := t.resolveAccount(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	account, err := t.getAssetAccount(stub, key, asset)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("get account for %s failed, err %+v", key, err))
+	}
+	cleartextValue, err := account.Encode(enc)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("getStateDecryptAndVerify failed, err %+v", err))
+		return shim.Error(fmt.Sprintf("encode account %s failed, err %+v", key, err))
 	}
 
 	return shim.Success(cleartextValue)
 }
 
-func (t *Paymentcc) getAccountInfo (stub shim.ChaincodeStubInterface, key string) (*accountInfo, error) {
+func (t *Paymentcc) getAccountInfo (stub shim.ChaincodeStubInterface, key string) (*schema.Account, error) {
 	accountInfobytes, err := stub.GetState(key)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
+	if len(accountInfobytes) == 0 {
+		return nil, errors.Errorf("account %s does not exist", key)
+	}
 
-	var account accountInfo
-	account.FromBytes(accountInfobytes)
+	account, _, err := t.openAccount(stub, key, accountInfobytes)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
 
-	return &account, nil
+	return account, nil
 }
 
 func (t *Paymentcc) getBalance (stub shim.ChaincodeStubInterface, key string) (int, error) {
@@ -141,23 +252,35 @@ func (t *Paymentcc) getBalance (stub shim.ChaincodeStubInterface, key string) (i
 		return -1, errors.WithStack(errors.WithMessage(err, fmt.Sprintf("get account for %s failed.", key)))
 	}
 
-	balance, err := strconv.Atoi(account.Balance)
-	if err != nil {
-		return -1, errors.WithStack(err)
-	}
+	return int(account.Balance), nil
+}
 
-	return balance, err
+// putBalance stores a new account of org holding balance.
+func (t *Paymentcc) putBalance (stub shim.ChaincodeStubInterface, key string, balance int, org string, enc schema.Encoding) error {
+	return t.putAccount(stub, key, &schema.Account{Balance: int64(balance), Org: org}, enc)
 }
 
-func (t *Paymentcc) putBalance (stub shim.ChaincodeStubInterface, key string, balance string) error {
-	logger.Infof("put %s : %s", key, balance)
+// putAccount stores the account state with enc, the encoding negotiated by the invoking payload,
+// encrypted with the current AES key once encryption is enabled, see rotate.
+// The first update during a snapshot keeps the previous state, see keepSnapshotState.
+func (t *Paymentcc) putAccount (stub shim.ChaincodeStubInterface, key string, account *schema.Account, enc schema.Encoding) error {
+	logger.Infof("put %s : %d (%s)", key, account.Balance, enc)
 
 	// sign, then encrypt, then put state
-	a := accountInfo{Balance: balance}
-	payload, err := a.ToBytes()
+	payload, err := account.Encode(enc)
 	if err != nil {
 		return errors.WithStack(err)
 	}
+	ring, err := t.newKeyring(stub)
+	if err != nil {
+		return err
+	}
+	if err := t.keepSnapshotState(stub, ring, key); err != nil {
+		return err
+	}
+	if payload, err = ring.seal(key, payload); err != nil {
+		return err
+	}
 
 	err = stub.PutState(key, payload)
 	if err != nil {
@@ -167,51 +290,143 @@ func (t *Paymentcc) putBalance (stub shim.ChaincodeStubInterface, key string, ba
 	return nil
 }
 
-// Transfer from A to B.
+// Transfer from A to B, the accounts are named by their keys or aliases.
 // arg0 is payload
 func (t *Paymentcc) transfer(stub shim.ChaincodeStubInterface, args []string) pb.Response {
 	if len(args) != 1 {
 		return shim.Error("Incorrect number of arguments. Expecting 1")
 	}
 
-	payload_str := args[0]
-	var payload Payload
-	payload.FromBytes([]byte(payload_str))
-
-	// get balance of A and B
-	balanceA, err := t.getBalance(stub, payload.From)
+	payload, err := schema.Decode([]byte(args[0]), schema.Transfer)
 	if err != nil {
-		return shim.Error(errors.WithMessage(err, fmt.Sprintf("get balance for account %s failed.", payload.From)).Error())
+		return shim.Error(fmt.Sprintf("invalid payload, err %+v", err))
+	}
+	// the accounts can be named by their aliases
+	if payload.From, err = t.resolveAccount(stub, payload.From); err != nil {
+		return shim.Error(err.Error())
+	}
+	if payload.To, err = t.resolveAccount(stub, payload.To); err != nil {
+		return shim.Error(err.Error())
+	}
+	if payload.From == payload.To {
+		return shim.Error(fmt.Sprintf("self-transfer on account %s is not allowed", payload.From))
+	}
+	config, err := t.getConfig(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("get chaincode configuration failed, err %+v", err))
+	}
+	if err := checkKYC(stub, config, payload.Amount); err != nil {
+		return shim.Error(fmt.Sprintf("transfer denied, err %+v", err))
+	}
+	now, err := txTime(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("get transaction timestamp failed, err %+v", err))
 	}
-	logger.Infof("before transfer, %s's balance is %d", payload.From, balanceA)
 
-	balanceB, err := t.getBalance(stub, payload.To)
+	cache := t.newAccountCache(stub)
+	receipt, err := t.pay(cache, config, now, payload)
 	if err != nil {
-		return shim.Error(errors.WithMessage(err, fmt.Sprintf("get balance for account %s failed.", payload.To)).Error())
+		return shim.Error(err.Error())
+	}
+	if err := cache.flush(schema.EncodingOf([]byte(args[0]))); err != nil {
+		return shim.Error(err.Error())
+	}
+	// the record lets the transfer be disputed and reversed
+	record := &schema.TransferRecord{ID: stub.GetTxID(), Time: now.Unix(), Receipt: *receipt, Status: schema.Completed}
+	if err := t.putTransferRecord(stub, record); err != nil {
+		return shim.Error(err.Error())
 	}
-	logger.Infof("before transfer, %s's balance is %d", payload.To, balanceB)
 
-	// check if A's balance is enough or not and if YES transfer (A-x, B+x)
-	X, _ := strconv.Atoi(string(payload.Amount))
-	logger.Infof("transfer v% from %s to %s", X, payload.From, payload.To)
+	value, err := receipt.ToBytes()
+	if err != nil {
+		return shim.Error(errors.WithStack(err).Error())
+	}
+	return shim.Success(value)
+}
 
-	balanceA = balanceA - X
-	if balanceA < 0 {
-		return shim.Error(fmt.Sprintf("account %s has not enough balance (%d) to Transfer %d.", args[0], balanceA + X, X))
+// pay moves payload.Amount of payload.Asset from payload.From to payload.To
+// in cache, the other account fields are kept as they are. The fee, if any,
+// is paid by the sender on top of the amount and credited to the fee account;
+// fees are in the default asset and only charged on its transfers. A payment
+// refused by the rules returns a rejectedError and leaves cache unchanged.
+func (t *Paymentcc) pay(cache *accountCache, config *ccConfig, now time.Time, payload *schema.Payload) (*schema.TransferReceipt, error) {
+	keyA, err := t.balanceKey(cache.stub, payload.From, payload.Asset)
+	if err != nil {
+		return nil, err
+	}
+	keyB, err := t.balanceKey(cache.stub, payload.To, payload.Asset)
+	if err != nil {
+		return nil, err
 	}
-	err = t.putBalance(stub, payload.From, strconv.Itoa(balanceA))
+
+	// get accounts of A and B
+	accountA, err := cache.get(keyA)
 	if err != nil {
-		return shim.Error(errors.WithMessage(err, fmt.Sprintf("put balance for account %s failed.", args[0])).Error())
+		return nil, errors.WithMessage(err, fmt.Sprintf("get balance for account %s failed.", payload.From))
+	}
+	if accountA == nil {
+		return nil, missingBalance(payload.From, payload.Asset)
 	}
+	logger.Infof("before transfer, %s's balance is %d", payload.From, accountA.Balance)
 
-	balanceB = balanceB + X
-	t.putBalance(stub, payload.To, strconv.Itoa(balanceB))
+	accountB, err := cache.get(keyB)
 	if err != nil {
-		return shim.Error(errors.WithMessage(err, fmt.Sprintf("put balance for account %s failed.", args[1])).Error())
+		return nil, errors.WithMessage(err, fmt.Sprintf("get balance for account %s failed.", payload.To))
 	}
+	if accountB == nil {
+		return nil, missingBalance(payload.To, payload.Asset)
+	}
+	logger.Infof("before transfer, %s's balance is %d", payload.To, accountB.Balance)
 
-	fmt.Printf("balanceA = %d, balanceB = %d\n", balanceA, balanceB)
-	return shim.Success(nil)
+	X := payload.Amount
+	var fee int64
+	if payload.Asset == "" {
+		fee = config.Fees.Fee(X)
+	}
+	receipt := &schema.TransferReceipt{From: payload.From, To: payload.To, Asset: payload.Asset, Amount: X, Fee: fee}
+	logger.Infof("transfer %d %s from %s to %s, fee %d", X, payload.Asset, payload.From, payload.To, fee)
+
+	// check if A's balance is enough or not and if YES transfer (A-x-fee, B+x, fee account+fee),
+	// compared without adding as X+fee may overflow
+	if fee > accountA.Balance || X > accountA.Balance-fee {
+		return nil, reject("account %s has not enough balance (%d) to Transfer %d with fee %d.", payload.From, accountA.Balance, X, fee)
+	}
+	var feeAccount *schema.Account
+	if fee > 0 {
+		receipt.FeeAccount = config.Fees.Account
+		if feeAccount, err = cache.get(receipt.FeeAccount); err != nil {
+			return nil, errors.WithMessage(err, fmt.Sprintf("get fee account %s failed.", receipt.FeeAccount))
+		}
+		if feeAccount == nil {
+			// the fee account is created by the first fee
+			feeAccount = &schema.Account{}
+		}
+	}
+	if err := accountA.Spend(now, X); err != nil {
+		return nil, reject("account %s: %s", payload.From, err)
+	}
+	if err := checkCredit(payload.To, accountB, X); err != nil {
+		return nil, err
+	}
+	if feeAccount != nil {
+		if err := checkCredit(receipt.FeeAccount, feeAccount, fee); err != nil {
+			return nil, err
+		}
+	}
+
+	accountA.Balance -= X + fee
+	cache.update(keyA, accountA)
+	accountB.Balance += X
+	cache.update(keyB, accountB)
+	cache.flow(accountA.Org, accountB.Org, payload.Asset, X)
+	if feeAccount != nil {
+		feeAccount.Balance += fee
+		cache.update(receipt.FeeAccount, feeAccount)
+		cache.flow(accountA.Org, feeAccount.Org, "", fee)
+	}
+
+	logger.Infof("balanceA = %d, balanceB = %d", accountA.Balance, accountB.Balance)
+	return receipt, nil
 }
 
 func main() {
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/schema"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/msp"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// identity is the creator of the transactions of a testStub, ous and attrs
+// are the organizational units and Fabric CA attributes of its certificate.
+type identity struct {
+	msp   string
+	name  string
+	ous   []string
+	attrs map[string]string
+}
+
+// attrsOID is the certificate extension of the Fabric CA attributes.
+var attrsOID = asn1.ObjectIdentifier{1, 2, 3, 4, 5, 6, 7, 8, 1}
+
+// admin belongs to the admin MSP of the default configuration.
+var admin = identity{msp: "Org1MSP", name: "Admin@org1"}
+
+// serialize returns the msp.SerializedIdentity of id with a self-signed certificate.
+func (id identity) serialize(t *testing.T) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: id.name, OrganizationalUnit: id.ous},
+		NotBefore:    time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(2028, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	if id.attrs != nil {
+		value, err := json.Marshal(map[string]interface{}{"attrs": id.attrs})
+		if err != nil {
+			t.Fatal(err)
+		}
+		template.ExtraExtensions = []pkix.Extension{{Id: attrsOID, Value: value}}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	creator, err := proto.Marshal(&msp.SerializedIdentity{
+		Mspid:   id.msp,
+		IdBytes: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return creator
+}
+
+// testStub runs the chaincode on a shim.MockStub the way a peer endorses a
+// transaction: the writes are buffered, reads see the state before the
+// transaction, and the writes of a failed invocation are dropped.
+type testStub struct {
+	*shim.MockStub
+	t      *testing.T
+	cc     *Paymentcc
+	now    time.Time
+	txs    int
+	fn     string
+	params []string
+	writes map[string][]byte
+	order  []string
+
+	creator   []byte
+	transient map[string][]byte
+}
+
+// newTestStub returns a stub whose transactions are created by admin.
+func newTestStub(t *testing.T) *testStub {
+	cc := &Paymentcc{}
+	s := &testStub{
+		MockStub: shim.NewMockStub("payment_cc", cc),
+		t:        t,
+		cc:       cc,
+		now:      time.Date(2018, 10, 1, 12, 0, 0, 0, time.UTC),
+	}
+	s.as(admin)
+	return s
+}
+
+// as makes id the creator of the next transactions.
+func (s *testStub) as(id identity) {
+	s.creator = id.serialize(s.t)
+}
+
+func (s *testStub) GetCreator() ([]byte, error) {
+	return s.creator, nil
+}
+
+// GetTransient returns s.transient, the transient map of every transaction.
+func (s *testStub) GetTransient() (map[string][]byte, error) {
+	return s.transient, nil
+}
+
+// GetStateByRange reads to the last key when endKey is empty, like the peer
+// does, MockStub returns nothing.
+func (s *testStub) GetStateByRange(startKey, endKey string) (shim.StateQueryIteratorInterface, error) {
+	if endKey == "" {
+		endKey = string(utf8.MaxRune)
+	}
+	return s.MockStub.GetStateByRange(startKey, endKey)
+}
+
+func (s *testStub) GetFunctionAndParameters() (string, []string) {
+	return s.fn, s.params
+}
+
+func (s *testStub) PutState(key string, value []byte) error {
+	if key == "" {
+		return fmt.Errorf("key must not be empty")
+	}
+	if _, ok := s.writes[key]; !ok {
+		s.order = append(s.order, key)
+	}
+	s.writes[key] = value
+	return nil
+}
+
+func (s *testStub) DelState(key string) error {
+	return s.PutState(key, nil)
+}
+
+// invoke runs fn as one transaction at s.now and commits its writes if it succeeds.
+func (s *testStub) invoke(fn string, args ...string) pb.Response {
+	s.txs++
+	txid := fmt.Sprintf("tx%d", s.txs)
+	s.fn, s.params = fn, args
+	s.writes, s.order = map[string][]byte{}, nil
+
+	s.MockTransactionStart(txid)
+	ts, err := ptypes.TimestampProto(s.now)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	s.TxTimestamp = ts
+	res := s.cc.Invoke(s)
+	if res.Status == shim.OK {
+		for _, key := range s.order {
+			if value := s.writes[key]; value != nil {
+				s.MockStub.PutState(key, value)
+			} else {
+				s.MockStub.DelState(key)
+			}
+		}
+	}
+	s.MockTransactionEnd(txid)
+	s.writes, s.order = nil, nil
+	return res
+}
+
+// mustInvoke fails the test if fn does not succeed.
+func (s *testStub) mustInvoke(fn string, args ...string) []byte {
+	s.t.Helper()
+	res := s.invoke(fn, args...)
+	if res.Status != shim.OK {
+		s.t.Fatalf("%s %q failed: %s", fn, args, res.Message)
+	}
+	return res.Payload
+}
+
+// mustFail fails the test if fn succeeds.
+func (s *testStub) mustFail(fn string, args ...string) string {
+	s.t.Helper()
+	res := s.invoke(fn, args...)
+	if res.Status == shim.OK {
+		s.t.Fatalf("%s %q succeeded, want an error", fn, args)
+	}
+	return res.Message
+}
+
+func (s *testStub) payload(p *schema.Payload) string {
+	s.t.Helper()
+	d, err := p.ToBytes()
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return string(d)
+}
+
+func (s *testStub) create(account string, balance int64) {
+	s.t.Helper()
+	s.mustInvoke("create", s.payload(&schema.Payload{To: account, Amount: balance}))
+}
+
+func (s *testStub) balance(account string) int64 {
+	s.t.Helper()
+	var a schema.Account
+	if err := a.FromBytes(s.mustInvoke("query", account)); err != nil {
+		s.t.Fatal(err)
+	}
+	return a.Balance
+}
+
+func (s *testStub) expectBalances(want map[string]int64) {
+	s.t.Helper()
+	for account, balance := range want {
+		if got := s.balance(account); got != balance {
+			s.t.Errorf("balance of %s = %d, want %d", account, got, balance)
+		}
+	}
+}
+
+func TestTransfer(t *testing.T) {
+	s := newTestStub(t)
+	s.create("1", 100)
+	s.create("2", 50)
+
+	s.mustInvoke("transfer", s.payload(&schema.Payload{From: "1", To: "2", Amount: 30}))
+	s.expectBalances(map[string]int64{"1": 70, "2": 80})
+
+	s.mustFail("transfer", s.payload(&schema.Payload{From: "1", To: "2", Amount: 71}))
+	s.mustFail("transfer", s.payload(&schema.Payload{From: "1", To: "3", Amount: 1}))
+	s.expectBalances(map[string]int64{"1": 70, "2": 80})
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strconv"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/schema"
+	"github.com/hyperledger/fabric/core/chaincode/lib/cid"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"github.com/pkg/errors"
+)
+
+// createBatch creates many accounts in one transaction to grow the ledger quickly,
+// admins only. The accounts that already exist are left as they are, so that a
+// resumed populate can send a committed batch again. arg0 is a schema.Batch.
+func (t *Paymentcc) createBatch(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+	if err := t.checkAdmin(stub); err != nil {
+		return shim.Error(fmt.Sprintf("createBatch denied, err %+v", err))
+	}
+
+	batch, err := schema.DecodeBatch([]byte(args[0]))
+	if err != nil {
+		return shim.Error(fmt.Sprintf("invalid batch, err %+v", err))
+	}
+	enc, _ := schema.ParseEncoding(batch.Encoding)
+	org, err := cid.GetMSPID(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("get MSP ID of the creator failed, err %+v", err))
+	}
+
+	skipped := 0
+	for i, size := range batch.Paddings {
+		key := strconv.FormatInt(batch.First+int64(i), 10)
+		existing, err := stub.GetState(key)
+		if err != nil {
+			return shim.Error(errors.WithMessage(errors.WithStack(err), fmt.Sprintf("get account %s failed.", key)).Error())
+		}
+		if len(existing) != 0 {
+			skipped++
+			continue
+		}
+		account := schema.Account{Balance: batch.Amount, Padding: padding(key, size), Org: org}
+		if err := t.putAccount(stub, key, &account, enc); err != nil {
+			return shim.Error(errors.WithMessage(err, fmt.Sprintf("put account %s failed.", key)).Error())
+		}
+	}
+
+	logger.Infof("created accounts %d to %d, %d already existed", batch.First, batch.First+int64(len(batch.Paddings))-1, skipped)
+	return shim.Success(nil)
+}
+
+// padding returns size filler bytes derived from key, the same on every endorser.
+func padding(key string, size int) []byte {
+	if size == 0 {
+		return nil
+	}
+	seed := sha256.Sum256([]byte(key))
+	p := make([]byte, size)
+	for i := 0; i < size; i += len(seed) {
+		copy(p[i:], seed[:])
+	}
+	return p
+}
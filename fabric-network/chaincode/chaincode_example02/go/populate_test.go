@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/schema"
+)
+
+func (s *testStub) batch(b *schema.Batch) string {
+	s.t.Helper()
+	d, err := b.ToBytes()
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return string(d)
+}
+
+func TestCreateBatch(t *testing.T) {
+	s := newTestStub(t)
+	batch := s.batch(&schema.Batch{First: 10, Amount: 100, Paddings: []int{0, 64, 100}, Encoding: "proto"})
+
+	s.as(identity{msp: "Org2MSP", name: "User1@org2"})
+	s.mustFail("createBatch", batch)
+	s.as(admin)
+	s.mustInvoke("createBatch", batch)
+	for i, key := range []string{"10", "11", "12"} {
+		var a schema.Account
+		value := s.State[key]
+		if err := a.FromBytes(value); err != nil {
+			t.Fatalf("account %s: %v", key, err)
+		}
+		if schema.EncodingOf(value) != schema.Proto || a.Balance != 100 || len(a.Padding) != []int{0, 64, 100}[i] {
+			t.Errorf("account %s = %s with balance %d and %d padding bytes", key, schema.EncodingOf(value), a.Balance, len(a.Padding))
+		}
+	}
+
+	// a batch sent again by a resumed populate leaves the existing accounts as they are
+	s.mustInvoke("transfer", s.transfer("10", "11", 40))
+	s.mustInvoke("createBatch", s.batch(&schema.Batch{First: 11, Amount: 100, Paddings: []int{0, 0}}))
+	s.expectBalances(map[string]int64{"10": 60, "11": 140, "12": 100})
+}
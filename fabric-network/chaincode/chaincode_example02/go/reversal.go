@@ -0,0 +1,465 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/schema"
+	"github.com/hyperledger/fabric/core/chaincode/lib/cid"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"github.com/pkg/errors"
+)
+
+// Composite key object types of the reversal workflow: the record of every
+// transfer by transaction id, the history entries of an account by
+// transaction id and the open debts of an account by transfer id.
+const (
+	transferRecordType = "transfer"
+	historyType        = "history"
+	debtType           = "debt"
+)
+
+// Chaincode events of the reversal workflow.
+const (
+	disputeEvent   = "transferDisputed"
+	reversalEvent  = "transferReversed"
+	debtEvent      = "debtOpened"
+	repaymentEvent = "debtRepaid"
+)
+
+func (t *Paymentcc) putTransferRecord(stub shim.ChaincodeStubInterface, record *schema.TransferRecord) error {
+	key, err := stub.CreateCompositeKey(transferRecordType, []string{record.ID})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	value, err := record.ToBytes()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := stub.PutState(key, value); err != nil {
+		return errors.WithMessage(err, fmt.Sprintf("put record of transfer %s failed.", record.ID))
+	}
+	return nil
+}
+
+func (t *Paymentcc) getTransferRecord(stub shim.ChaincodeStubInterface, id string) (*schema.TransferRecord, error) {
+	key, err := stub.CreateCompositeKey(transferRecordType, []string{id})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	value, err := stub.GetState(key)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if value == nil {
+		return nil, errors.Errorf("transfer %s does not exist", id)
+	}
+	var record schema.TransferRecord
+	if err := record.FromBytes(value); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// putHistory adds event on record, moving amount, to the history of the
+// sender and the receiver.
+func (t *Paymentcc) putHistory(stub shim.ChaincodeStubInterface, record *schema.TransferRecord, event string, time, amount int64) error {
+	r := record.Receipt
+	for _, side := range [][2]string{{r.From, r.To}, {r.To, r.From}} {
+		entry := schema.HistoryEntry{TxID: stub.GetTxID(), Time: time, Event: event, Transfer: record.ID,
+			Counterparty: side[1], Asset: r.Asset, Amount: amount}
+		key, err := stub.CreateCompositeKey(historyType, []string{side[0], stub.GetTxID()})
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		value, err := json.Marshal(&entry)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if err := stub.PutState(key, value); err != nil {
+			return errors.WithMessage(err, fmt.Sprintf("put history of account %s failed.", side[0]))
+		}
+	}
+	return nil
+}
+
+// checkParty fails unless the transaction creator belongs to an admin MSP
+// or to the org of the sender or of the receiver of record.
+func (t *Paymentcc) checkParty(stub shim.ChaincodeStubInterface, record *schema.TransferRecord) error {
+	config, err := t.getConfig(stub)
+	if err != nil {
+		return errors.WithMessage(err, "get chaincode configuration failed.")
+	}
+	var parties []string
+	for _, account := range []string{record.Receipt.From, record.Receipt.To} {
+		balance, err := t.getAssetAccount(stub, account, record.Receipt.Asset)
+		if err != nil {
+			return err
+		}
+		if balance.Org != "" {
+			parties = append(parties, balance.Org)
+		}
+	}
+	return checkCreatorMSP(stub, "an admin or a party's", config.Admins, parties)
+}
+
+// dispute marks the transfer with the transaction id in arg0 as disputed, the
+// optional arg1 is the reason. Only a completed transfer can be disputed, by
+// the org of its sender or receiver or an admin.
+func (t *Paymentcc) dispute(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 && len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 1 (txid) or 2 (txid, reason)")
+	}
+	record, err := t.getTransferRecord(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := t.checkParty(stub, record); err != nil {
+		return shim.Error(fmt.Sprintf("dispute denied, err %+v", err))
+	}
+	if record.Status != schema.Completed {
+		return shim.Error(fmt.Sprintf("transfer %s is %s, only a completed transfer can be disputed", record.ID, record.Status))
+	}
+	now, err := txTime(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("get transaction timestamp failed, err %+v", err))
+	}
+	mspID, err := cid.GetMSPID(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("get MSP ID of the creator failed, err %+v", err))
+	}
+
+	record.Status = schema.Disputed
+	record.DisputedBy = mspID
+	if len(args) == 2 {
+		record.Reason = args[1]
+	}
+	if err := t.putTransferRecord(stub, record); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := t.putHistory(stub, record, schema.EventDisputed, now.Unix(), record.Receipt.Amount); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := t.setEvent(stub, disputeEvent, record); err != nil {
+		return shim.Error(err.Error())
+	}
+	return recordResponse(record)
+}
+
+// approveReversal settles the disputed transfer with the transaction id in
+// arg0, admins only. The amount moves back from the receiver to the sender
+// if the receiver's balance covers it, otherwise a debt of the receiver is
+// opened. The fee is not refunded. The response is the updated record.
+func (t *Paymentcc) approveReversal(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1 (txid)")
+	}
+	if err := t.checkAdmin(stub); err != nil {
+		return shim.Error(fmt.Sprintf("approveReversal denied, err %+v", err))
+	}
+	record, err := t.getTransferRecord(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if record.Status != schema.Disputed {
+		return shim.Error(fmt.Sprintf("transfer %s is %s, only a disputed transfer can be reversed", record.ID, record.Status))
+	}
+	now, err := txTime(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("get transaction timestamp failed, err %+v", err))
+	}
+
+	r := record.Receipt
+	cache := t.newAccountCache(stub)
+	keyFrom, err := t.balanceKey(stub, r.From, r.Asset)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	keyTo, err := t.balanceKey(stub, r.To, r.Asset)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	from, err := cache.get(keyFrom)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	to, err := cache.get(keyTo)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if from == nil || to == nil {
+		return shim.Error(fmt.Sprintf("transfer %s: an account no longer exists", record.ID))
+	}
+
+	if to.Balance < r.Amount {
+		debt := &schema.Debt{Transfer: record.ID, Time: now.Unix(), Debtor: r.To, Creditor: r.From, Asset: r.Asset, Amount: r.Amount}
+		if err := t.putDebt(stub, debt); err != nil {
+			return shim.Error(err.Error())
+		}
+		record.Status = schema.Indebted
+		if err := t.putTransferRecord(stub, record); err != nil {
+			return shim.Error(err.Error())
+		}
+		if err := t.putHistory(stub, record, schema.EventDebt, now.Unix(), record.Receipt.Amount); err != nil {
+			return shim.Error(err.Error())
+		}
+		logger.Infof("transfer %s: %s cannot pay back %d, debt opened", record.ID, r.To, r.Amount)
+		if err := t.setEvent(stub, debtEvent, debt); err != nil {
+			return shim.Error(err.Error())
+		}
+		return recordResponse(record)
+	}
+
+	if err := checkCredit(r.From, from, r.Amount); err != nil {
+		return shim.Error(err.Error())
+	}
+	to.Balance -= r.Amount
+	cache.update(keyTo, to)
+	from.Balance += r.Amount
+	cache.update(keyFrom, from)
+	cache.flow(to.Org, from.Org, r.Asset, r.Amount)
+	if err := cache.flushStored(); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	record.Status = schema.Reversed
+	if err := t.putTransferRecord(stub, record); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := t.putHistory(stub, record, schema.EventReversed, now.Unix(), record.Receipt.Amount); err != nil {
+		return shim.Error(err.Error())
+	}
+	logger.Infof("transfer %s reversed: %d back from %s to %s", record.ID, r.Amount, r.To, r.From)
+	if err := t.setEvent(stub, reversalEvent, record); err != nil {
+		return shim.Error(err.Error())
+	}
+	return recordResponse(record)
+}
+
+// repayDebt pays back the debt opened by the reversal of the transfer with
+// the transaction id in arg0, arg1 of it or, by default, what is left to
+// repay, by the org of the debtor or an admin. The amount moves from the
+// debtor to the creditor, the debt is closed and the transfer reversed once
+// it is repaid. The response is the updated debt.
+func (t *Paymentcc) repayDebt(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 && len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 1 (txid) or 2 (txid, amount)")
+	}
+	record, err := t.getTransferRecord(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if record.Status != schema.Indebted {
+		return shim.Error(fmt.Sprintf("transfer %s is %s, only an indebted transfer has a debt to repay", record.ID, record.Status))
+	}
+	r := record.Receipt
+	debt, err := t.getDebt(stub, r.To, record.ID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	amount := debt.Owed()
+	if len(args) == 2 {
+		if amount, err = strconv.ParseInt(args[1], 10, 64); err != nil || amount <= 0 || amount > debt.Owed() {
+			return shim.Error(fmt.Sprintf("amount must be an integer in [1, %d], got %s", debt.Owed(), args[1]))
+		}
+	}
+	now, err := txTime(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("get transaction timestamp failed, err %+v", err))
+	}
+
+	cache := t.newAccountCache(stub)
+	keyDebtor, err := t.balanceKey(stub, debt.Debtor, debt.Asset)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	keyCreditor, err := t.balanceKey(stub, debt.Creditor, debt.Asset)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	debtor, err := cache.get(keyDebtor)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	creditor, err := cache.get(keyCreditor)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if debtor == nil || creditor == nil {
+		return shim.Error(fmt.Sprintf("debt of transfer %s: an account no longer exists", record.ID))
+	}
+	config, err := t.getConfig(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("get chaincode configuration failed, err %+v", err))
+	}
+	if err := checkCreatorMSP(stub, "an admin or the debtor's", config.Admins, []string{debtor.Org}); err != nil {
+		return shim.Error(fmt.Sprintf("repayDebt denied, err %+v", err))
+	}
+	if err := checkKYC(stub, config, amount); err != nil {
+		return shim.Error(fmt.Sprintf("repayDebt denied, err %+v", err))
+	}
+	if debtor.Balance < amount {
+		return shim.Error(fmt.Sprintf("account %s has not enough balance (%d) to repay %d.", debt.Debtor, debtor.Balance, amount))
+	}
+	if err := checkCredit(debt.Creditor, creditor, amount); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	debtor.Balance -= amount
+	cache.update(keyDebtor, debtor)
+	creditor.Balance += amount
+	cache.update(keyCreditor, creditor)
+	cache.flow(debtor.Org, creditor.Org, debt.Asset, amount)
+	if err := cache.flushStored(); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	debt.Repaid += amount
+	if debt.Owed() == 0 {
+		if err := t.delDebt(stub, debt); err != nil {
+			return shim.Error(err.Error())
+		}
+		record.Status = schema.Reversed
+		if err := t.putTransferRecord(stub, record); err != nil {
+			return shim.Error(err.Error())
+		}
+	} else if err := t.putDebt(stub, debt); err != nil {
+		return shim.Error(err.Error())
+	}
+	if err := t.putHistory(stub, record, schema.EventRepaid, now.Unix(), amount); err != nil {
+		return shim.Error(err.Error())
+	}
+	logger.Infof("transfer %s: %s repaid %d of its debt, %d left", record.ID, debt.Debtor, amount, debt.Owed())
+	if err := t.setEvent(stub, repaymentEvent, debt); err != nil {
+		return shim.Error(err.Error())
+	}
+	value, err := json.Marshal(debt)
+	if err != nil {
+		return shim.Error(errors.WithStack(err).Error())
+	}
+	return shim.Success(value)
+}
+
+func debtKey(stub shim.ChaincodeStubInterface, debtor, transfer string) (string, error) {
+	key, err := stub.CreateCompositeKey(debtType, []string{debtor, transfer})
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return key, nil
+}
+
+func (t *Paymentcc) getDebt(stub shim.ChaincodeStubInterface, debtor, transfer string) (*schema.Debt, error) {
+	key, err := debtKey(stub, debtor, transfer)
+	if err != nil {
+		return nil, err
+	}
+	value, err := stub.GetState(key)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if value == nil {
+		return nil, errors.Errorf("%s has no debt for transfer %s", debtor, transfer)
+	}
+	var debt schema.Debt
+	if err := debt.FromBytes(value); err != nil {
+		return nil, err
+	}
+	return &debt, nil
+}
+
+func (t *Paymentcc) delDebt(stub shim.ChaincodeStubInterface, debt *schema.Debt) error {
+	key, err := debtKey(stub, debt.Debtor, debt.Transfer)
+	if err != nil {
+		return err
+	}
+	if err := stub.DelState(key); err != nil {
+		return errors.WithMessage(err, fmt.Sprintf("delete debt of %s failed.", debt.Debtor))
+	}
+	return nil
+}
+
+func (t *Paymentcc) putDebt(stub shim.ChaincodeStubInterface, debt *schema.Debt) error {
+	key, err := debtKey(stub, debt.Debtor, debt.Transfer)
+	if err != nil {
+		return err
+	}
+	value, err := json.Marshal(debt)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := stub.PutState(key, value); err != nil {
+		return errors.WithMessage(err, fmt.Sprintf("put debt of %s failed.", debt.Debtor))
+	}
+	return nil
+}
+
+// setEvent sets v, marshalled as JSON, as the chaincode event name.
+func (t *Paymentcc) setEvent(stub shim.ChaincodeStubInterface, name string, v interface{}) error {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(stub.SetEvent(name, value))
+}
+
+// recordResponse returns the updated record as the response.
+func recordResponse(record *schema.TransferRecord) pb.Response {
+	value, err := record.ToBytes()
+	if err != nil {
+		return shim.Error(errors.WithStack(err).Error())
+	}
+	return shim.Success(value)
+}
+
+// transferRecord returns the schema.TransferRecord of the transaction id in arg0.
+func (t *Paymentcc) transferRecord(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1 (txid)")
+	}
+	record, err := t.getTransferRecord(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return recordResponse(record)
+}
+
+// history returns the JSON array of the schema.HistoryEntry of the account in arg0.
+func (t *Paymentcc) history(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1 (account)")
+	}
+	return t.listJSON(stub, historyType, args)
+}
+
+// debts returns the JSON array of the open schema.Debt of the account in arg0.
+func (t *Paymentcc) debts(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1 (account)")
+	}
+	return t.listJSON(stub, debtType, args)
+}
+
+// listJSON returns the JSON array of the values under the partial composite key.
+func (t *Paymentcc) listJSON(stub shim.ChaincodeStubInterface, objectType string, attrs []string) pb.Response {
+	iter, err := stub.GetStateByPartialCompositeKey(objectType, attrs)
+	if err != nil {
+		return shim.Error(errors.WithStack(err).Error())
+	}
+	defer iter.Close()
+
+	values := []json.RawMessage{}
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return shim.Error(errors.WithStack(err).Error())
+		}
+		values = append(values, kv.Value)
+	}
+	value, err := json.Marshal(values)
+	if err != nil {
+		return shim.Error(errors.WithStack(err).Error())
+	}
+	return shim.Success(value)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/schema"
+)
+
+// txID returns the transaction id of the last invocation.
+func (s *testStub) txID() string {
+	return fmt.Sprintf("tx%d", s.txs)
+}
+
+func (s *testStub) record(fn string, args ...string) *schema.TransferRecord {
+	s.t.Helper()
+	var record schema.TransferRecord
+	if err := record.FromBytes(s.mustInvoke(fn, args...)); err != nil {
+		s.t.Fatal(err)
+	}
+	return &record
+}
+
+func (s *testStub) debts(account string) []schema.Debt {
+	s.t.Helper()
+	var debts []schema.Debt
+	if err := json.Unmarshal(s.mustInvoke("debts", account), &debts); err != nil {
+		s.t.Fatal(err)
+	}
+	return debts
+}
+
+func TestReversal(t *testing.T) {
+	org2 := identity{msp: "Org2MSP", name: "User1@org2"}
+	org3 := identity{msp: "Org3MSP", name: "User1@org3"}
+	s := newTestStub(t)
+	s.create("1", 100)
+	s.as(org2)
+	s.create("2", 1)
+	s.as(org3)
+	s.create("3", 1)
+	s.as(admin)
+
+	s.mustInvoke("transfer", s.transfer("1", "2", 50))
+	id := s.txID()
+	s.mustInvoke("transfer", s.transfer("2", "3", 40))
+
+	// a transfer is disputed by its parties and reversed by an admin
+	s.as(org3)
+	s.mustFail("dispute", id, "mistake")
+	s.as(org2)
+	if record := s.record("dispute", id, "mistake"); record.Status != schema.Disputed || record.DisputedBy != "Org2MSP" {
+		t.Errorf("disputed record = %+v", *record)
+	}
+	s.mustFail("approveReversal", id)
+	s.as(admin)
+	if record := s.record("approveReversal", id); record.Status != schema.Indebted {
+		t.Errorf("record reversed without funds = %+v, want %s", *record, schema.Indebted)
+	}
+	s.expectBalances(map[string]int64{"1": 50, "2": 11, "3": 41})
+	if debts := s.debts("2"); len(debts) != 1 || debts[0].Owed() != 50 || debts[0].Creditor != "1" {
+		t.Fatalf("debts of 2 = %+v, want 50 owed to 1", debts)
+	}
+
+	// the debt is repaid by the debtor's org, in parts
+	s.as(org3)
+	s.mustFail("repayDebt", id, "10")
+	s.as(org2)
+	s.mustFail("repayDebt", id, "51")
+	s.mustFail("repayDebt", id, "12")
+	s.mustInvoke("repayDebt", id, "10")
+	if debts := s.debts("2"); len(debts) != 1 || debts[0].Owed() != 40 {
+		t.Errorf("debts of 2 after repaying 10 = %+v, want 40 owed", debts)
+	}
+	s.as(admin)
+	s.mustInvoke("transfer", s.transfer("3", "2", 40))
+	s.as(org2)
+	s.mustInvoke("repayDebt", id)
+	s.expectBalances(map[string]int64{"1": 100, "2": 1, "3": 1})
+	if debts := s.debts("2"); len(debts) != 0 {
+		t.Errorf("debts of 2 once repaid = %+v, want none", debts)
+	}
+	if record := s.record("transferRecord", id); record.Status != schema.Reversed {
+		t.Errorf("record once repaid = %+v, want %s", *record, schema.Reversed)
+	}
+	s.mustFail("repayDebt", id)
+
+	var history []schema.HistoryEntry
+	if err := json.Unmarshal(s.mustInvoke("history", "1"), &history); err != nil {
+		t.Fatal(err)
+	}
+	// the entries are ordered by transaction id
+	var events []string
+	for _, h := range history {
+		events = append(events, fmt.Sprintf("%s %d", h.Event, h.Amount))
+	}
+	sort.Strings(events)
+	if got, want := fmt.Sprint(events), "[debt 50 disputed 50 repaid 10 repaid 40]"; got != want {
+		t.Errorf("history of 1 = %s, want %s", got, want)
+	}
+}
+
+func TestReversalKeepsEncoding(t *testing.T) {
+	s := newTestStub(t)
+	d, err := (&schema.Payload{To: "1", Amount: 100}).Encode(schema.Proto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.mustInvoke("create", string(d))
+	s.create("2", 1)
+	// the transfer stores both accounts with the encoding of its payload
+	if d, err = (&schema.Payload{From: "2", To: "1", Amount: 1}).Encode(schema.Proto); err != nil {
+		t.Fatal(err)
+	}
+	s.mustInvoke("transfer", string(d))
+	id := s.txID()
+	s.mustInvoke("dispute", id)
+	if record := s.record("approveReversal", id); record.Status != schema.Reversed {
+		t.Errorf("record = %+v, want %s", *record, schema.Reversed)
+	}
+	s.expectBalances(map[string]int64{"1": 100, "2": 1})
+	if enc := schema.EncodingOf(s.State["1"]); enc != schema.Proto {
+		t.Errorf("account 1 is stored with %s after the reversal, want %s", enc, schema.Proto)
+	}
+}
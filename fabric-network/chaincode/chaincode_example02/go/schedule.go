@@ -0,0 +1,300 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/schema"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"github.com/pkg/errors"
+)
+
+// Composite key object types of the scheduled payments. Every pending
+// payment also has an entry (due time, id) in the due index, which tick
+// scans in time order.
+const (
+	scheduleType    = "schedule"
+	scheduleDueType = "scheduleDue"
+
+	// tickEvent is the chaincode event set by tick
+	tickEvent = "scheduledPayments"
+
+	// defaultTickLimit and maxTickLimit bound the executions of one tick
+	defaultTickLimit = 100
+	maxTickLimit     = 1000
+)
+
+func dueKey(stub shim.ChaincodeStubInterface, next int64, id string) (string, error) {
+	key, err := stub.CreateCompositeKey(scheduleDueType, []string{fmt.Sprintf("%020d", next), id})
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return key, nil
+}
+
+// schedulePayment registers a standing order, arg0 is the schema.Schedule, e.g.
+// {"version":2,"from":"1","to":"2","amount":10,"first":1546300800,"interval":86400,"count":12}
+// The accounts are named by their keys or aliases. It returns the id of the
+// scheduled payment, the transaction id.
+func (t *Paymentcc) schedulePayment(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1")
+	}
+	schedule, err := schema.DecodeSchedule([]byte(args[0]))
+	if err != nil {
+		return shim.Error(fmt.Sprintf("invalid schedule, err %+v", err))
+	}
+	// the accounts can be named by their aliases, the schedule keeps their keys
+	if schedule.From, err = t.resolveAccount(stub, schedule.From); err != nil {
+		return shim.Error(err.Error())
+	}
+	if schedule.To, err = t.resolveAccount(stub, schedule.To); err != nil {
+		return shim.Error(err.Error())
+	}
+	if schedule.From == schedule.To {
+		return shim.Error(fmt.Sprintf("self-transfer on account %s is not allowed", schedule.From))
+	}
+	if schedule.Asset != "" {
+		if _, err := t.assetBalanceKey(stub, schedule.From, schedule.Asset); err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+	// tick executes the payments as the ticker, the KYC level of the user
+	// scheduling them is checked here
+	config, err := t.getConfig(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("get chaincode configuration failed, err %+v", err))
+	}
+	if err := checkKYC(stub, config, schedule.Amount); err != nil {
+		return shim.Error(fmt.Sprintf("schedulePayment denied, err %+v", err))
+	}
+
+	payment := &schema.ScheduledPayment{ID: stub.GetTxID(), Schedule: *schedule, Next: schedule.First}
+	if err := t.putScheduledPayment(stub, payment, 0); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	logger.Infof("scheduled payment %s: %d x %d from %s to %s from %d every %ds",
+		payment.ID, schedule.Count, schedule.Amount, schedule.From, schedule.To, schedule.First, schedule.Interval)
+	return shim.Success([]byte(payment.ID))
+}
+
+// cancelSchedule stops the scheduled payment with the id in arg0.
+func (t *Paymentcc) cancelSchedule(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1 (id)")
+	}
+	payment, err := t.getScheduledPayment(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if payment.Next == 0 {
+		return shim.Error(fmt.Sprintf("scheduled payment %s is already finished", payment.ID))
+	}
+
+	due := payment.Next
+	payment.Next = 0
+	payment.Cancelled = true
+	if err := t.putScheduledPayment(stub, payment, due); err != nil {
+		return shim.Error(err.Error())
+	}
+	return shim.Success(nil)
+}
+
+// scheduledPayment returns the schema.ScheduledPayment with the id in arg0.
+func (t *Paymentcc) scheduledPayment(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 {
+		return shim.Error("Incorrect number of arguments. Expecting 1 (id)")
+	}
+	payment, err := t.getScheduledPayment(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	value, err := payment.ToBytes()
+	if err != nil {
+		return shim.Error(errors.WithStack(err).Error())
+	}
+	return shim.Success(value)
+}
+
+// tick executes the scheduled payments due at the transaction timestamp, at
+// most arg0 (default 100) of them, in due time order. A payment refused by
+// the payment rules, e.g. for insufficient funds, is recorded as failed and
+// the schedule moves on. Each payment executes at most one occurrence per
+// tick, missed occurrences are caught up by the next ticks.
+// An executed occurrence is recorded as a transfer under its
+// schema.ExecutionTransferID. tick returns a schema.TickResult, also set as
+// the scheduledPayments event.
+func (t *Paymentcc) tick(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) > 1 {
+		return shim.Error("Incorrect number of arguments. Expecting at most 1 (limit)")
+	}
+	limit := defaultTickLimit
+	if len(args) == 1 {
+		var err error
+		if limit, err = strconv.Atoi(args[0]); err != nil || limit < 1 || limit > maxTickLimit {
+			return shim.Error(fmt.Sprintf("tick limit must be an integer in [1, %d], got %s", maxTickLimit, args[0]))
+		}
+	}
+
+	config, err := t.getConfig(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("get chaincode configuration failed, err %+v", err))
+	}
+	if err := checkCreatorMSP(stub, "an admin or ticker", config.Admins, config.Tickers); err != nil {
+		return shim.Error(fmt.Sprintf("tick denied, err %+v", err))
+	}
+	now, err := txTime(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("get transaction timestamp failed, err %+v", err))
+	}
+
+	due, err := t.duePayments(stub, now.Unix(), limit)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("list due payments failed, err %+v", err))
+	}
+
+	result := &schema.TickResult{Time: now.Unix(), Executions: []schema.Execution{}}
+	if len(due) > limit {
+		due, result.More = due[:limit], true
+	}
+	cache := t.newAccountCache(stub)
+	for _, id := range due {
+		payment, err := t.getScheduledPayment(stub, id)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		execution := schema.Execution{ID: id, Occurrence: payment.Occurrence(), Due: payment.Next}
+
+		receipt, err := t.pay(cache, config, now, payment.Schedule.Payload())
+		switch {
+		case err == nil:
+			// the record lets the execution be disputed and reversed like a transfer
+			execution.Receipt = receipt
+			execution.Transfer = schema.ExecutionTransferID(id, execution.Occurrence)
+			record := &schema.TransferRecord{ID: execution.Transfer, Time: now.Unix(), Receipt: *receipt, Status: schema.Completed}
+			if err := t.putTransferRecord(stub, record); err != nil {
+				return shim.Error(err.Error())
+			}
+			payment.Executed++
+			result.Executed++
+		case isRejected(err):
+			execution.Error = err.Error()
+			payment.Failed++
+			payment.LastError = err.Error()
+			result.Failed++
+		default:
+			return shim.Error(fmt.Sprintf("scheduled payment %s failed, err %+v", id, err))
+		}
+		if execution.Error != "" {
+			logger.Infof("scheduled payment %s occurrence %d failed: %s", id, execution.Occurrence, execution.Error)
+		} else {
+			logger.Infof("scheduled payment %s occurrence %d executed", id, execution.Occurrence)
+		}
+
+		payment.Advance()
+		if err := t.putScheduledPayment(stub, payment, execution.Due); err != nil {
+			return shim.Error(err.Error())
+		}
+		result.Executions = append(result.Executions, execution)
+	}
+
+	if err := cache.flushStored(); err != nil {
+		return shim.Error(err.Error())
+	}
+	value, err := result.ToBytes()
+	if err != nil {
+		return shim.Error(errors.WithStack(err).Error())
+	}
+	if err := stub.SetEvent(tickEvent, value); err != nil {
+		return shim.Error(errors.WithStack(err).Error())
+	}
+	return shim.Success(value)
+}
+
+// duePayments returns the ids of the payments due at or before now in due
+// time order, at most limit+1 of them so that the caller knows if more are due.
+func (t *Paymentcc) duePayments(stub shim.ChaincodeStubInterface, now int64, limit int) ([]string, error) {
+	iter, err := stub.GetStateByPartialCompositeKey(scheduleDueType, []string{})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer iter.Close()
+
+	var ids []string
+	for iter.HasNext() && len(ids) <= limit {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		_, attrs, err := stub.SplitCompositeKey(kv.Key)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		next, err := strconv.ParseInt(attrs[0], 10, 64)
+		if err != nil {
+			return nil, errors.Errorf("malformed due index key %q", kv.Key)
+		}
+		if next > now {
+			break
+		}
+		ids = append(ids, attrs[1])
+	}
+	return ids, nil
+}
+
+func (t *Paymentcc) getScheduledPayment(stub shim.ChaincodeStubInterface, id string) (*schema.ScheduledPayment, error) {
+	key, err := stub.CreateCompositeKey(scheduleType, []string{id})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	value, err := stub.GetState(key)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if value == nil {
+		return nil, errors.Errorf("scheduled payment %s does not exist", id)
+	}
+	var payment schema.ScheduledPayment
+	if err := payment.FromBytes(value); err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// putScheduledPayment stores payment and moves its due index entry from
+// previous, 0 for a new payment, to payment.Next.
+func (t *Paymentcc) putScheduledPayment(stub shim.ChaincodeStubInterface, payment *schema.ScheduledPayment, previous int64) error {
+	key, err := stub.CreateCompositeKey(scheduleType, []string{payment.ID})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	value, err := payment.ToBytes()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := stub.PutState(key, value); err != nil {
+		return errors.WithMessage(err, fmt.Sprintf("put scheduled payment %s failed.", payment.ID))
+	}
+
+	if previous != 0 {
+		key, err := dueKey(stub, previous, payment.ID)
+		if err != nil {
+			return err
+		}
+		if err := stub.DelState(key); err != nil {
+			return errors.WithMessage(err, fmt.Sprintf("delete due entry of scheduled payment %s failed.", payment.ID))
+		}
+	}
+	if payment.Next != 0 {
+		key, err := dueKey(stub, payment.Next, payment.ID)
+		if err != nil {
+			return err
+		}
+		if err := stub.PutState(key, []byte{0}); err != nil {
+			return errors.WithMessage(err, fmt.Sprintf("put due entry of scheduled payment %s failed.", payment.ID))
+		}
+	}
+	return nil
+}
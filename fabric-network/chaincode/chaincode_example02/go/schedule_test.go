@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/schema"
+)
+
+func (s *testStub) tick() *schema.TickResult {
+	s.t.Helper()
+	var result schema.TickResult
+	if err := result.FromBytes(s.mustInvoke("tick")); err != nil {
+		s.t.Fatal(err)
+	}
+	return &result
+}
+
+func TestScheduledPayments(t *testing.T) {
+	s := newTestStub(t)
+	// account 1 stores its balance with protobuf, tick must keep it
+	d, err := (&schema.Payload{To: "1", Amount: 25}).Encode(schema.Proto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.mustInvoke("create", string(d))
+	s.create("2", 1)
+
+	schedule := &schema.Schedule{From: "1", To: "2", Amount: 10, First: s.now.Unix(), Interval: 3600, Count: 3}
+	d, err = schedule.ToBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := string(s.mustInvoke("schedulePayment", string(d)))
+
+	s.as(identity{msp: "Org2MSP", name: "User1@org2"})
+	s.mustFail("tick")
+	s.as(admin)
+
+	if result := s.tick(); result.Executed != 1 || result.Failed != 0 || result.Executions[0].ID != id {
+		t.Errorf("first tick = %+v, want the first occurrence executed", *result)
+	}
+	// nothing is due until the next interval
+	if result := s.tick(); len(result.Executions) != 0 {
+		t.Errorf("tick before the next occurrence = %+v, want no execution", *result)
+	}
+	s.now = s.now.Add(time.Hour)
+	s.tick()
+	s.now = s.now.Add(time.Hour)
+	if result := s.tick(); result.Failed != 1 || result.Executions[0].Error == "" {
+		t.Errorf("tick without funds = %+v, want a failed occurrence", *result)
+	}
+	s.expectBalances(map[string]int64{"1": 5, "2": 21})
+	if enc := schema.EncodingOf(s.State["1"]); enc != schema.Proto {
+		t.Errorf("account 1 is stored with %s after tick, want %s", enc, schema.Proto)
+	}
+
+	var payment schema.ScheduledPayment
+	if err := payment.FromBytes(s.mustInvoke("scheduledPayment", id)); err != nil {
+		t.Fatal(err)
+	}
+	if payment.Executed != 2 || payment.Failed != 1 || payment.Next != 0 {
+		t.Errorf("finished payment = %+v", payment)
+	}
+	s.mustFail("cancelSchedule", id)
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/schema"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"github.com/pkg/errors"
+)
+
+// Composite key object types of the interbank settlement. A single counter
+// per org pair would make every cross-org transfer conflict with the others,
+// so each transaction adds its own record (time, lo, hi, asset, txID) holding
+// the net amount paid by the accounts of org lo to the accounts of org hi,
+// and settle nets and deletes them. Records are ordered by the transaction
+// time, so that settle reads the records of its window only and the
+// transfers made after the window do not conflict with it.
+const (
+	positionType   = "position"
+	settlementType = "settlement"
+	// the lastSettlement key holds the id of the latest settlement
+	lastSettlementType = "lastSettlement"
+
+	// defaultSettleLimit and maxSettleLimit bound the position records read
+	// by one settle or positions
+	defaultSettleLimit = 1000
+	maxSettleLimit     = 10000
+)
+
+// putPosition adds the position record of the current transaction for pair and asset.
+func (t *Paymentcc) putPosition(stub shim.ChaincodeStubInterface, pair [2]string, asset string, amount int64) error {
+	now, err := txTime(stub)
+	if err != nil {
+		return err
+	}
+	key, err := stub.CreateCompositeKey(positionType, []string{fmt.Sprintf("%020d", now.Unix()), pair[0], pair[1], asset, stub.GetTxID()})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := stub.PutState(key, []byte(strconv.FormatInt(amount, 10))); err != nil {
+		return errors.WithMessage(err, fmt.Sprintf("put position %s/%s failed.", pair[0], pair[1]))
+	}
+	return nil
+}
+
+// openPositions sums the position records made before to, optionally of one
+// org pair, and returns the flows per pair and asset and the keys of the
+// records. It reads at most limit records and reports if more are left.
+func (t *Paymentcc) openPositions(stub shim.ChaincodeStubInterface, to int64, orgs []string, limit int) (map[[2]string]map[string]int64, []string, bool, error) {
+	iter, err := stub.GetStateByPartialCompositeKey(positionType, []string{})
+	if err != nil {
+		return nil, nil, false, errors.WithStack(err)
+	}
+	defer iter.Close()
+
+	flows := map[[2]string]map[string]int64{}
+	var keys []string
+	for read := 0; iter.HasNext(); read++ {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, nil, false, errors.WithStack(err)
+		}
+		_, attrs, err := stub.SplitCompositeKey(kv.Key)
+		if err != nil {
+			return nil, nil, false, errors.WithStack(err)
+		}
+		if len(attrs) != 5 {
+			return nil, nil, false, errors.Errorf("malformed position record %q", kv.Key)
+		}
+		made, err := strconv.ParseInt(attrs[0], 10, 64)
+		if err != nil {
+			return nil, nil, false, errors.Errorf("malformed position record %q", kv.Key)
+		}
+		if made >= to {
+			break
+		}
+		if read == limit {
+			return flows, keys, true, nil
+		}
+		pair := [2]string{attrs[1], attrs[2]}
+		if len(orgs) == 2 && pair != [2]string{orgs[0], orgs[1]} {
+			continue
+		}
+		amount, err := strconv.ParseInt(string(kv.Value), 10, 64)
+		if err != nil {
+			return nil, nil, false, errors.Errorf("malformed position record %q", kv.Key)
+		}
+		if flows[pair] == nil {
+			flows[pair] = map[string]int64{}
+		}
+		flows[pair][attrs[3]] += amount
+		keys = append(keys, kv.Key)
+	}
+	return flows, keys, false, nil
+}
+
+// positionArgs checks the optional org pair of positions.
+func positionArgs(args []string) ([]string, error) {
+	switch len(args) {
+	case 0:
+		return []string{}, nil
+	case 2:
+		if args[0] == args[1] {
+			return nil, errors.Errorf("expecting two different orgs, got %s twice", args[0])
+		}
+		if args[1] < args[0] {
+			return []string{args[1], args[0]}, nil
+		}
+		return args, nil
+	default:
+		return nil, errors.New("Incorrect number of arguments. Expecting 0 or 2 (org, org)")
+	}
+}
+
+// settleLimit parses the optional limit of settle and positions.
+func settleLimit(args []string) (int, error) {
+	if len(args) == 0 {
+		return defaultSettleLimit, nil
+	}
+	limit, err := strconv.Atoi(args[0])
+	if err != nil || limit < 1 || limit > maxSettleLimit {
+		return 0, errors.Errorf("limit must be an integer in [1, %d], got %s", maxSettleLimit, args[0])
+	}
+	return limit, nil
+}
+
+// positions returns the open net positions at the transaction time, of every
+// org pair or of the pair in arg0 and arg1, as a schema.Settlement without
+// id: the settlement of the window ending now. At most 1000 records are read,
+// More is set if there are more.
+func (t *Paymentcc) positions(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	orgs, err := positionArgs(args)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	now, err := txTime(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("get transaction timestamp failed, err %+v", err))
+	}
+	flows, keys, more, err := t.openPositions(stub, now.Unix()+1, orgs, defaultSettleLimit)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("get open positions failed, err %+v", err))
+	}
+	open := &schema.Settlement{To: now.Unix(), Entries: len(keys), More: more, Positions: schema.NetPositions(flows)}
+	last, err := t.getLastSettlement(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if last != nil {
+		open.From = last.To
+	}
+	value, err := open.ToBytes()
+	if err != nil {
+		return shim.Error(errors.WithStack(err).Error())
+	}
+	return shim.Success(value)
+}
+
+// settle nets the position records made before the window end in arg0, unix
+// seconds at most the transaction time, writes them as a schema.Settlement
+// under the transaction id and deletes the records, admins and operators
+// only. It returns the settlement.
+// One settle reads at most arg1 (default 1000) records. If the window holds
+// more, the settlement has More set and settle must be run again with the
+// same window end, which continues the same settlement, before a new window
+// can be settled.
+func (t *Paymentcc) settle(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) < 1 || len(args) > 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 1 or 2 (window end, limit)")
+	}
+	config, err := t.getConfig(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("get chaincode configuration failed, err %+v", err))
+	}
+	if err := checkCreatorMSP(stub, "an admin or operator", config.Admins, config.Operators); err != nil {
+		return shim.Error(fmt.Sprintf("settle denied, err %+v", err))
+	}
+	now, err := txTime(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("get transaction timestamp failed, err %+v", err))
+	}
+	to, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("window end must be unix seconds, got %s", args[0]))
+	}
+	if to > now.Unix() {
+		return shim.Error(fmt.Sprintf("window end %d is after the transaction time %d", to, now.Unix()))
+	}
+	limit, err := settleLimit(args[1:])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	last, err := t.getLastSettlement(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	settlement := &schema.Settlement{ID: stub.GetTxID(), To: to}
+	switch {
+	case last != nil && last.More:
+		if to != last.To {
+			return shim.Error(fmt.Sprintf("settlement %s of the window ending at %d is not complete, settle it first", last.ID, last.To))
+		}
+		settlement = last
+	case last != nil && to <= last.To:
+		return shim.Error(fmt.Sprintf("window end %d is not after the previous settlement, %d", to, last.To))
+	case last != nil:
+		settlement.From = last.To
+	}
+
+	flows, keys, more, err := t.openPositions(stub, to, nil, limit)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("get open positions failed, err %+v", err))
+	}
+	for _, key := range keys {
+		if err := stub.DelState(key); err != nil {
+			return shim.Error(errors.WithMessage(err, "reset position failed.").Error())
+		}
+	}
+	// a continued settlement adds the records of this page to the previous ones
+	for pair, assets := range schema.Flows(settlement.Positions) {
+		if flows[pair] == nil {
+			flows[pair] = map[string]int64{}
+		}
+		for asset, amount := range assets {
+			flows[pair][asset] += amount
+		}
+	}
+	settlement.Entries += len(keys)
+	settlement.More = more
+	settlement.Positions = schema.NetPositions(flows)
+
+	value, err := settlement.ToBytes()
+	if err != nil {
+		return shim.Error(errors.WithStack(err).Error())
+	}
+	key, err := stub.CreateCompositeKey(settlementType, []string{settlement.ID})
+	if err != nil {
+		return shim.Error(errors.WithStack(err).Error())
+	}
+	if err := stub.PutState(key, value); err != nil {
+		return shim.Error(errors.WithMessage(err, "put settlement failed.").Error())
+	}
+	lastKey, err := stub.CreateCompositeKey(lastSettlementType, []string{})
+	if err != nil {
+		return shim.Error(errors.WithStack(err).Error())
+	}
+	if err := stub.PutState(lastKey, []byte(settlement.ID)); err != nil {
+		return shim.Error(errors.WithMessage(err, "put last settlement failed.").Error())
+	}
+
+	logger.Infof("settlement %s: %d positions from %d records, more %t", settlement.ID, len(settlement.Positions), settlement.Entries, settlement.More)
+	return shim.Success(value)
+}
+
+// settlement returns the schema.Settlement with the id in arg0, the latest one without argument.
+func (t *Paymentcc) settlement(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	var settlement *schema.Settlement
+	var err error
+	switch len(args) {
+	case 0:
+		if settlement, err = t.getLastSettlement(stub); err == nil && settlement == nil {
+			return shim.Error("no settlement yet")
+		}
+	case 1:
+		settlement, err = t.getSettlement(stub, args[0])
+	default:
+		return shim.Error("Incorrect number of arguments. Expecting 0 or 1 (id)")
+	}
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	value, err := settlement.ToBytes()
+	if err != nil {
+		return shim.Error(errors.WithStack(err).Error())
+	}
+	return shim.Success(value)
+}
+
+func (t *Paymentcc) getSettlement(stub shim.ChaincodeStubInterface, id string) (*schema.Settlement, error) {
+	key, err := stub.CreateCompositeKey(settlementType, []string{id})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	value, err := stub.GetState(key)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if value == nil {
+		return nil, errors.Errorf("settlement %s does not exist", id)
+	}
+	var settlement schema.Settlement
+	if err := settlement.FromBytes(value); err != nil {
+		return nil, err
+	}
+	return &settlement, nil
+}
+
+// getLastSettlement returns nil before the first settlement.
+func (t *Paymentcc) getLastSettlement(stub shim.ChaincodeStubInterface) (*schema.Settlement, error) {
+	key, err := stub.CreateCompositeKey(lastSettlementType, []string{})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	id, err := stub.GetState(key)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if id == nil {
+		return nil, nil
+	}
+	return t.getSettlement(stub, string(id))
+}
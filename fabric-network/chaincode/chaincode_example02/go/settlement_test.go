@@ -0,0 +1,71 @@
+package main
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/schema"
+)
+
+func (s *testStub) settlement(fn string, args ...string) *schema.Settlement {
+	s.t.Helper()
+	var settlement schema.Settlement
+	if err := settlement.FromBytes(s.mustInvoke(fn, args...)); err != nil {
+		s.t.Fatal(err)
+	}
+	return &settlement
+}
+
+func TestSettle(t *testing.T) {
+	s := newTestStub(t)
+	s.create("1", 100)
+	s.as(identity{msp: "Org2MSP", name: "User1@org2"})
+	s.create("2", 100)
+	s.as(admin)
+
+	t0 := s.now
+	s.mustInvoke("transfer", s.transfer("1", "2", 30))
+	s.mustInvoke("transfer", s.transfer("2", "1", 10))
+	owed := []schema.NetPosition{{Debtor: "Org1MSP", Creditor: "Org2MSP", Amount: 20}}
+	if open := s.settlement("positions"); open.Entries != 2 || !reflect.DeepEqual(open.Positions, owed) {
+		t.Errorf("open positions = %+v, want %+v", *open, owed)
+	}
+
+	s.now = t0.Add(time.Minute)
+	window := strconv.FormatInt(t0.Unix()+1, 10)
+	s.as(identity{msp: "Org2MSP", name: "User1@org2"})
+	s.mustFail("settle", window)
+	s.as(admin)
+	s.mustFail("settle", strconv.FormatInt(s.now.Unix()+1, 10))
+
+	// a transfer after the window end is left for the next settlement
+	s.mustInvoke("transfer", s.transfer("1", "2", 5))
+	// one record per settle, the settlement is completed by the second one
+	first := s.settlement("settle", window, "1")
+	if !first.More || first.Entries != 1 {
+		t.Errorf("first page of the settlement = %+v, want 1 entry and more", *first)
+	}
+	s.mustFail("settle", strconv.FormatInt(s.now.Unix(), 10))
+	settlement := s.settlement("settle", window, "1")
+	if settlement.ID != first.ID || settlement.More || settlement.Entries != 2 || !reflect.DeepEqual(settlement.Positions, owed) {
+		t.Errorf("settlement = %+v, want the positions %+v of 2 entries", *settlement, owed)
+	}
+	if last := s.settlement("settlement"); !reflect.DeepEqual(last, settlement) {
+		t.Errorf("latest settlement = %+v, want %+v", *last, *settlement)
+	}
+	s.mustFail("settle", window)
+
+	// flows that cancel out settle to no position
+	s.mustInvoke("transfer", s.transfer("2", "1", 5))
+	s.now = s.now.Add(time.Minute)
+	next := s.settlement("settle", strconv.FormatInt(s.now.Unix(), 10))
+	if next.From != t0.Unix()+1 || next.Entries != 2 || len(next.Positions) != 0 {
+		t.Errorf("next settlement = %+v, want 2 entries netted to no position", *next)
+	}
+	if open := s.settlement("positions"); open.Entries != 0 || len(open.Positions) != 0 {
+		t.Errorf("open positions after settling = %+v, want none", *open)
+	}
+	s.expectBalances(map[string]int64{"1": 80, "2": 120})
+}
@@ -0,0 +1,551 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/merkle"
+	"github.com/GingerMoon/fabric_demo/payment-common/schema"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"github.com/pkg/errors"
+)
+
+// Composite key object types of the balance snapshots. The schema.Snapshot
+// is stored under (id) and the schema.SnapshotLeaves of its pages under
+// (id, page), sealed like the accounts when encryption is enabled, as are
+// the states kept for the snapshot in progress under (account, id).
+const (
+	snapshotType       = "snapshot"
+	snapshotLeavesType = "snapshotLeaves"
+	snapshotBeforeType = "snapshotBefore"
+	// the lastSnapshot key holds the id of the latest completed snapshot
+	lastSnapshotType = "lastSnapshot"
+	// the activeSnapshot key holds the id of the snapshot in progress
+	activeSnapshotType = "activeSnapshot"
+)
+
+// compositeKeyNamespace starts the composite keys, the plain keys are the
+// accounts of the default asset.
+const compositeKeyNamespace = "\x00"
+
+// absentBefore is the state kept for an account created after the start of
+// the snapshot in progress.
+var absentBefore = []byte{0}
+
+// Events of the snapshot function.
+const (
+	snapshotStarted = "snapshotStarted"
+	snapshotTaken   = "snapshotTaken"
+)
+
+// Page sizes of snapshot.
+const (
+	defaultSnapshotPage = 100
+	maxSnapshotPage     = 1000
+)
+
+// snapshot builds the Merkle tree of the balances of the accounts of the
+// default asset page by page, admins only.
+// With arg0 the height of the ledger, as reported by its client, it starts a
+// snapshot of the state committed before that transaction and sets the
+// snapshotStarted event. The height cannot be below the one of the latest
+// snapshot. The next calls take arg0 the bookmark returned by the previous
+// call and the optional arg1 the page size (default 100, at most 1000).
+// The pages are separate transactions, so while the snapshot is in progress
+// the first update of an account keeps its state from before the start, see
+// keepSnapshotState, which the later pages read instead of the current one.
+// Starting a snapshot abandons the one in progress, if any.
+func (t *Paymentcc) snapshot(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) == 0 || len(args) > 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 1 (height) or 1 (bookmark) or 2 (bookmark, page size)")
+	}
+	if err := t.checkAdmin(stub); err != nil {
+		return shim.Error(fmt.Sprintf("snapshot denied, err %+v", err))
+	}
+
+	// a bookmark is id/last account
+	i := strings.Index(args[0], "/")
+	if i < 0 {
+		if len(args) != 1 {
+			return shim.Error(fmt.Sprintf("malformed bookmark %q", args[0]))
+		}
+		return t.startSnapshot(stub, args[0])
+	}
+	id, last := args[0][:i], args[0][i+1:]
+	page := defaultSnapshotPage
+	if len(args) == 2 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 || n > maxSnapshotPage {
+			return shim.Error(fmt.Sprintf("page size must be between 1 and %d, got %s", maxSnapshotPage, args[1]))
+		}
+		page = n
+	}
+
+	snap, err := t.getSnapshot(stub, id)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if snap.Done {
+		return shim.Error(fmt.Sprintf("snapshot %s is complete", id))
+	}
+	active, err := t.getActiveSnapshot(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if active != id {
+		return shim.Error(fmt.Sprintf("snapshot %s was abandoned for snapshot %s", id, active))
+	}
+	// a replayed page would add its accounts twice
+	if n := len(snap.Pages); (n == 0 && last != "") || (n > 0 && last != snap.Pages[n-1].Last) {
+		return shim.Error(fmt.Sprintf("bookmark %q is not the next page of snapshot %s", args[0], id))
+	}
+
+	result, err := t.snapshotPage(stub, snap, last, page)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if result.Done {
+		roots, err := pageRoots(snap)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		snap.Root, snap.Done = hex.EncodeToString(merkle.Root(roots)), true
+		result.Root = snap.Root
+		if err := t.putLastSnapshot(stub, snap.ID); err != nil {
+			return shim.Error(err.Error())
+		}
+		if err := t.putActiveSnapshot(stub, ""); err != nil {
+			return shim.Error(err.Error())
+		}
+		if err := t.setEvent(stub, snapshotTaken, snap); err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+	if err := t.putSnapshot(stub, snap); err != nil {
+		return shim.Error(err.Error())
+	}
+
+	logger.Infof("snapshot %s: %d accounts, bookmark %q, root %s", snap.ID, snap.Accounts, result.Bookmark, snap.Root)
+	return snapshotResponse(result)
+}
+
+// startSnapshot starts the snapshot of the transaction at the ledger height
+// in arg, see snapshot.
+func (t *Paymentcc) startSnapshot(stub shim.ChaincodeStubInterface, arg string) pb.Response {
+	height, err := strconv.ParseUint(arg, 10, 64)
+	if err != nil || height == 0 {
+		return shim.Error(fmt.Sprintf("height must be the number of blocks of the ledger, got %s", arg))
+	}
+	// the chaincode cannot read the ledger height, at least it never goes back
+	previous, err := t.getLastSnapshotID(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if previous != "" {
+		last, err := t.getSnapshot(stub, previous)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if height < last.Height {
+			return shim.Error(fmt.Sprintf("height %d is below the height %d of snapshot %s", height, last.Height, last.ID))
+		}
+	}
+	now, err := txTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	snap := &schema.Snapshot{ID: stub.GetTxID(), Time: now.Unix(), Height: height, Pages: []schema.SnapshotPage{}}
+	if err := t.putSnapshot(stub, snap); err != nil {
+		return shim.Error(err.Error())
+	}
+	// the transactions updating accounts read the key, those endorsed
+	// before the start and committed after it are invalidated
+	if err := t.putActiveSnapshot(stub, snap.ID); err != nil {
+		return shim.Error(err.Error())
+	}
+	result := &schema.SnapshotResult{ID: snap.ID, Bookmark: snap.ID + "/"}
+	if err := t.setEvent(stub, snapshotStarted, result); err != nil {
+		return shim.Error(err.Error())
+	}
+	logger.Infof("snapshot %s started at height %d", snap.ID, height)
+	return snapshotResponse(result)
+}
+
+// snapshotPage adds the next page of up to page accounts after the key last
+// to snap, with the state they had when snap started.
+func (t *Paymentcc) snapshotPage(stub shim.ChaincodeStubInterface, snap *schema.Snapshot, last string, page int) (*schema.SnapshotResult, error) {
+	ring, err := t.newKeyring(stub)
+	if err != nil {
+		return nil, err
+	}
+	// the plain keys start after the composite key namespace
+	start := "\x01"
+	if last != "" {
+		start = last + "\x00"
+	}
+	iter, err := stub.GetStateByRange(start, "")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer iter.Close()
+
+	leaves := &schema.SnapshotLeaves{}
+	var hashes [][]byte
+	for iter.HasNext() && len(hashes) < page {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		clear, err := ring.open(kv.Key, kv.Value)
+		if err != nil {
+			return nil, err
+		}
+		beforeKey, err := stub.CreateCompositeKey(snapshotBeforeType, []string{kv.Key, snap.ID})
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		before, err := stub.GetState(beforeKey)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if before != nil {
+			// updated since the start
+			if clear, err = ring.open(beforeKey, before); err != nil {
+				return nil, err
+			}
+			if bytes.Equal(clear, absentBefore) {
+				continue
+			}
+		}
+		var account schema.Account
+		if err := account.FromBytes(clear); err != nil {
+			return nil, errors.WithMessage(err, "account "+kv.Key)
+		}
+		leaves.Keys = append(leaves.Keys, kv.Key)
+		leaves.Balances = append(leaves.Balances, account.Balance)
+		hashes = append(hashes, merkle.Leaf(kv.Key, account.Balance))
+	}
+
+	result := &schema.SnapshotResult{ID: snap.ID}
+	if len(hashes) > 0 {
+		index := len(snap.Pages)
+		key, err := snapshotLeavesKey(stub, snap.ID, index)
+		if err != nil {
+			return nil, err
+		}
+		clear, err := leaves.ToBytes()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		value, err := ring.seal(key, clear)
+		if err != nil {
+			return nil, err
+		}
+		if err := stub.PutState(key, value); err != nil {
+			return nil, errors.WithMessage(err, fmt.Sprintf("put page %d of snapshot %s failed.", index, snap.ID))
+		}
+		last = leaves.Keys[len(leaves.Keys)-1]
+		snap.Pages = append(snap.Pages, schema.SnapshotPage{First: leaves.Keys[0], Last: last, Size: len(hashes),
+			Root: hex.EncodeToString(merkle.Root(hashes))})
+		snap.Accounts += len(hashes)
+	}
+	result.Accounts = snap.Accounts
+	if iter.HasNext() {
+		result.Bookmark = snap.ID + "/" + last
+	} else {
+		result.Done = true
+	}
+	return result, nil
+}
+
+// pageRoots decodes the page roots of snap, the leaves of its top tree.
+func pageRoots(snap *schema.Snapshot) ([][]byte, error) {
+	roots := make([][]byte, len(snap.Pages))
+	for i, p := range snap.Pages {
+		root, err := hex.DecodeString(p.Root)
+		if err != nil {
+			return nil, errors.Wrapf(err, "malformed root of page %d of snapshot %s", i, snap.ID)
+		}
+		roots[i] = root
+	}
+	return roots, nil
+}
+
+func snapshotResponse(result *schema.SnapshotResult) pb.Response {
+	value, err := result.ToBytes()
+	if err != nil {
+		return shim.Error(errors.WithStack(err).Error())
+	}
+	return shim.Success(value)
+}
+
+func (t *Paymentcc) putSnapshot(stub shim.ChaincodeStubInterface, snap *schema.Snapshot) error {
+	key, err := stub.CreateCompositeKey(snapshotType, []string{snap.ID})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	value, err := snap.ToBytes()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := stub.PutState(key, value); err != nil {
+		return errors.WithMessage(err, fmt.Sprintf("put snapshot %s failed.", snap.ID))
+	}
+	return nil
+}
+
+func (t *Paymentcc) getSnapshot(stub shim.ChaincodeStubInterface, id string) (*schema.Snapshot, error) {
+	key, err := stub.CreateCompositeKey(snapshotType, []string{id})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	value, err := stub.GetState(key)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if value == nil {
+		return nil, errors.Errorf("snapshot %s does not exist", id)
+	}
+	var snap schema.Snapshot
+	if err := snap.FromBytes(value); err != nil {
+		return nil, err
+	}
+	return &snap, nil
+}
+
+// getActiveSnapshot returns the id of the snapshot in progress, "" if none.
+func (t *Paymentcc) getActiveSnapshot(stub shim.ChaincodeStubInterface) (string, error) {
+	key, err := stub.CreateCompositeKey(activeSnapshotType, []string{})
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	value, err := stub.GetState(key)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return string(value), nil
+}
+
+// putActiveSnapshot records id as the snapshot in progress, none if empty.
+func (t *Paymentcc) putActiveSnapshot(stub shim.ChaincodeStubInterface, id string) error {
+	key, err := stub.CreateCompositeKey(activeSnapshotType, []string{})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if id == "" {
+		err = stub.DelState(key)
+	} else {
+		err = stub.PutState(key, []byte(id))
+	}
+	if err != nil {
+		return errors.WithMessage(errors.WithStack(err), "put active snapshot failed.")
+	}
+	return nil
+}
+
+// keepSnapshotState keeps the state of the account key from before the start
+// of the snapshot in progress, if any, on its first update since: the state
+// committed before the current transaction, or absentBefore if the account
+// did not exist. Only the accounts of the default asset are snapshot.
+func (t *Paymentcc) keepSnapshotState(stub shim.ChaincodeStubInterface, ring *keyring, key string) error {
+	if strings.HasPrefix(key, compositeKeyNamespace) {
+		return nil
+	}
+	id, err := t.getActiveSnapshot(stub)
+	if err != nil || id == "" {
+		return err
+	}
+	beforeKey, err := stub.CreateCompositeKey(snapshotBeforeType, []string{key, id})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	kept, err := stub.GetState(beforeKey)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if kept != nil {
+		return nil
+	}
+	current, err := stub.GetState(key)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	clear := absentBefore
+	if current != nil {
+		if clear, err = ring.open(key, current); err != nil {
+			return err
+		}
+	}
+	value, err := ring.seal(beforeKey, clear)
+	if err != nil {
+		return err
+	}
+	if err := stub.PutState(beforeKey, value); err != nil {
+		return errors.WithMessage(errors.WithStack(err), fmt.Sprintf("keep state of %s for snapshot %s failed.", key, id))
+	}
+	return nil
+}
+
+func (t *Paymentcc) putLastSnapshot(stub shim.ChaincodeStubInterface, id string) error {
+	key, err := stub.CreateCompositeKey(lastSnapshotType, []string{})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := stub.PutState(key, []byte(id)); err != nil {
+		return errors.WithMessage(err, "put last snapshot failed.")
+	}
+	return nil
+}
+
+// getLastSnapshotID returns the id of the latest completed snapshot, "" if
+// none.
+func (t *Paymentcc) getLastSnapshotID(stub shim.ChaincodeStubInterface) (string, error) {
+	key, err := stub.CreateCompositeKey(lastSnapshotType, []string{})
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	value, err := stub.GetState(key)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return string(value), nil
+}
+
+// getCompletedSnapshot returns the snapshot id, the latest completed one if
+// id is empty.
+func (t *Paymentcc) getCompletedSnapshot(stub shim.ChaincodeStubInterface, id string) (*schema.Snapshot, error) {
+	if id == "" {
+		last, err := t.getLastSnapshotID(stub)
+		if err != nil {
+			return nil, err
+		}
+		if last == "" {
+			return nil, errors.New("no snapshot yet")
+		}
+		id = last
+	}
+	snap, err := t.getSnapshot(stub, id)
+	if err != nil {
+		return nil, err
+	}
+	if !snap.Done {
+		return nil, errors.Errorf("snapshot %s is in progress", id)
+	}
+	return snap, nil
+}
+
+// snapshotLeavesKey returns the state key of the leaves of the page index of
+// snapshot id.
+func snapshotLeavesKey(stub shim.ChaincodeStubInterface, id string, index int) (string, error) {
+	key, err := stub.CreateCompositeKey(snapshotLeavesType, []string{id, fmt.Sprintf("%08d", index)})
+	return key, errors.WithStack(err)
+}
+
+// getSnapshotLeaves returns the leaves of the page index of snapshot id.
+func (t *Paymentcc) getSnapshotLeaves(stub shim.ChaincodeStubInterface, id string, index int) (*schema.SnapshotLeaves, error) {
+	key, err := snapshotLeavesKey(stub, id, index)
+	if err != nil {
+		return nil, err
+	}
+	value, err := stub.GetState(key)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if value == nil {
+		return nil, errors.Errorf("page %d of snapshot %s does not exist", index, id)
+	}
+	if isSealed(value) {
+		ring, err := t.newKeyring(stub)
+		if err != nil {
+			return nil, err
+		}
+		if value, err = ring.open(key, value); err != nil {
+			return nil, err
+		}
+	}
+	var leaves schema.SnapshotLeaves
+	if err := leaves.FromBytes(value); err != nil {
+		return nil, err
+	}
+	return &leaves, nil
+}
+
+// snapshotInfo returns the schema.Snapshot with the id in arg0, the latest
+// completed one without argument.
+func (t *Paymentcc) snapshotInfo(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	var snap *schema.Snapshot
+	var err error
+	switch len(args) {
+	case 0:
+		snap, err = t.getCompletedSnapshot(stub, "")
+	case 1:
+		snap, err = t.getSnapshot(stub, args[0])
+	default:
+		return shim.Error("Incorrect number of arguments. Expecting 0 or 1 (id)")
+	}
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	value, err := snap.ToBytes()
+	if err != nil {
+		return shim.Error(errors.WithStack(err).Error())
+	}
+	return shim.Success(value)
+}
+
+// proof returns the merkle.Proof of the balance of the account in arg0 in
+// the completed snapshot with the optional id in arg1, the latest one by
+// default. Aliases are resolved.
+func (t *Paymentcc) proof(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) != 1 && len(args) != 2 {
+		return shim.Error("Incorrect number of arguments. Expecting 1 (account) or 2 (account, snapshot id)")
+	}
+	account, err := t.resolveAccount(stub, args[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	id := ""
+	if len(args) == 2 {
+		id = args[1]
+	}
+	snap, err := t.getCompletedSnapshot(stub, id)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	index := sort.Search(len(snap.Pages), func(i int) bool { return snap.Pages[i].First > account }) - 1
+	if index < 0 {
+		return shim.Error(fmt.Sprintf("account %s is not in snapshot %s", account, snap.ID))
+	}
+	leaves, err := t.getSnapshotLeaves(stub, snap.ID, index)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	position := sort.SearchStrings(leaves.Keys, account)
+	if position == len(leaves.Keys) || leaves.Keys[position] != account {
+		return shim.Error(fmt.Sprintf("account %s is not in snapshot %s", account, snap.ID))
+	}
+
+	hashes := make([][]byte, len(leaves.Keys))
+	for i, key := range leaves.Keys {
+		hashes[i] = merkle.Leaf(key, leaves.Balances[i])
+	}
+	roots, err := pageRoots(snap)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	proof := &merkle.Proof{Snapshot: snap.ID, Root: snap.Root, Height: snap.Height, Time: snap.Time,
+		Key: account, Balance: leaves.Balances[position],
+		Steps: append(merkle.Path(hashes, position), merkle.Path(roots, index)...)}
+	value, err := proof.ToBytes()
+	if err != nil {
+		return shim.Error(errors.WithStack(err).Error())
+	}
+	return shim.Success(value)
+}
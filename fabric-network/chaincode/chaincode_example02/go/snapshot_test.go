@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/merkle"
+	"github.com/GingerMoon/fabric_demo/payment-common/schema"
+)
+
+func (s *testStub) snapshot(args ...string) *schema.SnapshotResult {
+	s.t.Helper()
+	var result schema.SnapshotResult
+	if err := result.FromBytes(s.mustInvoke("snapshot", args...)); err != nil {
+		s.t.Fatal(err)
+	}
+	return &result
+}
+
+func (s *testStub) proof(account string) *merkle.Proof {
+	s.t.Helper()
+	var proof merkle.Proof
+	if err := proof.FromBytes(s.mustInvoke("proof", account)); err != nil {
+		s.t.Fatal(err)
+	}
+	return &proof
+}
+
+func (s *testStub) snapshotInfo(id string) *schema.Snapshot {
+	s.t.Helper()
+	var snap schema.Snapshot
+	if err := snap.FromBytes(s.mustInvoke("snapshotInfo", id)); err != nil {
+		s.t.Fatal(err)
+	}
+	return &snap
+}
+
+func TestSnapshot(t *testing.T) {
+	s := newTestStub(t)
+	for i := 1; i <= 5; i++ {
+		s.create(strconv.Itoa(i), int64(100*i))
+	}
+	s.mustFail("snapshot", "0")
+	s.mustFail("snapshot", "x")
+	start := s.snapshot("10")
+	id := start.ID
+
+	// the updates after the start are not in the snapshot
+	s.mustInvoke("transfer", s.transfer("1", "5", 50))
+	first := s.snapshot(start.Bookmark, "2")
+	if first.Done || first.Bookmark != id+"/2" {
+		t.Fatalf("first page = %+v, want the bookmark %s/2", *first, id)
+	}
+	s.mustFail("snapshot", start.Bookmark, "2")
+	s.mustInvoke("transfer", s.transfer("4", "1", 100))
+	s.create("6", 600)
+	second := s.snapshot(first.Bookmark, "2")
+	last := s.snapshot(second.Bookmark, "2")
+	if !last.Done || last.Accounts != 5 || last.Root == "" {
+		t.Fatalf("last page = %+v, want 5 accounts and the root", *last)
+	}
+	s.mustFail("snapshot", last.Bookmark, "2")
+
+	snap := s.snapshotInfo(id)
+	if snap.Height != 10 || snap.Root != last.Root || len(snap.Pages) != 3 {
+		t.Errorf("snapshot = %+v, want height 10, root %s and 3 pages", *snap, last.Root)
+	}
+	for account, balance := range map[string]int64{"1": 100, "4": 400, "5": 500} {
+		proof := s.proof(account)
+		if proof.Balance != balance || proof.Height != 10 || proof.Snapshot != id {
+			t.Errorf("proof of %s = %+v, want %d at height 10", account, *proof, balance)
+		}
+		if err := proof.Verify(snap.Root); err != nil {
+			t.Errorf("proof of %s: %v", account, err)
+		}
+		// a proof of another balance does not lead to the root
+		proof.Balance++
+		if err := proof.Verify(snap.Root); err == nil {
+			t.Errorf("proof of %s with a wrong balance verifies", account)
+		}
+	}
+	s.mustFail("proof", "6")
+
+	// the height never goes back, a snapshot in progress is abandoned
+	s.mustFail("snapshot", "9")
+	abandoned := s.snapshot("10")
+	next := s.snapshot("12")
+	s.mustFail("snapshot", abandoned.Bookmark)
+	s.snapshot(next.Bookmark)
+	if proof := s.proof("1"); proof.Balance != 150 || proof.Height != 12 {
+		t.Errorf("proof of 1 in the next snapshot = %+v, want 150 at height 12", *proof)
+	}
+
+	s.as(identity{msp: "Org2MSP", name: "Admin@org2"})
+	s.mustFail("snapshot", "20")
+}
+
+// TestRotateSnapshots checks that rotate re-seals the snapshot pages and the
+// states kept for a snapshot in progress.
+func TestRotateSnapshots(t *testing.T) {
+	key1, key2 := bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 32)
+	s := newTestStub(t)
+	for i := 1; i <= 3; i++ {
+		s.create(strconv.Itoa(i), 100)
+	}
+	s.transient = map[string][]byte{AESKEY: key1}
+	s.rotateAll(10)
+
+	done := s.snapshot("1")
+	for !done.Done {
+		done = s.snapshot(done.Bookmark, "1")
+	}
+	progress := s.snapshot("2")
+	progress = s.snapshot(progress.Bookmark, "1")
+	s.mustInvoke("transfer", s.transfer("3", "1", 10))
+
+	s.transient = map[string][]byte{AESKEY: key2, AESKEY_OLD: key1}
+	// 3 accounts with the kept states of 1 and 3, then 3 + 1 snapshot pages
+	if bookmarks := s.rotateAll(2); len(bookmarks) != 3 || bookmarks[0] != "accounts/2" ||
+		bookmarks[1] != "snapshots/"+done.ID+"/0" || bookmarks[2] != "snapshots/"+done.ID+"/2" {
+		t.Errorf("rotation bookmarks = %q", bookmarks)
+	}
+	for key, value := range s.State {
+		if isSealed(value) && sealedVersion(value) != 2 {
+			t.Errorf("state %q is still sealed with key %d", key, sealedVersion(value))
+		}
+	}
+
+	s.transient = map[string][]byte{AESKEY: key2}
+	for progress = s.snapshot(progress.Bookmark, "1"); !progress.Done; {
+		progress = s.snapshot(progress.Bookmark, "1")
+	}
+	if proof := s.proof("3"); proof.Balance != 100 {
+		t.Errorf("proof of 3 = %+v, want the balance before the snapshot", *proof)
+	}
+	if err := s.proof("1").Verify(progress.Root); err != nil {
+		t.Error(err)
+	}
+}
@@ -1,8 +1,8 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
+	"github.com/GingerMoon/fabric_demo/payment-common/schema"
 	"github.com/hyperledger/fabric/common/flogging"
 	"github.com/hyperledger/fabric/core/chaincode/shim"
 	pb "github.com/hyperledger/fabric/protos/peer"
@@ -14,37 +14,14 @@ const (
 	COLLECTION 	  = "collectionPayment"
 )
 
+// maxBalance is the largest balance an account can hold.
+const maxBalance = int(^uint(0) >> 1)
+
 var (
 	//logger = shim.NewLogger("payment_cc")
 	logger = flogging.MustGetLogger("payment_cc")
 )
 
-type Payload struct {
-	From   string `json:from`
-	To     string `json:to`
-	Amount int `json:amount`
-}
-
-func (a *Payload) ToBytes() ([]byte, error) {
-	return json.Marshal(a)
-}
-
-func (a *Payload) FromBytes(d []byte) error {
-	return json.Unmarshal(d, a)
-}
-
-type accountInfo struct {
-	Balance int  `json: "balance"`
-}
-
-func (a *accountInfo) ToBytes() ([]byte, error) {
-	return json.Marshal(a)
-}
-
-func (a *accountInfo) FromBytes(d []byte) error {
-	return json.Unmarshal(d, a)
-}
-
 
 // Paymentcc example simple Chaincode implementation
 type Paymentcc struct {
@@ -82,43 +59,62 @@ func (t *Paymentcc) create(stub shim.ChaincodeStubInterface, args []string) pb.R
 		return shim.Error("Incorrect number of arguments. Expecting 1")
 	}
 
-	payload_str := args[0]
-
-	var payload Payload
-	payload.FromBytes([]byte(payload_str))
+	payload, err := schema.Decode([]byte(args[0]), schema.Create)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("invalid payload, err %+v", err))
+	}
+	if payload.Asset != "" {
+		return shim.Error(fmt.Sprintf("asset %s is not supported, the private chaincode only holds the default asset", payload.Asset))
+	}
 
-	err := t.putBalance(stub, payload.To, payload.Amount)
+	err = t.putBalance(stub, payload.To, int(payload.Amount), schema.EncodingOf([]byte(args[0])))
 	if err != nil {
-		return shim.Error(fmt.Sprintf("put balance %s for %s failed, err %+v", args[1], args[0], err))
+		return shim.Error(fmt.Sprintf("put balance %d for %s failed, err %+v", payload.Amount, payload.To, err))
 	}
 
 	return shim.Success(nil)
 }
 
-// arg0 is the world state key
+// arg0 is the world state key, the optional arg1 is the response encoding (json or proto)
 func (t *Paymentcc) query(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	if len(args) != 1 {
+	if len(args) != 1 && len(args) != 2 {
 		return shim.Error("Incorrect number of arguments. Expecting name of the person to query")
 	}
 
+	enc := schema.JSON
+	if len(args) == 2 {
+		var err error
+		if enc, err = schema.ParseEncoding(args[1]); err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
 	key := args[0]
 	account, err := t.getAccountInfo(stub, key)
-	cleartextValue, err := account.ToBytes()
 	if err != nil {
-		return shim.Error(fmt.Sprintf("getStateDecryptAndVerify failed, err %+v", err))
+		return shim.Error(fmt.Sprintf("get account for %s failed, err %+v", key, err))
+	}
+	cleartextValue, err := account.Encode(enc)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("encode account %s failed, err %+v", key, err))
 	}
 
 	return shim.Success(cleartextValue)
 }
 
-func (t *Paymentcc) getAccountInfo (stub shim.ChaincodeStubInterface, key string) (*accountInfo, error) {
+func (t *Paymentcc) getAccountInfo (stub shim.ChaincodeStubInterface, key string) (*schema.Account, error) {
 	accountInfobytes, err := stub.GetPrivateData(COLLECTION, key)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
+	if len(accountInfobytes) == 0 {
+		return nil, errors.Errorf("account %s does not exist", key)
+	}
 
-	var account accountInfo
-	account.FromBytes(accountInfobytes)
+	var account schema.Account
+	if err := account.FromBytes(accountInfobytes); err != nil {
+		return nil, errors.WithStack(err)
+	}
 
 	return &account, nil
 }
@@ -129,15 +125,16 @@ func (t *Paymentcc) getBalance (stub shim.ChaincodeStubInterface, key string) (i
 		return -1, errors.WithStack(errors.WithMessage(err, fmt.Sprintf("get account for %s failed.", key)))
 	}
 
-	return account.Balance, err
+	return int(account.Balance), nil
 }
 
-func (t *Paymentcc) putBalance (stub shim.ChaincodeStubInterface, key string, balance int) error {
-	logger.Infof("put %s : %s", key, balance)
+// putBalance stores the account state with enc, the encoding negotiated by the invoking payload.
+func (t *Paymentcc) putBalance (stub shim.ChaincodeStubInterface, key string, balance int, enc schema.Encoding) error {
+	logger.Infof("put %s : %d (%s)", key, balance, enc)
 
 	// sign, then encrypt, then put state
-	a := accountInfo{Balance: balance}
-	payload, err := a.ToBytes()
+	a := schema.Account{Balance: int64(balance)}
+	payload, err := a.Encode(enc)
 	if err != nil {
 		return errors.WithStack(err)
 	}
@@ -157,12 +154,12 @@ func (t *Paymentcc) transfer(stub shim.ChaincodeStubInterface, args []string) pb
 		return shim.Error("Incorrect number of arguments. Expecting 1")
 	}
 
-	payload_str := args[0]
-	var payload Payload
-	payload.FromBytes([]byte(payload_str))
-
-	if payload.From == payload.To {
-		return shim.Success(nil)
+	payload, err := schema.Decode([]byte(args[0]), schema.Transfer)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("invalid payload, err %+v", err))
+	}
+	if payload.Asset != "" {
+		return shim.Error(fmt.Sprintf("asset %s is not supported, the private chaincode only holds the default asset", payload.Asset))
 	}
 
 	// get balance of A and B
@@ -178,22 +175,26 @@ func (t *Paymentcc) transfer(stub shim.ChaincodeStubInterface, args []string) pb
 	}
 	logger.Infof("before transfer, %s's balance is %d", payload.To, balanceB)
 
-	balanceA = balanceA - payload.Amount
+	X := int(payload.Amount)
+	balanceA = balanceA - X
 	if balanceA < 0 {
-		return shim.Error(fmt.Sprintf("account %s has not enough balance (%d) to Transfer %d.", args[0], balanceA + payload.Amount, payload.Amount))
+		return shim.Error(fmt.Sprintf("account %s has not enough balance (%d) to Transfer %d.", payload.From, balanceA + X, X))
+	}
+	if X > maxBalance-balanceB {
+		return shim.Error(fmt.Sprintf("account %s cannot be credited %d, its balance (%d) would overflow", payload.To, X, balanceB))
 	}
-	err = t.putBalance(stub, payload.From, balanceA)
+	enc := schema.EncodingOf([]byte(args[0]))
+	err = t.putBalance(stub, payload.From, balanceA, enc)
 	if err != nil {
-		return shim.Error(errors.WithMessage(err, fmt.Sprintf("put balance for account %s failed.", args[0])).Error())
+		return shim.Error(errors.WithMessage(err, fmt.Sprintf("put balance for account %s failed.", payload.From)).Error())
 	}
 
-	balanceB = balanceB + payload.Amount
-	t.putBalance(stub, payload.To, balanceB)
+	balanceB = balanceB + X
+	err = t.putBalance(stub, payload.To, balanceB, enc)
 	if err != nil {
-		return shim.Error(errors.WithMessage(err, fmt.Sprintf("put balance for account %s failed.", args[1])).Error())
+		return shim.Error(errors.WithMessage(err, fmt.Sprintf("put balance for account %s failed.", payload.To)).Error())
 	}
 
-	fmt.Printf("balanceA = %d, balanceB = %d\n", balanceA, balanceB)
 	return shim.Success(nil)
 }
 
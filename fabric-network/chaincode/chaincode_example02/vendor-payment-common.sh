@@ -0,0 +1,25 @@
+#!/bin/bash -eu
+#
+# This script copies the payment-common packages into the vendor directory of
+# the chaincodes, so that each chaincode builds on its own once packaged for
+# the peer. payment-common changes together with the chaincodes in this
+# repository, there is no released revision dep could pin, so Gopkg.toml
+# ignores it and it is vendored from the checkout instead.
+#
+# Run it after dep ensure, which replaces the vendor directory, and after
+# every change to payment-common.
+
+cd "$(dirname "$0")"
+COMMON=../../../payment-common
+VENDORED=vendor/github.com/GingerMoon/fabric_demo/payment-common
+
+for cc in go go_pvt; do
+  echo "Vendoring payment-common into $cc"
+  rm -rf $cc/$VENDORED
+  # the library packages only: no tests, commands or vendored dependencies
+  (cd $COMMON && find . -type f ! -name '*_test.go' ! -path './cmd/*' ! -path './vendor/*' \
+    ! -name 'Gopkg.*' ! -name '*.md') | while read -r f; do
+    mkdir -p "$cc/$VENDORED/$(dirname "$f")"
+    cp "$COMMON/$f" "$cc/$VENDORED/$f"
+  done
+done
@@ -0,0 +1,180 @@
+// Package blob describes how large attachments are split into chunks small
+// enough for a single transaction and how their integrity is checked.
+package blob
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultChunkSize keeps a chunk transaction well below the gRPC message limit.
+	DefaultChunkSize = 1 << 20
+	// MaxChunkSize is the largest chunk the chaincode accepts.
+	MaxChunkSize = 3 << 20
+)
+
+// Manifest lists the SHA-256 of every chunk of a blob and the root hash,
+// the SHA-256 of all the chunk hashes concatenated in order.
+type Manifest struct {
+	ID        string   `json:"id"`
+	Size      int64    `json:"size"`
+	ChunkSize int      `json:"chunkSize"`
+	Chunks    []string `json:"chunks"`
+	Root      string   `json:"root"`
+}
+
+// ToBytes marshals the manifest as JSON.
+func (m *Manifest) ToBytes() ([]byte, error) {
+	return json.Marshal(m)
+}
+
+// FromBytes unmarshals and validates a manifest.
+func (m *Manifest) FromBytes(d []byte) error {
+	if err := json.Unmarshal(d, m); err != nil {
+		return errors.Wrap(err, "malformed blob manifest")
+	}
+	return m.Validate()
+}
+
+// ChunkCount returns the number of chunks of a blob of size bytes.
+func ChunkCount(size int64, chunkSize int) int {
+	if size == 0 {
+		return 0
+	}
+	return int((size + int64(chunkSize) - 1) / int64(chunkSize))
+}
+
+// ChunkLen returns the length of chunk index, the last one may be short.
+func (m *Manifest) ChunkLen(index int) int {
+	if index == len(m.Chunks)-1 {
+		if rest := int(m.Size % int64(m.ChunkSize)); rest != 0 {
+			return rest
+		}
+	}
+	return m.ChunkSize
+}
+
+// Validate checks the chunk layout and that the root matches the chunk hashes.
+func (m *Manifest) Validate() error {
+	if m.ID == "" {
+		return errors.New("blob manifest: missing id")
+	}
+	if m.ChunkSize <= 0 || m.ChunkSize > MaxChunkSize {
+		return errors.Errorf("blob manifest %s: chunk size %d out of range (0, %d]", m.ID, m.ChunkSize, MaxChunkSize)
+	}
+	if m.Size < 0 {
+		return errors.Errorf("blob manifest %s: negative size %d", m.ID, m.Size)
+	}
+	if n := ChunkCount(m.Size, m.ChunkSize); n != len(m.Chunks) {
+		return errors.Errorf("blob manifest %s: %d bytes need %d chunks, got %d", m.ID, m.Size, n, len(m.Chunks))
+	}
+
+	root, err := RootHash(m.Chunks)
+	if err != nil {
+		return errors.WithMessage(err, "blob manifest "+m.ID)
+	}
+	if root != m.Root {
+		return errors.Errorf("blob manifest %s: root hash %s does not match chunks (%s)", m.ID, m.Root, root)
+	}
+	return nil
+}
+
+// VerifyChunk checks the length and the SHA-256 of chunk index.
+func (m *Manifest) VerifyChunk(index int, data []byte) error {
+	if index < 0 || index >= len(m.Chunks) {
+		return errors.Errorf("blob %s: chunk %d out of range [0, %d)", m.ID, index, len(m.Chunks))
+	}
+	if len(data) != m.ChunkLen(index) {
+		return errors.Errorf("blob %s: chunk %d has %d bytes, expecting %d", m.ID, index, len(data), m.ChunkLen(index))
+	}
+	if sum := ChunkHash(data); sum != m.Chunks[index] {
+		return errors.Errorf("blob %s: chunk %d hash %s does not match manifest %s", m.ID, index, sum, m.Chunks[index])
+	}
+	return nil
+}
+
+// ChunkHash returns the hex SHA-256 of a chunk.
+func ChunkHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// RootHash returns the hex SHA-256 of the concatenated chunk hashes.
+func RootHash(chunks []string) (string, error) {
+	h := sha256.New()
+	for i, c := range chunks {
+		b, err := hex.DecodeString(c)
+		if err != nil || len(b) != sha256.Size {
+			return "", errors.Errorf("chunk %d: malformed hash %q", i, c)
+		}
+		h.Write(b)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// NewManifest streams r and builds the manifest of its content.
+func NewManifest(id string, r io.Reader, chunkSize int) (*Manifest, error) {
+	m := &Manifest{ID: id, ChunkSize: chunkSize, Chunks: []string{}}
+	buf := make([]byte, chunkSize)
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			m.Chunks = append(m.Chunks, ChunkHash(buf[:n]))
+			m.Size += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	root, err := RootHash(m.Chunks)
+	if err != nil {
+		return nil, err
+	}
+	m.Root = root
+	return m, m.Validate()
+}
+
+// Equal reports whether two manifests describe the same blob.
+func (m *Manifest) Equal(o *Manifest) bool {
+	a, _ := m.ToBytes()
+	b, _ := o.ToBytes()
+	return bytes.Equal(a, b)
+}
+
+// Status is the answer of getBlob without a chunk index.
+type Status struct {
+	Manifest *Manifest `json:"manifest"`
+	Stored   int       `json:"stored"`
+	Missing  []int     `json:"missing"`
+}
+
+// ToBytes marshals the status as JSON.
+func (s *Status) ToBytes() ([]byte, error) {
+	return json.Marshal(s)
+}
+
+// FromBytes unmarshals a status and validates its manifest.
+func (s *Status) FromBytes(d []byte) error {
+	if err := json.Unmarshal(d, s); err != nil {
+		return errors.Wrap(err, "malformed blob status")
+	}
+	if s.Manifest == nil {
+		return errors.New("blob status without manifest")
+	}
+	return s.Manifest.Validate()
+}
+
+// Complete reports whether every chunk has been stored.
+func (s *Status) Complete() bool {
+	return len(s.Missing) == 0
+}
@@ -0,0 +1,136 @@
+package blob
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestChunkCount(t *testing.T) {
+	tests := []struct {
+		size      int64
+		chunkSize int
+		want      int
+	}{
+		{0, 4, 0},
+		{1, 4, 1},
+		{4, 4, 1},
+		{5, 4, 2},
+		{8, 4, 2},
+		{9, 4, 3},
+	}
+	for _, tt := range tests {
+		if got := ChunkCount(tt.size, tt.chunkSize); got != tt.want {
+			t.Errorf("ChunkCount(%d, %d) = %d, want %d", tt.size, tt.chunkSize, got, tt.want)
+		}
+	}
+}
+
+func TestNewManifest(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		lengths []int
+	}{
+		{"empty", "", nil},
+		{"short", "abc", []int{3}},
+		{"exact", "abcd", []int{4}},
+		{"two chunks", "abcdefgh", []int{4, 4}},
+		{"short last chunk", "abcdefghij", []int{4, 4, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewManifest("id", strings.NewReader(tt.data), 4)
+			if err != nil {
+				t.Fatalf("NewManifest: %v", err)
+			}
+			if m.Size != int64(len(tt.data)) || len(m.Chunks) != len(tt.lengths) {
+				t.Fatalf("NewManifest = %d bytes in %d chunks, want %d in %d", m.Size, len(m.Chunks), len(tt.data), len(tt.lengths))
+			}
+			offset := 0
+			for i, n := range tt.lengths {
+				if got := m.ChunkLen(i); got != n {
+					t.Errorf("ChunkLen(%d) = %d, want %d", i, got, n)
+				}
+				if err := m.VerifyChunk(i, []byte(tt.data[offset:offset+n])); err != nil {
+					t.Errorf("VerifyChunk(%d): %v", i, err)
+				}
+				offset += n
+			}
+
+			d, err := m.ToBytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var decoded Manifest
+			if err := decoded.FromBytes(d); err != nil {
+				t.Fatalf("FromBytes(%s): %v", d, err)
+			}
+			if !decoded.Equal(m) {
+				t.Errorf("FromBytes = %+v, want %+v", decoded, *m)
+			}
+		})
+	}
+}
+
+func TestVerifyChunk(t *testing.T) {
+	m, err := NewManifest("id", strings.NewReader("abcdefghij"), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		index int
+		data  string
+		ok    bool
+	}{
+		{"first", 0, "abcd", true},
+		{"last", 2, "ij", true},
+		{"negative index", -1, "abcd", false},
+		{"index out of range", 3, "abcd", false},
+		{"short", 0, "abc", false},
+		{"long last", 2, "ijk", false},
+		{"tampered", 1, "efgX", false},
+		{"swapped", 0, "efgh", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := m.VerifyChunk(tt.index, []byte(tt.data)); (err == nil) != tt.ok {
+				t.Errorf("VerifyChunk(%d, %q) = %v, want ok %v", tt.index, tt.data, err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestManifestValidate(t *testing.T) {
+	valid, err := NewManifest("id", bytes.NewReader(make([]byte, 10)), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		modify func(m *Manifest)
+		ok     bool
+	}{
+		{"valid", func(m *Manifest) {}, true},
+		{"missing id", func(m *Manifest) { m.ID = "" }, false},
+		{"zero chunk size", func(m *Manifest) { m.ChunkSize = 0 }, false},
+		{"chunk size too large", func(m *Manifest) { m.ChunkSize = MaxChunkSize + 1 }, false},
+		{"negative size", func(m *Manifest) { m.Size = -1 }, false},
+		{"size needing another chunk", func(m *Manifest) { m.Size = 13 }, false},
+		{"missing chunk", func(m *Manifest) { m.Chunks = m.Chunks[:2] }, false},
+		{"malformed chunk hash", func(m *Manifest) { m.Chunks[0] = "zz" }, false},
+		{"other root", func(m *Manifest) { m.Root = ChunkHash(nil) }, false},
+		{"reordered chunks", func(m *Manifest) { m.Chunks[1], m.Chunks[2] = m.Chunks[2], m.Chunks[1] }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := *valid
+			m.Chunks = append([]string{}, valid.Chunks...)
+			tt.modify(&m)
+			if err := m.Validate(); (err == nil) != tt.ok {
+				t.Errorf("Validate() = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+// schemabench compares the JSON and protobuf encodings of the payment
+// payloads and account state: encoded size and marshal/unmarshal cost.
+//
+//	go run ./cmd/schemabench
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"testing"
+	"text/tabwriter"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/schema"
+)
+
+type result struct {
+	name   string
+	size   int
+	encode testing.BenchmarkResult
+	decode testing.BenchmarkResult
+}
+
+func benchPayload(enc schema.Encoding, p schema.Payload) (result, error) {
+	d, err := p.Encode(enc)
+	if err != nil {
+		return result{}, err
+	}
+	if _, err := schema.Decode(d, schema.Transfer); err != nil {
+		return result{}, err
+	}
+
+	r := result{name: "payload/" + enc.String(), size: len(d)}
+	r.encode = testing.Benchmark(func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			p.Encode(enc)
+		}
+	})
+	r.decode = testing.Benchmark(func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			schema.Decode(d, schema.Transfer)
+		}
+	})
+	return r, nil
+}
+
+func benchAccount(enc schema.Encoding, a schema.Account) (result, error) {
+	d, err := a.Encode(enc)
+	if err != nil {
+		return result{}, err
+	}
+
+	r := result{name: "account/" + enc.String(), size: len(d)}
+	r.encode = testing.Benchmark(func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			a.Encode(enc)
+		}
+	})
+	r.decode = testing.Benchmark(func(b *testing.B) {
+		b.ReportAllocs()
+		var out schema.Account
+		for i := 0; i < b.N; i++ {
+			out.FromBytes(d)
+		}
+	})
+	return r, nil
+}
+
+func main() {
+	from := flag.String("from", "123456", "source account key of the sample payload")
+	to := flag.String("to", "654321", "destination account key of the sample payload")
+	amount := flag.Int64("amount", 80, "amount of the sample payload and balance of the sample account")
+	flag.Parse()
+
+	p := schema.Payload{From: *from, To: *to, Amount: *amount}
+	a := schema.Account{Balance: *amount}
+
+	var results []result
+	for _, enc := range []schema.Encoding{schema.JSON, schema.Proto} {
+		r, err := benchPayload(enc, p)
+		if err != nil {
+			log.Fatalf("payload/%s: %+v", enc, err)
+		}
+		results = append(results, r)
+
+		r, err = benchAccount(enc, a)
+		if err != nil {
+			log.Fatalf("account/%s: %+v", enc, err)
+		}
+		results = append(results, r)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "format\tbytes\tencode ns/op\tencode allocs/op\tdecode ns/op\tdecode allocs/op")
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\n", r.name, r.size,
+			r.encode.NsPerOp(), r.encode.AllocsPerOp(), r.decode.NsPerOp(), r.decode.AllocsPerOp())
+	}
+	w.Flush()
+}
@@ -0,0 +1,152 @@
+// Package merkle builds the Merkle trees of the balance snapshots and
+// verifies their inclusion proofs. The chaincode and an offline verifier
+// must hash identically, so the tree is defined here once.
+//
+// A leaf is SHA-256(0x00 || len(key) || key || balance) with the key length
+// and the balance as big-endian 64-bit integers, a node is
+// SHA-256(0x01 || left || right). The prefixes keep a leaf from passing for
+// a node. On a level with an odd number of hashes the last one is promoted
+// to the next level unchanged.
+package merkle
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	leafPrefix = 0x00
+	nodePrefix = 0x01
+)
+
+// Leaf returns the hash of the balance of the account stored under key.
+func Leaf(key string, balance int64) []byte {
+	b := make([]byte, 1+8+len(key)+8)
+	b[0] = leafPrefix
+	binary.BigEndian.PutUint64(b[1:], uint64(len(key)))
+	copy(b[9:], key)
+	binary.BigEndian.PutUint64(b[9+len(key):], uint64(balance))
+	sum := sha256.Sum256(b)
+	return sum[:]
+}
+
+// Node returns the hash of the parent of left and right.
+func Node(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{nodePrefix})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// Root returns the root of the tree of hashes, the SHA-256 of nothing if
+// there are none.
+func Root(hashes [][]byte) []byte {
+	if len(hashes) == 0 {
+		sum := sha256.Sum256(nil)
+		return sum[:]
+	}
+	for len(hashes) > 1 {
+		hashes = level(hashes)
+	}
+	return hashes[0]
+}
+
+// level returns the parents of hashes.
+func level(hashes [][]byte) [][]byte {
+	next := make([][]byte, 0, (len(hashes)+1)/2)
+	for i := 0; i < len(hashes); i += 2 {
+		if i+1 == len(hashes) {
+			next = append(next, hashes[i])
+		} else {
+			next = append(next, Node(hashes[i], hashes[i+1]))
+		}
+	}
+	return next
+}
+
+// Step is a sibling on the path from a leaf to the root, Left if it is the
+// left child of their parent.
+type Step struct {
+	Hash string `json:"hash"`
+	Left bool   `json:"left,omitempty"`
+}
+
+// Path returns the siblings of hashes[index] from the leaf level up, the
+// levels where it is promoted have no step.
+func Path(hashes [][]byte, index int) []Step {
+	var steps []Step
+	for len(hashes) > 1 {
+		switch {
+		case index%2 == 1:
+			steps = append(steps, Step{Hash: hex.EncodeToString(hashes[index-1]), Left: true})
+		case index+1 < len(hashes):
+			steps = append(steps, Step{Hash: hex.EncodeToString(hashes[index+1])})
+		}
+		hashes, index = level(hashes), index/2
+	}
+	return steps
+}
+
+// Fold returns the root reached from hash along steps.
+func Fold(hash []byte, steps []Step) ([]byte, error) {
+	for i, step := range steps {
+		sibling, err := hex.DecodeString(step.Hash)
+		if err != nil || len(sibling) != sha256.Size {
+			return nil, errors.Errorf("malformed hash at step %d", i)
+		}
+		if step.Left {
+			hash = Node(sibling, hash)
+		} else {
+			hash = Node(hash, sibling)
+		}
+	}
+	return hash, nil
+}
+
+// Proof proves that Key held Balance in the snapshot Snapshot of root Root,
+// the state committed before the transaction Snapshot, at ledger height
+// Height and time Time (unix seconds). Steps is the path from the leaf to
+// the root.
+type Proof struct {
+	Snapshot string `json:"snapshot"`
+	Root     string `json:"root"`
+	Height   uint64 `json:"height"`
+	Time     int64  `json:"time"`
+	Key      string `json:"key"`
+	Balance  int64  `json:"balance"`
+	Steps    []Step `json:"steps"`
+}
+
+// ToBytes marshals the proof as JSON.
+func (p *Proof) ToBytes() ([]byte, error) {
+	return json.Marshal(p)
+}
+
+// FromBytes unmarshals a JSON proof.
+func (p *Proof) FromBytes(d []byte) error {
+	return errors.Wrap(json.Unmarshal(d, p), "malformed proof")
+}
+
+// Verify checks that the proof leads to root, the hex root of the snapshot
+// obtained from a trusted source. Checking against p.Root only shows that
+// the proof is consistent.
+func (p *Proof) Verify(root string) error {
+	trusted, err := hex.DecodeString(root)
+	if err != nil {
+		return errors.Wrap(err, "malformed root, expecting hex")
+	}
+	reached, err := Fold(Leaf(p.Key, p.Balance), p.Steps)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(reached, trusted) {
+		return errors.Errorf("proof of %s leads to root %x, not %s", p.Key, reached, root)
+	}
+	return nil
+}
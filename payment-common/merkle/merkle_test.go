@@ -0,0 +1,117 @@
+package merkle
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"testing"
+)
+
+func leaves(n int) [][]byte {
+	hashes := make([][]byte, n)
+	for i := range hashes {
+		hashes[i] = Leaf(strconv.Itoa(i), int64(100+i))
+	}
+	return hashes
+}
+
+func TestRoot(t *testing.T) {
+	l := leaves(5)
+	empty := sha256.Sum256(nil)
+	tests := []struct {
+		name   string
+		hashes [][]byte
+		want   []byte
+	}{
+		{"empty", nil, empty[:]},
+		{"one", l[:1], l[0]},
+		{"two", l[:2], Node(l[0], l[1])},
+		{"three", l[:3], Node(Node(l[0], l[1]), l[2])},
+		{"four", l[:4], Node(Node(l[0], l[1]), Node(l[2], l[3]))},
+		{"five", l[:5], Node(Node(Node(l[0], l[1]), Node(l[2], l[3])), l[4])},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Root(tt.hashes); !bytes.Equal(got, tt.want) {
+				t.Errorf("Root = %x, want %x", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLeaf(t *testing.T) {
+	// the key length keeps the key and the balance from being shifted into each other
+	if bytes.Equal(Leaf("1", 0x0200), Leaf("12", 0x00)) {
+		t.Error("Leaf does not separate the key from the balance")
+	}
+	if bytes.Equal(Leaf("1", 1), Leaf("1", 2)) {
+		t.Error("Leaf ignores the balance")
+	}
+}
+
+func TestPathFold(t *testing.T) {
+	for n := 1; n <= 17; n++ {
+		hashes := leaves(n)
+		root := Root(hashes)
+		for i := 0; i < n; i++ {
+			got, err := Fold(hashes[i], Path(hashes, i))
+			if err != nil {
+				t.Fatalf("%d leaves, leaf %d: Fold: %v", n, i, err)
+			}
+			if !bytes.Equal(got, root) {
+				t.Errorf("%d leaves, leaf %d: Fold = %x, want root %x", n, i, got, root)
+			}
+		}
+	}
+}
+
+func TestFoldMalformed(t *testing.T) {
+	l := leaves(1)
+	for _, hash := range []string{"zz", hex.EncodeToString(l[0][:8]), ""} {
+		if _, err := Fold(l[0], []Step{{Hash: hash}}); err == nil {
+			t.Errorf("Fold with step hash %q succeeded, want an error", hash)
+		}
+	}
+}
+
+func TestProofVerify(t *testing.T) {
+	hashes := leaves(6)
+	root := hex.EncodeToString(Root(hashes))
+	valid := Proof{Key: "3", Balance: 103, Root: root, Steps: Path(hashes, 3)}
+
+	tests := []struct {
+		name  string
+		proof func(p Proof) Proof
+		root  string
+		ok    bool
+	}{
+		{"valid", func(p Proof) Proof { return p }, root, true},
+		{"other balance", func(p Proof) Proof { p.Balance++; return p }, root, false},
+		{"other key", func(p Proof) Proof { p.Key = "4"; return p }, root, false},
+		{"missing step", func(p Proof) Proof { p.Steps = p.Steps[1:]; return p }, root, false},
+		{"flipped step", func(p Proof) Proof {
+			p.Steps = append([]Step{}, p.Steps...)
+			p.Steps[0].Left = !p.Steps[0].Left
+			return p
+		}, root, false},
+		{"other root", func(p Proof) Proof { return p }, hex.EncodeToString(Root(hashes[:5])), false},
+		{"malformed root", func(p Proof) Proof { return p }, "root", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.proof(valid)
+			d, err := p.ToBytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var decoded Proof
+			if err := decoded.FromBytes(d); err != nil {
+				t.Fatalf("FromBytes(%s): %v", d, err)
+			}
+			if err := decoded.Verify(tt.root); (err == nil) != tt.ok {
+				t.Errorf("Verify = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
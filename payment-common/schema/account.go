@@ -0,0 +1,82 @@
+package schema
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/schema/paymentpb"
+	"github.com/pkg/errors"
+)
+
+// Account is the state stored under an account key. Padding is filler
+// written by createBatch to grow the ledger to a target size. Window is only
+// kept once the account has Limits. Org is the MSP ID of the organization
+// that created the account, empty for accounts created before it was recorded.
+type Account struct {
+	Balance int64        `json:"balance"`
+	Padding []byte       `json:"padding,omitempty"`
+	Limits  *Limits      `json:"limits,omitempty"`
+	Window  *SpendWindow `json:"window,omitempty"`
+	Org     string       `json:"org,omitempty"`
+}
+
+// ToBytes marshals the account as JSON.
+func (a *Account) ToBytes() ([]byte, error) {
+	return json.Marshal(a)
+}
+
+// Encode serializes the account with enc.
+func (a *Account) Encode(enc Encoding) ([]byte, error) {
+	switch enc {
+	case JSON:
+		return a.ToBytes()
+	case Proto:
+		m := &paymentpb.Account{Balance: a.Balance, Padding: a.Padding, Org: a.Org}
+		if a.Limits != nil {
+			m.Limits = &paymentpb.Limits{Daily: a.Limits.Daily, PerTx: a.Limits.PerTx}
+		}
+		if a.Window != nil {
+			m.Window = &paymentpb.SpendWindow{Hour: a.Window.Hour, Buckets: a.Window.Buckets}
+		}
+		return marshalProto(m)
+	default:
+		return nil, errors.Errorf("unsupported encoding %s", enc)
+	}
+}
+
+// FromBytes decodes account state in either encoding.
+func (a *Account) FromBytes(d []byte) error {
+	if EncodingOf(d) == Proto {
+		var m paymentpb.Account
+		if err := unmarshalProto(d, &m); err != nil {
+			return errors.WithMessage(err, "malformed protobuf account")
+		}
+		*a = Account{Balance: m.Balance, Padding: m.Padding, Org: m.Org}
+		if m.Limits != nil {
+			a.Limits = &Limits{Daily: m.Limits.Daily, PerTx: m.Limits.PerTx}
+		}
+		if m.Window != nil {
+			a.Window = &SpendWindow{Hour: m.Window.Hour, Buckets: m.Window.Buckets}
+		}
+		return nil
+	}
+
+	// the public chaincode used to store the balance as a JSON string
+	*a = Account{}
+	type plain Account
+	legacy := struct {
+		*plain
+		Balance json.Number `json:"balance"`
+	}{plain: (*plain)(a)}
+	if err := json.Unmarshal(d, &legacy); err != nil {
+		return errors.Wrap(err, "malformed account")
+	}
+	if legacy.Balance != "" {
+		balance, err := strconv.ParseInt(string(legacy.Balance), 10, 64)
+		if err != nil {
+			return errors.Wrapf(err, "account balance %q is not an integer", legacy.Balance)
+		}
+		a.Balance = balance
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package schema
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+// RoleAttribute is the certificate attribute matched by the Roles of an
+// ACLRule. Fabric CA sets it to the type of the identity, e.g. client or peer.
+const RoleAttribute = "hf.Type"
+
+// ACLDefault is the ACL key of the rule applied to the functions without one.
+const ACLDefault = "*"
+
+// ACLRule allows a function to the members of MSPs. When OUs or Roles are
+// set, the creator's certificate must also carry one of the organizational
+// units, or one of the roles as its RoleAttribute.
+type ACLRule struct {
+	MSPs  []string `json:"msps"`
+	OUs   []string `json:"ous,omitempty"`
+	Roles []string `json:"roles,omitempty"`
+}
+
+// Allows reports whether the rule allows a creator of mspID with the
+// organizational units ous and the role.
+func (r *ACLRule) Allows(mspID string, ous []string, role string) bool {
+	if !contains(r.MSPs, mspID) {
+		return false
+	}
+	if len(r.OUs) > 0 {
+		found := false
+		for _, ou := range ous {
+			if contains(r.OUs, ou) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return len(r.Roles) == 0 || contains(r.Roles, role)
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ACL maps the chaincode function names to their rule. A function without a
+// rule follows the ACLDefault rule, or is open to every channel member when
+// there is none.
+type ACL map[string]ACLRule
+
+// Validate checks that every rule allows at least one MSP.
+func (a ACL) Validate() error {
+	for function, rule := range a {
+		if function == "" {
+			return errors.New("acl: empty function name")
+		}
+		if len(rule.MSPs) == 0 {
+			return errors.Errorf("acl: rule of %s allows no MSP", function)
+		}
+	}
+	return nil
+}
+
+// Rule returns the rule of function, false if the function is open.
+func (a ACL) Rule(function string) (ACLRule, bool) {
+	if rule, ok := a[function]; ok {
+		return rule, true
+	}
+	rule, ok := a[ACLDefault]
+	return rule, ok
+}
+
+// ToBytes marshals the ACL as JSON.
+func (a ACL) ToBytes() ([]byte, error) {
+	return json.Marshal(a)
+}
+
+// DecodeACL strictly decodes and validates a setACL argument.
+func DecodeACL(d []byte) (ACL, error) {
+	var a ACL
+	if err := decodeStrict(d, &a); err != nil {
+		return nil, errors.WithMessage(err, "malformed acl")
+	}
+	if err := a.Validate(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
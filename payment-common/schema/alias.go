@@ -0,0 +1,53 @@
+package schema
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// AliasMark is the character telling an alias from an account key, account
+// keys cannot contain it.
+const AliasMark = "@"
+
+var aliasPattern = regexp.MustCompile(`^[a-z0-9._+-]{1,64}@[a-z0-9.-]{1,64}$`)
+
+// IsAlias reports whether name is an alias rather than an account key.
+func IsAlias(name string) bool {
+	return strings.Contains(name, AliasMark)
+}
+
+// ValidateAlias checks an email-like alias such as alice@bank1, lower case.
+func ValidateAlias(name string) error {
+	if !aliasPattern.MatchString(name) {
+		return errors.Errorf("invalid alias %q, expecting an email-like lower case name", name)
+	}
+	return nil
+}
+
+// Alias maps Name to the key of Account. Owner is the client identity, as
+// returned by the whoami function, allowed to transfer the name.
+type Alias struct {
+	Name    string `json:"name"`
+	Account string `json:"account"`
+	Owner   string `json:"owner"`
+}
+
+// ToBytes marshals the alias as JSON.
+func (a *Alias) ToBytes() ([]byte, error) {
+	return json.Marshal(a)
+}
+
+// FromBytes unmarshals a JSON alias.
+func (a *Alias) FromBytes(d []byte) error {
+	return errors.Wrap(json.Unmarshal(d, a), "malformed alias")
+}
+
+// Identity is the response of the whoami function: the unique id of the
+// client identity and its MSP.
+type Identity struct {
+	ID    string `json:"id"`
+	MSPID string `json:"mspid"`
+}
@@ -0,0 +1,117 @@
+package schema
+
+import (
+	"encoding/json"
+	"regexp"
+
+	"github.com/pkg/errors"
+)
+
+// MaxDecimals bounds the decimals of a registered asset.
+const MaxDecimals = 18
+
+var assetCodeRE = regexp.MustCompile(`^[A-Za-z0-9_-]{1,16}$`)
+
+// ValidateAssetCode checks that code can be used as an asset code, and so as
+// a composite key attribute.
+func ValidateAssetCode(code string) error {
+	if !assetCodeRE.MatchString(code) {
+		return errors.Errorf("malformed asset code %q, expecting 1 to 16 letters, digits, '_' or '-'", code)
+	}
+	return nil
+}
+
+// Asset is the registry entry of an asset. Balances are integers in units of
+// 10^-Decimals of the asset.
+type Asset struct {
+	Code     string `json:"code"`
+	Symbol   string `json:"symbol,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Decimals int    `json:"decimals"`
+}
+
+// Validate checks the asset code and decimals.
+func (a *Asset) Validate() error {
+	if err := ValidateAssetCode(a.Code); err != nil {
+		return err
+	}
+	if a.Decimals < 0 || a.Decimals > MaxDecimals {
+		return errors.Errorf("asset %s: decimals %d out of range [0, %d]", a.Code, a.Decimals, MaxDecimals)
+	}
+	return nil
+}
+
+// ToBytes marshals the asset as JSON.
+func (a *Asset) ToBytes() ([]byte, error) {
+	return json.Marshal(a)
+}
+
+// DecodeAsset strictly decodes and validates a registerAsset argument.
+func DecodeAsset(d []byte) (*Asset, error) {
+	var a Asset
+	if err := decodeStrict(d, &a); err != nil {
+		return nil, errors.WithMessage(err, "malformed asset")
+	}
+	if err := a.Validate(); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// Swap is the argument of the "swap" function: A gives AmountA of AssetA to
+// B and B gives AmountB of AssetB to A, both legs or none. An empty asset is
+// the default asset.
+type Swap struct {
+	Version int    `json:"version"`
+	A       string `json:"a"`
+	AssetA  string `json:"assetA,omitempty"`
+	AmountA int64  `json:"amountA"`
+	B       string `json:"b"`
+	AssetB  string `json:"assetB,omitempty"`
+	AmountB int64  `json:"amountB"`
+}
+
+// ToBytes stamps the current version and marshals the swap.
+func (s *Swap) ToBytes() ([]byte, error) {
+	s.Version = Current
+	return json.Marshal(s)
+}
+
+// Validate requires two distinct accounts, two distinct assets and positive amounts.
+func (s *Swap) Validate() error {
+	if s.A == "" || s.B == "" {
+		return errors.New("swap: missing account")
+	}
+	if s.A == s.B {
+		return errors.Errorf("swap: both legs on account %s", s.A)
+	}
+	for _, asset := range []string{s.AssetA, s.AssetB} {
+		if asset != "" {
+			if err := ValidateAssetCode(asset); err != nil {
+				return errors.WithMessage(err, "swap")
+			}
+		}
+	}
+	if s.AssetA == s.AssetB {
+		return errors.New("swap: both legs in the same asset, use transfer")
+	}
+	if s.AmountA <= 0 || s.AmountB <= 0 {
+		return errors.Errorf("swap: amounts must be positive, got %d and %d", s.AmountA, s.AmountB)
+	}
+	return nil
+}
+
+// DecodeSwap strictly decodes and validates a swap argument.
+func DecodeSwap(d []byte) (*Swap, error) {
+	var s Swap
+	if err := decodeStrict(d, &s); err != nil {
+		return nil, errors.WithMessage(err, "malformed swap")
+	}
+	if s.Version != V2 {
+		return nil, errors.Errorf("unsupported swap version %d", s.Version)
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
@@ -0,0 +1,74 @@
+package schema
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// MaxBatchAccounts bounds the number of accounts created by one createBatch.
+	MaxBatchAccounts = 1000
+	// MaxBatchPadding bounds the filler bytes written by one createBatch.
+	MaxBatchPadding = 8 << 20
+)
+
+// Batch is the argument of the "createBatch" function: accounts First,
+// First+1, ... are created with Amount and Paddings[i] filler bytes each,
+// stored with Encoding ("json" or "proto").
+type Batch struct {
+	Version  int    `json:"version"`
+	First    int64  `json:"first"`
+	Amount   int64  `json:"amount"`
+	Paddings []int  `json:"paddings"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// ToBytes stamps the current version and marshals the batch.
+func (b *Batch) ToBytes() ([]byte, error) {
+	b.Version = Current
+	return json.Marshal(b)
+}
+
+// Validate checks the batch bounds.
+func (b *Batch) Validate() error {
+	if b.First < 0 {
+		return errors.Errorf("batch: negative first account %d", b.First)
+	}
+	if b.Amount <= 0 {
+		return errors.Errorf("batch: amount must be positive, got %d", b.Amount)
+	}
+	if n := len(b.Paddings); n == 0 || n > MaxBatchAccounts {
+		return errors.Errorf("batch: %d accounts out of range [1, %d]", n, MaxBatchAccounts)
+	}
+	total := 0
+	for i, p := range b.Paddings {
+		// bounded before adding, a huge padding would overflow the total
+		if p < 0 || p > MaxBatchPadding {
+			return errors.Errorf("batch: padding %d for account %d out of range [0, %d]", p, b.First+int64(i), MaxBatchPadding)
+		}
+		total += p
+	}
+	if total > MaxBatchPadding {
+		return errors.Errorf("batch: %d padding bytes exceed %d", total, MaxBatchPadding)
+	}
+	if _, err := ParseEncoding(b.Encoding); err != nil {
+		return errors.WithMessage(err, "batch")
+	}
+	return nil
+}
+
+// DecodeBatch strictly decodes and validates a createBatch argument.
+func DecodeBatch(d []byte) (*Batch, error) {
+	var b Batch
+	if err := decodeStrict(d, &b); err != nil {
+		return nil, errors.WithMessage(err, "malformed batch")
+	}
+	if b.Version != V2 {
+		return nil, errors.Errorf("unsupported batch version %d", b.Version)
+	}
+	if err := b.Validate(); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
@@ -0,0 +1,60 @@
+package schema
+
+import (
+	"math"
+	"testing"
+)
+
+func TestBatchValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		batch Batch
+		ok    bool
+	}{
+		{"valid", Batch{First: 0, Amount: 100, Paddings: []int{0, 512, 4096}}, true},
+		{"proto", Batch{Amount: 100, Paddings: []int{1}, Encoding: "proto"}, true},
+		{"padding at the bound", Batch{Amount: 100, Paddings: []int{MaxBatchPadding}}, true},
+		{"negative first", Batch{First: -1, Amount: 100, Paddings: []int{1}}, false},
+		{"zero amount", Batch{Amount: 0, Paddings: []int{1}}, false},
+		{"no accounts", Batch{Amount: 100}, false},
+		{"too many accounts", Batch{Amount: 100, Paddings: make([]int, MaxBatchAccounts+1)}, false},
+		{"negative padding", Batch{Amount: 100, Paddings: []int{10, -1}}, false},
+		{"padding above the bound", Batch{Amount: 100, Paddings: []int{MaxBatchPadding + 1}}, false},
+		{"total above the bound", Batch{Amount: 100, Paddings: []int{MaxBatchPadding, 1}}, false},
+		{"overflowing total", Batch{Amount: 100, Paddings: []int{math.MaxInt64, math.MaxInt64, 1}}, false},
+		{"negative padding hiding a huge one", Batch{Amount: 100, Paddings: []int{math.MaxInt64, math.MinInt64 + 1}}, false},
+		{"unknown encoding", Batch{Amount: 100, Paddings: []int{1}, Encoding: "xml"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.batch.Validate(); (err == nil) != tt.ok {
+				t.Errorf("Validate() = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestDecodeBatch(t *testing.T) {
+	b := &Batch{First: 10, Amount: 100, Paddings: []int{1, 2}}
+	d, err := b.ToBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := DecodeBatch(d)
+	if err != nil {
+		t.Fatalf("DecodeBatch(%s): %v", d, err)
+	}
+	if got.First != 10 || len(got.Paddings) != 2 {
+		t.Errorf("DecodeBatch(%s) = %+v", d, got)
+	}
+
+	for _, d := range []string{
+		`{"version":1,"first":0,"amount":1,"paddings":[1]}`,
+		`{"version":2,"first":0,"amount":1,"paddings":[1],"extra":true}`,
+		`{"version":2,"first":0,"amount":1,"paddings":[-1]}`,
+	} {
+		if _, err := DecodeBatch([]byte(d)); err == nil {
+			t.Errorf("DecodeBatch(%s) succeeded, want an error", d)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package schema
+
+import (
+	"fmt"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/schema/paymentpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+)
+
+// ProtoV1 is the version byte in front of protobuf-encoded payloads and
+// account state. JSON always starts with '{' so it needs no prefix, which is
+// how both sides tell the two encodings apart.
+const ProtoV1 byte = 0x01
+
+// Encoding selects how payloads and account state are serialized.
+type Encoding int
+
+const (
+	// JSON is the original, human readable encoding.
+	JSON Encoding = iota
+	// Proto is the ProtoV1 prefixed protobuf encoding of paymentpb.
+	Proto
+)
+
+func (e Encoding) String() string {
+	switch e {
+	case JSON:
+		return "json"
+	case Proto:
+		return "proto"
+	default:
+		return fmt.Sprintf("Encoding(%d)", int(e))
+	}
+}
+
+// ParseEncoding maps "json" or "proto" to an Encoding. An empty name means JSON.
+func ParseEncoding(name string) (Encoding, error) {
+	switch name {
+	case "", "json":
+		return JSON, nil
+	case "proto":
+		return Proto, nil
+	default:
+		return JSON, errors.Errorf("unknown encoding %q, expecting json or proto", name)
+	}
+}
+
+// EncodingOf reports the encoding of d by looking at its version byte.
+func EncodingOf(d []byte) Encoding {
+	if len(d) > 0 && d[0] == ProtoV1 {
+		return Proto
+	}
+	return JSON
+}
+
+func marshalProto(m proto.Message) ([]byte, error) {
+	b, err := proto.Marshal(m)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return append([]byte{ProtoV1}, b...), nil
+}
+
+func unmarshalProto(d []byte, m proto.Message) error {
+	if EncodingOf(d) != Proto {
+		return errors.New("missing protobuf version byte")
+	}
+	if err := proto.Unmarshal(d[1:], m); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// Encode serializes the payload with enc. JSON payloads are stamped with the
+// current schema version.
+func (p *Payload) Encode(enc Encoding) ([]byte, error) {
+	switch enc {
+	case JSON:
+		return p.ToBytes()
+	case Proto:
+		return marshalProto(&paymentpb.Payload{From: p.From, To: p.To, Amount: p.Amount, Asset: p.Asset})
+	default:
+		return nil, errors.Errorf("unsupported encoding %s", enc)
+	}
+}
+
+func decodeProtoPayload(d []byte) (*Payload, error) {
+	var m paymentpb.Payload
+	if err := unmarshalProto(d, &m); err != nil {
+		return nil, errors.WithMessage(err, "malformed protobuf payload")
+	}
+	if len(m.XXX_unrecognized) != 0 {
+		return nil, errors.New("malformed protobuf payload: unknown fields")
+	}
+	return &Payload{Version: V2, From: m.From, To: m.To, Amount: m.Amount, Asset: m.Asset}, nil
+}
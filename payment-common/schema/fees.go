@@ -0,0 +1,104 @@
+package schema
+
+import (
+	"encoding/json"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// MaxBps is a fee rate of 100%, in basis points.
+const MaxBps = 10000
+
+// FeeSchedule is the fee charged on every transfer: Flat plus Bps basis
+// points of the amount, raised to Min and capped at Max when they are set.
+// The fee is debited from the sender on top of the amount and credited to Account.
+type FeeSchedule struct {
+	Flat    int64  `json:"flat,omitempty"`
+	Bps     int64  `json:"bps,omitempty"`
+	Min     int64  `json:"min,omitempty"`
+	Max     int64  `json:"max,omitempty"`
+	Account string `json:"account,omitempty"`
+}
+
+// Validate checks the schedule bounds.
+func (f *FeeSchedule) Validate() error {
+	if f.Flat < 0 || f.Bps < 0 || f.Min < 0 || f.Max < 0 {
+		return errors.New("fee schedule: values must not be negative")
+	}
+	if f.Bps > MaxBps {
+		return errors.Errorf("fee schedule: rate %d bps is above %d", f.Bps, MaxBps)
+	}
+	if f.Max > 0 && f.Max < f.Min {
+		return errors.Errorf("fee schedule: max %d is below min %d", f.Max, f.Min)
+	}
+	if !f.IsZero() && f.Account == "" {
+		return errors.New("fee schedule: missing fee account")
+	}
+	return nil
+}
+
+// IsZero reports whether the schedule charges nothing.
+func (f *FeeSchedule) IsZero() bool {
+	return f == nil || (f.Flat == 0 && f.Bps == 0 && f.Min == 0)
+}
+
+// Fee returns the fee charged on a transfer of amount.
+func (f *FeeSchedule) Fee(amount int64) int64 {
+	if f.IsZero() {
+		return 0
+	}
+	// the rate part is at most amount, the flat part is added without overflowing
+	fee := amount/MaxBps*f.Bps + amount%MaxBps*f.Bps/MaxBps
+	if f.Flat > math.MaxInt64-fee {
+		fee = math.MaxInt64
+	} else {
+		fee += f.Flat
+	}
+	if fee < f.Min {
+		fee = f.Min
+	}
+	if f.Max > 0 && fee > f.Max {
+		fee = f.Max
+	}
+	return fee
+}
+
+// ToBytes marshals the schedule as JSON.
+func (f *FeeSchedule) ToBytes() ([]byte, error) {
+	return json.Marshal(f)
+}
+
+// DecodeFeeSchedule strictly decodes and validates a setFees argument.
+func DecodeFeeSchedule(d []byte) (*FeeSchedule, error) {
+	var f FeeSchedule
+	if err := decodeStrict(d, &f); err != nil {
+		return nil, errors.WithMessage(err, "malformed fee schedule")
+	}
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// TransferReceipt is the response of a transfer. The sender is debited
+// Amount+Fee, the receiver credited Amount and FeeAccount credited Fee.
+// Fees are only charged on transfers of the default asset.
+type TransferReceipt struct {
+	From       string `json:"from"`
+	To         string `json:"to"`
+	Asset      string `json:"asset,omitempty"`
+	Amount     int64  `json:"amount"`
+	Fee        int64  `json:"fee"`
+	FeeAccount string `json:"feeAccount,omitempty"`
+}
+
+// ToBytes marshals the receipt as JSON.
+func (r *TransferReceipt) ToBytes() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+// FromBytes unmarshals a JSON receipt.
+func (r *TransferReceipt) FromBytes(d []byte) error {
+	return errors.Wrap(json.Unmarshal(d, r), "malformed transfer receipt")
+}
@@ -0,0 +1,58 @@
+package schema
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFee(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule *FeeSchedule
+		amount   int64
+		want     int64
+	}{
+		{"no schedule", nil, 1000, 0},
+		{"zero schedule", &FeeSchedule{Account: "fees"}, 1000, 0},
+		{"flat", &FeeSchedule{Flat: 3, Account: "fees"}, 1000, 3},
+		{"bps", &FeeSchedule{Bps: 25, Account: "fees"}, 1000, 2},
+		{"bps rounded down", &FeeSchedule{Bps: 25, Account: "fees"}, 399, 0},
+		{"flat and bps", &FeeSchedule{Flat: 1, Bps: 100, Account: "fees"}, 1000, 11},
+		{"raised to min", &FeeSchedule{Bps: 25, Min: 5, Account: "fees"}, 1000, 5},
+		{"capped at max", &FeeSchedule{Bps: 100, Max: 7, Account: "fees"}, 1000, 7},
+		{"full rate", &FeeSchedule{Bps: MaxBps, Account: "fees"}, 12345, 12345},
+		{"bps of max amount", &FeeSchedule{Bps: MaxBps, Account: "fees"}, math.MaxInt64, math.MaxInt64},
+		{"flat on max amount saturates", &FeeSchedule{Flat: 1, Bps: MaxBps, Account: "fees"}, math.MaxInt64, math.MaxInt64},
+		{"max flat saturates", &FeeSchedule{Flat: math.MaxInt64, Bps: 1, Account: "fees"}, 1 << 40, math.MaxInt64},
+		{"saturated then capped", &FeeSchedule{Flat: math.MaxInt64, Bps: 1, Max: 9, Account: "fees"}, 1 << 40, 9},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.schedule.Fee(tt.amount); got != tt.want {
+				t.Errorf("Fee(%d) = %d, want %d", tt.amount, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFeeScheduleValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule FeeSchedule
+		ok       bool
+	}{
+		{"zero", FeeSchedule{}, true},
+		{"complete", FeeSchedule{Flat: 1, Bps: 25, Min: 1, Max: 500, Account: "fees"}, true},
+		{"negative", FeeSchedule{Flat: -1, Account: "fees"}, false},
+		{"rate above 100%", FeeSchedule{Bps: MaxBps + 1, Account: "fees"}, false},
+		{"max below min", FeeSchedule{Min: 10, Max: 5, Account: "fees"}, false},
+		{"missing account", FeeSchedule{Flat: 1}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.schedule.Validate(); (err == nil) != tt.ok {
+				t.Errorf("Validate() = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
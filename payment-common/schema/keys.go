@@ -0,0 +1,49 @@
+package schema
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+// KeyState is the state of the AES encryption of the account states. Version
+// is the version of the key sealing the writes, 0 while accounts are stored
+// in clear. While Rotating, the accounts still sealed with the Previous key
+// are re-encrypted by the rotate function, both keys are accepted by reads.
+// The checks identify the keys without revealing them.
+type KeyState struct {
+	Version       int    `json:"version"`
+	Check         string `json:"check,omitempty"`
+	Rotating      bool   `json:"rotating,omitempty"`
+	Previous      int    `json:"previous,omitempty"`
+	PreviousCheck string `json:"previousCheck,omitempty"`
+}
+
+// ToBytes marshals the key state as JSON.
+func (s *KeyState) ToBytes() ([]byte, error) {
+	return json.Marshal(s)
+}
+
+// FromBytes unmarshals a JSON key state.
+func (s *KeyState) FromBytes(d []byte) error {
+	return errors.Wrap(json.Unmarshal(d, s), "malformed key state")
+}
+
+// RotateResult is the response of one rotate call: Rotated accounts of the
+// page were re-encrypted and the next call resumes from Bookmark until Done.
+type RotateResult struct {
+	Version  int    `json:"version"`
+	Rotated  int    `json:"rotated"`
+	Bookmark string `json:"bookmark,omitempty"`
+	Done     bool   `json:"done,omitempty"`
+}
+
+// ToBytes marshals the result as JSON.
+func (r *RotateResult) ToBytes() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+// FromBytes unmarshals a JSON result.
+func (r *RotateResult) FromBytes(d []byte) error {
+	return errors.Wrap(json.Unmarshal(d, r), "malformed rotate result")
+}
@@ -0,0 +1,86 @@
+package schema
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// KYCAttribute is the certificate attribute, issued by Fabric CA, holding the
+// KYC level of a user. A user without it has level 0.
+const KYCAttribute = "kyc.level"
+
+// KYCThreshold requires a KYC level of at least Level for transfers above Amount.
+type KYCThreshold struct {
+	Amount int64 `json:"amount"`
+	Level  int   `json:"level"`
+}
+
+// KYCPolicy caps the transfers a user can sign by the KYC level of the user.
+type KYCPolicy struct {
+	Thresholds []KYCThreshold `json:"thresholds"`
+}
+
+// Validate checks the thresholds.
+func (p *KYCPolicy) Validate() error {
+	amounts := make(map[int64]bool, len(p.Thresholds))
+	for _, t := range p.Thresholds {
+		if t.Amount < 0 {
+			return errors.Errorf("kyc policy: threshold amount must not be negative, got %d", t.Amount)
+		}
+		if t.Level < 1 {
+			return errors.Errorf("kyc policy: required level must be at least 1, got %d", t.Level)
+		}
+		if amounts[t.Amount] {
+			return errors.Errorf("kyc policy: duplicate threshold amount %d", t.Amount)
+		}
+		amounts[t.Amount] = true
+	}
+	return nil
+}
+
+// IsZero reports whether the policy allows every transfer.
+func (p *KYCPolicy) IsZero() bool {
+	return p == nil || len(p.Thresholds) == 0
+}
+
+// Required returns the KYC level required to transfer amount, 0 if none.
+func (p *KYCPolicy) Required(amount int64) int {
+	if p == nil {
+		return 0
+	}
+	level := 0
+	for _, t := range p.Thresholds {
+		if amount > t.Amount && t.Level > level {
+			level = t.Level
+		}
+	}
+	return level
+}
+
+// ToBytes marshals the policy as JSON.
+func (p *KYCPolicy) ToBytes() ([]byte, error) {
+	return json.Marshal(p)
+}
+
+// DecodeKYCPolicy strictly decodes and validates a setKYC argument.
+func DecodeKYCPolicy(d []byte) (*KYCPolicy, error) {
+	var p KYCPolicy
+	if err := decodeStrict(d, &p); err != nil {
+		return nil, errors.WithMessage(err, "malformed kyc policy")
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ParseKYCLevel parses the value of the KYCAttribute.
+func ParseKYCLevel(value string) (int, error) {
+	level, err := strconv.Atoi(value)
+	if err != nil || level < 0 {
+		return 0, errors.Errorf("malformed %s attribute %q", KYCAttribute, value)
+	}
+	return level, nil
+}
@@ -0,0 +1,171 @@
+package schema
+
+import (
+	"encoding/json"
+	"math"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// WindowHours is the length of the rolling spend window, in hourly buckets.
+const WindowHours = 24
+
+// Unlimited is reported by LimitStatus when a limit is not set.
+const Unlimited int64 = -1
+
+// Limits are the spending limits of an account. A zero limit is not enforced.
+type Limits struct {
+	Daily int64 `json:"daily,omitempty"`
+	PerTx int64 `json:"perTx,omitempty"`
+}
+
+// Validate rejects negative limits.
+func (l *Limits) Validate() error {
+	if l.Daily < 0 || l.PerTx < 0 {
+		return errors.Errorf("limits must not be negative, got daily %d, per transaction %d", l.Daily, l.PerTx)
+	}
+	return nil
+}
+
+// IsZero reports whether no limit is set.
+func (l *Limits) IsZero() bool {
+	return l == nil || (l.Daily == 0 && l.PerTx == 0)
+}
+
+// ToBytes marshals the limits as JSON.
+func (l *Limits) ToBytes() ([]byte, error) {
+	return json.Marshal(l)
+}
+
+// DecodeLimits strictly decodes and validates a setLimits argument.
+func DecodeLimits(d []byte) (*Limits, error) {
+	var l Limits
+	if err := decodeStrict(d, &l); err != nil {
+		return nil, errors.WithMessage(err, "malformed limits")
+	}
+	if err := l.Validate(); err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// SpendWindow sums the amounts sent by an account over the last WindowHours
+// hours. Buckets[0] holds the hour Hour (hours since the unix epoch),
+// Buckets[i] the hour Hour-i. Times come from the transaction timestamp, so
+// every endorser computes the same window.
+type SpendWindow struct {
+	Hour    int64   `json:"hour"`
+	Buckets []int64 `json:"buckets"`
+}
+
+func unixHour(now time.Time) int64 {
+	return now.Unix() / int64(time.Hour/time.Second)
+}
+
+// Spent returns the amount sent in the window ending at now.
+func (w *SpendWindow) Spent(now time.Time) int64 {
+	if w == nil {
+		return 0
+	}
+	shift := unixHour(now) - w.Hour
+	if shift < 0 {
+		// a transaction stamped earlier than the newest bucket sees the whole window
+		shift = 0
+	}
+	var total int64
+	for i := int64(0); i+shift < WindowHours && i < int64(len(w.Buckets)); i++ {
+		total = addCapped(total, w.Buckets[i])
+	}
+	return total
+}
+
+// addCapped adds two amounts, capped at math.MaxInt64 instead of overflowing.
+func addCapped(a, b int64) int64 {
+	if b > math.MaxInt64-a {
+		return math.MaxInt64
+	}
+	return a + b
+}
+
+// Add records amount as sent at now, dropping the buckets that fell out of the window.
+func (w *SpendWindow) Add(now time.Time, amount int64) {
+	hour := unixHour(now)
+	buckets := make([]int64, WindowHours)
+	if shift := hour - w.Hour; shift > 0 {
+		for i := int64(0); i+shift < WindowHours && i < int64(len(w.Buckets)); i++ {
+			buckets[i+shift] = w.Buckets[i]
+		}
+		w.Hour = hour
+	} else {
+		copy(buckets, w.Buckets)
+	}
+	w.Buckets = buckets
+
+	// clients do not agree on the clock, count late transactions in their own hour if still in the window
+	if age := w.Hour - hour; age < WindowHours {
+		w.Buckets[age] = addCapped(w.Buckets[age], amount)
+	}
+}
+
+// LimitStatus is returned by the "limits" query.
+// RemainingDaily and MaxTransfer are Unlimited when the matching limit is not set.
+type LimitStatus struct {
+	Limits
+	Spent          int64 `json:"spent"`
+	RemainingDaily int64 `json:"remainingDaily"`
+	MaxTransfer    int64 `json:"maxTransfer"`
+}
+
+// ToBytes marshals the status as JSON.
+func (s *LimitStatus) ToBytes() ([]byte, error) {
+	return json.Marshal(s)
+}
+
+// FromBytes unmarshals a JSON status.
+func (s *LimitStatus) FromBytes(d []byte) error {
+	return errors.Wrap(json.Unmarshal(d, s), "malformed limit status")
+}
+
+// LimitStatus returns the limits of the account and what remains of them at now.
+func (a *Account) LimitStatus(now time.Time) *LimitStatus {
+	s := &LimitStatus{Spent: a.Window.Spent(now), RemainingDaily: Unlimited, MaxTransfer: Unlimited}
+	if a.Limits == nil {
+		return s
+	}
+	s.Limits = *a.Limits
+	if s.Daily > 0 {
+		s.RemainingDaily = s.Daily - s.Spent
+		if s.RemainingDaily < 0 {
+			s.RemainingDaily = 0
+		}
+		s.MaxTransfer = s.RemainingDaily
+	}
+	if s.PerTx > 0 && (s.MaxTransfer == Unlimited || s.PerTx < s.MaxTransfer) {
+		s.MaxTransfer = s.PerTx
+	}
+	return s
+}
+
+// Spend checks amount against the limits of the account and records it in
+// the spend window. Accounts without limits do not keep a window.
+func (a *Account) Spend(now time.Time, amount int64) error {
+	if a.Limits.IsZero() {
+		return nil
+	}
+	if a.Limits.PerTx > 0 && amount > a.Limits.PerTx {
+		return errors.Errorf("amount %d exceeds the per transaction limit %d", amount, a.Limits.PerTx)
+	}
+	if a.Limits.Daily > 0 {
+		// compared without adding, spent+amount may overflow
+		if spent := a.Window.Spent(now); amount > a.Limits.Daily-spent {
+			return errors.Errorf("amount %d exceeds the daily limit %d, %d already spent in the last %d hours",
+				amount, a.Limits.Daily, spent, WindowHours)
+		}
+	}
+	if a.Window == nil {
+		a.Window = &SpendWindow{}
+	}
+	a.Window.Add(now, amount)
+	return nil
+}
@@ -0,0 +1,118 @@
+package schema
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// at returns the time minutes past the hour hour since the unix epoch.
+func at(hour int64, minutes int) time.Time {
+	return time.Unix(hour*3600+int64(minutes)*60, 0)
+}
+
+func TestSpendWindow(t *testing.T) {
+	type add struct {
+		now    time.Time
+		amount int64
+	}
+	tests := []struct {
+		name  string
+		adds  []add
+		now   time.Time
+		spent int64
+	}{
+		{"empty", nil, at(100, 0), 0},
+		{"same hour", []add{{at(100, 0), 10}, {at(100, 59), 5}}, at(100, 59), 15},
+		{"across hours", []add{{at(100, 0), 10}, {at(110, 0), 5}}, at(110, 0), 15},
+		{"last hour of the window", []add{{at(100, 0), 10}}, at(100+WindowHours-1, 0), 10},
+		{"expired", []add{{at(100, 0), 10}, {at(101, 0), 5}}, at(100+WindowHours, 0), 5},
+		{"all expired", []add{{at(100, 0), 10}}, at(200, 0), 0},
+		{"late transaction in its own hour", []add{{at(110, 0), 5}, {at(105, 0), 10}}, at(110, 0), 15},
+		{"late transaction out of the window", []add{{at(200, 0), 5}, {at(100, 0), 10}}, at(200, 0), 5},
+		{"stamped before the newest bucket", []add{{at(110, 0), 5}}, at(105, 0), 5},
+		{"capped", []add{{at(100, 0), math.MaxInt64}, {at(101, 0), math.MaxInt64}}, at(101, 0), math.MaxInt64},
+		{"capped in one bucket", []add{{at(100, 0), math.MaxInt64}, {at(100, 1), 1}}, at(100, 1), math.MaxInt64},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &SpendWindow{}
+			for _, a := range tt.adds {
+				w.Add(a.now, a.amount)
+			}
+			if got := w.Spent(tt.now); got != tt.spent {
+				t.Errorf("Spent = %d, want %d (window %+v)", got, tt.spent, w)
+			}
+		})
+	}
+}
+
+func TestSpend(t *testing.T) {
+	now := at(100, 0)
+	tests := []struct {
+		name   string
+		limits *Limits
+		spent  int64
+		amount int64
+		ok     bool
+	}{
+		{"no limits", nil, 0, math.MaxInt64, true},
+		{"within per transaction", &Limits{PerTx: 10}, 0, 10, true},
+		{"above per transaction", &Limits{PerTx: 10}, 0, 11, false},
+		{"within daily", &Limits{Daily: 100}, 90, 10, true},
+		{"above daily", &Limits{Daily: 100}, 90, 11, false},
+		{"daily already exceeded", &Limits{Daily: 100}, 150, 1, false},
+		{"max amount", &Limits{Daily: 100}, 1, math.MaxInt64, false},
+		{"max amount on max daily", &Limits{Daily: math.MaxInt64}, 1, math.MaxInt64, false},
+		{"max daily", &Limits{Daily: math.MaxInt64}, 1, math.MaxInt64 - 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &Account{Limits: tt.limits}
+			if tt.spent > 0 {
+				a.Window = &SpendWindow{}
+				a.Window.Add(now, tt.spent)
+			}
+			err := a.Spend(now, tt.amount)
+			if (err == nil) != tt.ok {
+				t.Fatalf("Spend(%d) = %v, want ok %v", tt.amount, err, tt.ok)
+			}
+			want := tt.spent
+			if tt.ok && !tt.limits.IsZero() {
+				want = addCapped(tt.spent, tt.amount)
+			}
+			if got := a.Window.Spent(now); got != want {
+				t.Errorf("spent after Spend(%d) = %d, want %d", tt.amount, got, want)
+			}
+		})
+	}
+}
+
+func TestLimitStatus(t *testing.T) {
+	now := at(100, 0)
+	tests := []struct {
+		name      string
+		limits    *Limits
+		spent     int64
+		remaining int64
+		max       int64
+	}{
+		{"no limits", nil, 0, Unlimited, Unlimited},
+		{"per transaction", &Limits{PerTx: 10}, 0, Unlimited, 10},
+		{"daily", &Limits{Daily: 100}, 30, 70, 70},
+		{"daily below per transaction", &Limits{Daily: 100, PerTx: 80}, 30, 70, 70},
+		{"per transaction below daily", &Limits{Daily: 100, PerTx: 20}, 30, 70, 20},
+		{"daily exceeded", &Limits{Daily: 100}, 150, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &Account{Limits: tt.limits, Window: &SpendWindow{}}
+			a.Window.Add(now, tt.spent)
+			s := a.LimitStatus(now)
+			if s.Spent != tt.spent || s.RemainingDaily != tt.remaining || s.MaxTransfer != tt.max {
+				t.Errorf("LimitStatus = spent %d, remaining %d, max %d, want %d, %d, %d",
+					s.Spent, s.RemainingDaily, s.MaxTransfer, tt.spent, tt.remaining, tt.max)
+			}
+		})
+	}
+}
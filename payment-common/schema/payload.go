@@ -0,0 +1,171 @@
+// Package schema holds the wire format shared by the payment chaincode and
+// its clients.
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// V1 is the legacy, unversioned payload: Go field names and the amount
+	// either as a JSON string ("10") or as a number (10).
+	V1 = 1
+	// V2 is the versioned payload with lower case field names.
+	V2 = 2
+
+	// Current is the version written by ToBytes.
+	Current = V2
+)
+
+// Kind tells Decode which invocation the payload belongs to.
+type Kind int
+
+const (
+	// Create is the payload of the "create" function, From must be empty.
+	Create Kind = iota
+	// Transfer is the payload of the "transfer" function.
+	Transfer
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Create:
+		return "create"
+	case Transfer:
+		return "transfer"
+	default:
+		return fmt.Sprintf("Kind(%d)", int(k))
+	}
+}
+
+// Payload is the argument of the create and transfer functions. An empty
+// Asset is the default asset, kept under the plain account key.
+type Payload struct {
+	Version int    `json:"version"`
+	From    string `json:"from,omitempty"`
+	To      string `json:"to"`
+	Amount  int64  `json:"amount"`
+	Asset   string `json:"asset,omitempty"`
+}
+
+// payloadV1 is what the clients sent before the schema was versioned.
+type payloadV1 struct {
+	From   string
+	To     string
+	Amount json.Number
+	Blob   [2]byte // ignored, attachments are stored with putBlob
+}
+
+// ToBytes stamps the current version and marshals the payload.
+func (p *Payload) ToBytes() ([]byte, error) {
+	p.Version = Current
+	return json.Marshal(p)
+}
+
+// Validate enforces the payload policy: an account key to credit, a strictly
+// positive amount, no source account on create and no self-transfer.
+func (p *Payload) Validate(kind Kind) error {
+	if p.To == "" {
+		return errors.Errorf("%s payload: missing destination account", kind)
+	}
+	if p.Amount <= 0 {
+		return errors.Errorf("%s payload: amount must be positive, got %d", kind, p.Amount)
+	}
+	if p.Asset != "" {
+		if err := ValidateAssetCode(p.Asset); err != nil {
+			return errors.WithMessage(err, fmt.Sprintf("%s payload", kind))
+		}
+	}
+
+	switch kind {
+	case Create:
+		if p.From != "" {
+			return errors.Errorf("create payload: unexpected source account %s", p.From)
+		}
+		if IsAlias(p.To) {
+			return errors.Errorf("create payload: account key %s contains %s, which is reserved for aliases", p.To, AliasMark)
+		}
+	case Transfer:
+		if p.From == "" {
+			return errors.New("transfer payload: missing source account")
+		}
+		if p.From == p.To {
+			return errors.Errorf("transfer payload: self-transfer on account %s is not allowed", p.From)
+		}
+	default:
+		return errors.Errorf("unknown payload kind %d", int(kind))
+	}
+	return nil
+}
+
+// Decode is the single entry point for incoming payloads. It detects the
+// encoding and version, rejects unknown fields and validates the result
+// against kind.
+func Decode(d []byte, kind Kind) (*Payload, error) {
+	var p *Payload
+	var err error
+	if EncodingOf(d) == Proto {
+		p, err = decodeProtoPayload(d)
+	} else {
+		p, err = decodeJSONPayload(d)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.Validate(kind); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func decodeJSONPayload(d []byte) (*Payload, error) {
+	var probe struct {
+		Version *int `json:"version"`
+	}
+	if err := json.Unmarshal(d, &probe); err != nil {
+		return nil, errors.Wrap(err, "malformed payload")
+	}
+
+	version := V1
+	if probe.Version != nil {
+		version = *probe.Version
+	}
+
+	var p Payload
+	switch version {
+	case V1:
+		var legacy payloadV1
+		if err := decodeStrict(d, &legacy); err != nil {
+			return nil, errors.WithMessage(err, "malformed v1 payload")
+		}
+		amount, err := legacy.Amount.Int64()
+		if err != nil {
+			return nil, errors.Wrapf(err, "v1 payload: amount %q is not an integer", legacy.Amount)
+		}
+		p = Payload{Version: V1, From: legacy.From, To: legacy.To, Amount: amount}
+	case V2:
+		if err := decodeStrict(d, &p); err != nil {
+			return nil, errors.WithMessage(err, "malformed v2 payload")
+		}
+	default:
+		return nil, errors.Errorf("unsupported payload version %d", version)
+	}
+	return &p, nil
+}
+
+func decodeStrict(d []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(d))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return errors.WithStack(err)
+	}
+	if dec.More() {
+		return errors.New("trailing data after payload")
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package schema
+
+import (
+	"reflect"
+	"testing"
+)
+
+func mustEncode(t *testing.T, p *Payload, enc Encoding) []byte {
+	t.Helper()
+	d, err := p.Encode(enc)
+	if err != nil {
+		t.Fatalf("encode %+v as %s: %v", p, enc, err)
+	}
+	return d
+}
+
+func TestDecode(t *testing.T) {
+	transfer := &Payload{From: "1", To: "2", Amount: 10}
+	proto := mustEncode(t, transfer, Proto)
+
+	tests := []struct {
+		name string
+		data []byte
+		kind Kind
+		want *Payload // nil when Decode must fail
+	}{
+		{"v1 string amount", []byte(`{"From":"1","To":"2","Amount":"10"}`), Transfer,
+			&Payload{Version: V1, From: "1", To: "2", Amount: 10}},
+		{"v1 number amount", []byte(`{"From":"1","To":"2","Amount":10}`), Transfer,
+			&Payload{Version: V1, From: "1", To: "2", Amount: 10}},
+		{"v1 create", []byte(`{"To":"1","Amount":"100"}`), Create,
+			&Payload{Version: V1, To: "1", Amount: 100}},
+		{"v1 fractional amount", []byte(`{"From":"1","To":"2","Amount":"1.5"}`), Transfer, nil},
+		{"v1 unknown field", []byte(`{"From":"1","To":"2","Amount":"10","Memo":"x"}`), Transfer, nil},
+		{"v2", []byte(`{"version":2,"from":"1","to":"2","amount":10}`), Transfer,
+			&Payload{Version: V2, From: "1", To: "2", Amount: 10}},
+		{"v2 asset", []byte(`{"version":2,"from":"1","to":"2","amount":10,"asset":"EUR"}`), Transfer,
+			&Payload{Version: V2, From: "1", To: "2", Amount: 10, Asset: "EUR"}},
+		{"v2 from ToBytes", mustEncode(t, transfer, JSON), Transfer,
+			&Payload{Version: V2, From: "1", To: "2", Amount: 10}},
+		{"v2 unknown field", []byte(`{"version":2,"from":"1","to":"2","amount":10,"memo":"x"}`), Transfer, nil},
+		{"v2 trailing data", []byte(`{"version":2,"from":"1","to":"2","amount":10}{}`), Transfer, nil},
+		{"unsupported version", []byte(`{"version":3,"from":"1","to":"2","amount":10}`), Transfer, nil},
+		{"malformed json", []byte(`{"version":2,`), Transfer, nil},
+		{"proto", proto, Transfer, &Payload{Version: V2, From: "1", To: "2", Amount: 10}},
+		{"proto unknown field", append(append([]byte{}, proto...), 0x78, 0x01), Transfer, nil},
+		{"proto truncated", proto[:len(proto)-1], Transfer, nil},
+		{"zero amount", []byte(`{"version":2,"from":"1","to":"2","amount":0}`), Transfer, nil},
+		{"negative amount", []byte(`{"version":2,"from":"1","to":"2","amount":-1}`), Transfer, nil},
+		{"missing destination", []byte(`{"version":2,"from":"1","amount":10}`), Transfer, nil},
+		{"missing source", []byte(`{"version":2,"to":"2","amount":10}`), Transfer, nil},
+		{"self-transfer", []byte(`{"version":2,"from":"1","to":"1","amount":10}`), Transfer, nil},
+		{"create with source", []byte(`{"version":2,"from":"1","to":"2","amount":10}`), Create, nil},
+		{"create alias key", []byte(`{"version":2,"to":"alice@bank1","amount":10}`), Create, nil},
+		{"malformed asset", []byte(`{"version":2,"from":"1","to":"2","amount":10,"asset":"E R"}`), Transfer, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Decode(tt.data, tt.kind)
+			if tt.want == nil {
+				if err == nil {
+					t.Fatalf("Decode(%q) = %+v, want an error", tt.data, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Decode(%q): %v", tt.data, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Decode(%q) = %+v, want %+v", tt.data, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAccountRoundTrip(t *testing.T) {
+	account := &Account{Balance: 100, Org: "Org1MSP"}
+	for _, enc := range []Encoding{JSON, Proto} {
+		t.Run(enc.String(), func(t *testing.T) {
+			d, err := account.Encode(enc)
+			if err != nil {
+				t.Fatalf("encode: %v", err)
+			}
+			if got := EncodingOf(d); got != enc {
+				t.Errorf("EncodingOf = %s, want %s", got, enc)
+			}
+			var got Account
+			if err := got.FromBytes(d); err != nil {
+				t.Fatalf("FromBytes: %v", err)
+			}
+			if !reflect.DeepEqual(&got, account) {
+				t.Errorf("FromBytes = %+v, want %+v", got, *account)
+			}
+		})
+	}
+}
+
+func TestAccountLegacyBalance(t *testing.T) {
+	tests := []struct {
+		data    string
+		balance int64
+		ok      bool
+	}{
+		{`{"balance":"42"}`, 42, true},
+		{`{"balance":42}`, 42, true},
+		{`{}`, 0, true},
+		{`{"balance":"4.2"}`, 0, false},
+		{`{"balance":"x"}`, 0, false},
+	}
+	for _, tt := range tests {
+		var a Account
+		err := a.FromBytes([]byte(tt.data))
+		if (err == nil) != tt.ok {
+			t.Errorf("FromBytes(%s) error = %v, want ok %v", tt.data, err, tt.ok)
+			continue
+		}
+		if tt.ok && a.Balance != tt.balance {
+			t.Errorf("FromBytes(%s) balance = %d, want %d", tt.data, a.Balance, tt.balance)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package schema
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+// Status of a transfer record.
+const (
+	// Completed is a transfer as executed.
+	Completed = "completed"
+	// Disputed is a transfer waiting for the decision of an admin.
+	Disputed = "disputed"
+	// Reversed is a transfer whose amount was moved back to the sender,
+	// at once or by repaying its Debt.
+	Reversed = "reversed"
+	// Indebted is a transfer approved for reversal while the receiver could
+	// not pay it back, a Debt is open instead until it is repaid.
+	Indebted = "indebted"
+)
+
+// TransferRecord is written by every transfer under its transaction id, and
+// by every executed occurrence of a scheduled payment under its
+// ExecutionTransferID, so that it can be disputed and reversed.
+type TransferRecord struct {
+	ID         string          `json:"id"`
+	Time       int64           `json:"time"`
+	Receipt    TransferReceipt `json:"receipt"`
+	Status     string          `json:"status"`
+	Reason     string          `json:"reason,omitempty"`
+	DisputedBy string          `json:"disputedBy,omitempty"`
+}
+
+// ToBytes marshals the record as JSON.
+func (r *TransferRecord) ToBytes() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+// FromBytes unmarshals a JSON record.
+func (r *TransferRecord) FromBytes(d []byte) error {
+	return errors.Wrap(json.Unmarshal(d, r), "malformed transfer record")
+}
+
+// Debt is opened when a reversal is approved and the receiver of the
+// transfer, the Debtor, cannot pay Amount back to the Creditor. Repaid is
+// the part of Amount paid back since, the debt is closed once it is repaid.
+type Debt struct {
+	Transfer string `json:"transfer"`
+	Time     int64  `json:"time"`
+	Debtor   string `json:"debtor"`
+	Creditor string `json:"creditor"`
+	Asset    string `json:"asset,omitempty"`
+	Amount   int64  `json:"amount"`
+	Repaid   int64  `json:"repaid,omitempty"`
+}
+
+// Owed returns the amount left to repay.
+func (d *Debt) Owed() int64 {
+	return d.Amount - d.Repaid
+}
+
+// FromBytes unmarshals a JSON debt.
+func (d *Debt) FromBytes(b []byte) error {
+	return errors.Wrap(json.Unmarshal(b, d), "malformed debt")
+}
+
+// History events.
+const (
+	EventDisputed = "disputed"
+	EventReversed = "reversed"
+	EventDebt     = "debt"
+	EventRepaid   = "repaid"
+)
+
+// HistoryEntry is a change of a transfer of the account: Event on the
+// transfer Transfer with Counterparty, made by transaction TxID. Amount is
+// the amount of the transfer, the amount paid back for EventRepaid.
+type HistoryEntry struct {
+	TxID         string `json:"txID"`
+	Time         int64  `json:"time"`
+	Event        string `json:"event"`
+	Transfer     string `json:"transfer"`
+	Counterparty string `json:"counterparty"`
+	Asset        string `json:"asset,omitempty"`
+	Amount       int64  `json:"amount"`
+}
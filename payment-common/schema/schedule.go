@@ -0,0 +1,141 @@
+package schema
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// Schedule is the argument of the "schedulePayment" function: Count
+// transfers of Amount from From to To, the first at First and then every
+// Interval seconds. Times are unix seconds compared with the transaction
+// timestamp of tick.
+type Schedule struct {
+	Version  int    `json:"version"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Amount   int64  `json:"amount"`
+	Asset    string `json:"asset,omitempty"`
+	First    int64  `json:"first"`
+	Interval int64  `json:"interval,omitempty"`
+	Count    int    `json:"count"`
+}
+
+// ToBytes stamps the current version and marshals the schedule.
+func (s *Schedule) ToBytes() ([]byte, error) {
+	s.Version = Current
+	return json.Marshal(s)
+}
+
+// Payload returns the transfer executed on every occurrence.
+func (s *Schedule) Payload() *Payload {
+	return &Payload{Version: V2, From: s.From, To: s.To, Amount: s.Amount, Asset: s.Asset}
+}
+
+// Validate applies the transfer policy and checks the timing.
+func (s *Schedule) Validate() error {
+	if err := s.Payload().Validate(Transfer); err != nil {
+		return errors.WithMessage(err, "schedule")
+	}
+	if s.First <= 0 {
+		return errors.Errorf("schedule: first execution time must be positive, got %d", s.First)
+	}
+	if s.Count < 1 {
+		return errors.Errorf("schedule: count must be at least 1, got %d", s.Count)
+	}
+	if s.Count > 1 && s.Interval <= 0 {
+		return errors.Errorf("schedule: a recurring payment needs a positive interval, got %d", s.Interval)
+	}
+	return nil
+}
+
+// DecodeSchedule strictly decodes and validates a schedulePayment argument.
+func DecodeSchedule(d []byte) (*Schedule, error) {
+	var s Schedule
+	if err := decodeStrict(d, &s); err != nil {
+		return nil, errors.WithMessage(err, "malformed schedule")
+	}
+	if s.Version != V2 {
+		return nil, errors.Errorf("unsupported schedule version %d", s.Version)
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// ScheduledPayment is the state of a registered schedule. Every occurrence
+// is either executed or failed, Next is the due time of the next one and 0
+// once the schedule is finished or cancelled.
+type ScheduledPayment struct {
+	ID        string   `json:"id"`
+	Schedule  Schedule `json:"schedule"`
+	Next      int64    `json:"next"`
+	Executed  int      `json:"executed"`
+	Failed    int      `json:"failed"`
+	LastError string   `json:"lastError,omitempty"`
+	Cancelled bool     `json:"cancelled,omitempty"`
+}
+
+// Occurrence returns the index of the next occurrence.
+func (p *ScheduledPayment) Occurrence() int {
+	return p.Executed + p.Failed
+}
+
+// Advance moves Next past the occurrence just executed or failed.
+func (p *ScheduledPayment) Advance() {
+	if p.Occurrence() >= p.Schedule.Count {
+		p.Next = 0
+		return
+	}
+	p.Next += p.Schedule.Interval
+}
+
+// ToBytes marshals the scheduled payment as JSON.
+func (p *ScheduledPayment) ToBytes() ([]byte, error) {
+	return json.Marshal(p)
+}
+
+// FromBytes unmarshals a JSON scheduled payment.
+func (p *ScheduledPayment) FromBytes(d []byte) error {
+	return errors.Wrap(json.Unmarshal(d, p), "malformed scheduled payment")
+}
+
+// Execution is the outcome of one occurrence of a scheduled payment, Receipt
+// when it was executed and Error when it failed. Transfer is the id of the
+// TransferRecord of an executed occurrence, the one to dispute.
+type Execution struct {
+	ID         string           `json:"id"`
+	Occurrence int              `json:"occurrence"`
+	Due        int64            `json:"due"`
+	Receipt    *TransferReceipt `json:"receipt,omitempty"`
+	Transfer   string           `json:"transfer,omitempty"`
+	Error      string           `json:"error,omitempty"`
+}
+
+// ExecutionTransferID returns the id of the TransferRecord of occurrence of
+// the scheduled payment id. A tick executes several payments in one
+// transaction, so its transaction id cannot name their transfers.
+func ExecutionTransferID(id string, occurrence int) string {
+	return id + "/" + strconv.Itoa(occurrence)
+}
+
+// TickResult is the response and the event of a tick.
+type TickResult struct {
+	Time       int64       `json:"time"`
+	Executed   int         `json:"executed"`
+	Failed     int         `json:"failed"`
+	More       bool        `json:"more,omitempty"`
+	Executions []Execution `json:"executions"`
+}
+
+// ToBytes marshals the result as JSON.
+func (r *TickResult) ToBytes() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+// FromBytes unmarshals a JSON result.
+func (r *TickResult) FromBytes(d []byte) error {
+	return errors.Wrap(json.Unmarshal(d, r), "malformed tick result")
+}
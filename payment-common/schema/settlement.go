@@ -0,0 +1,85 @@
+package schema
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// NetPosition is the net obligation of Debtor to Creditor in Asset between
+// two organizations, Amount is positive.
+type NetPosition struct {
+	Debtor   string `json:"debtor"`
+	Creditor string `json:"creditor"`
+	Asset    string `json:"asset,omitempty"`
+	Amount   int64  `json:"amount"`
+}
+
+// NetPositions nets the flows of every org pair and asset. flows maps
+// [2]string{lo, hi} pairs, lo < hi, to the amount per asset paid by the
+// accounts of lo to the accounts of hi, negative when hi paid more.
+func NetPositions(flows map[[2]string]map[string]int64) []NetPosition {
+	positions := []NetPosition{}
+	for pair, assets := range flows {
+		for asset, amount := range assets {
+			switch {
+			case amount > 0:
+				positions = append(positions, NetPosition{Debtor: pair[0], Creditor: pair[1], Asset: asset, Amount: amount})
+			case amount < 0:
+				positions = append(positions, NetPosition{Debtor: pair[1], Creditor: pair[0], Asset: asset, Amount: -amount})
+			}
+		}
+	}
+	sort.Slice(positions, func(i, j int) bool {
+		a, b := positions[i], positions[j]
+		if a.Debtor != b.Debtor {
+			return a.Debtor < b.Debtor
+		}
+		if a.Creditor != b.Creditor {
+			return a.Creditor < b.Creditor
+		}
+		return a.Asset < b.Asset
+	})
+	return positions
+}
+
+// Flows is the inverse of NetPositions.
+func Flows(positions []NetPosition) map[[2]string]map[string]int64 {
+	flows := map[[2]string]map[string]int64{}
+	for _, p := range positions {
+		pair, amount := [2]string{p.Debtor, p.Creditor}, p.Amount
+		if p.Creditor < p.Debtor {
+			pair, amount = [2]string{p.Creditor, p.Debtor}, -amount
+		}
+		if flows[pair] == nil {
+			flows[pair] = map[string]int64{}
+		}
+		flows[pair][p.Asset] += amount
+	}
+	return flows
+}
+
+// Settlement is the record written by settle: the net positions of the
+// cross-org transfers made since the previous settlement, From, up to To.
+// Entries is the number of position records netted, times are unix seconds.
+// More is set while the window holds more records than one settle nets,
+// settle then continues the same settlement.
+type Settlement struct {
+	ID        string        `json:"id"`
+	From      int64         `json:"from"`
+	To        int64         `json:"to"`
+	Entries   int           `json:"entries"`
+	More      bool          `json:"more,omitempty"`
+	Positions []NetPosition `json:"positions"`
+}
+
+// ToBytes marshals the settlement as JSON.
+func (s *Settlement) ToBytes() ([]byte, error) {
+	return json.Marshal(s)
+}
+
+// FromBytes unmarshals a JSON settlement.
+func (s *Settlement) FromBytes(d []byte) error {
+	return errors.Wrap(json.Unmarshal(d, s), "malformed settlement")
+}
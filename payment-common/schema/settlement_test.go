@@ -0,0 +1,25 @@
+package schema
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNetPositions(t *testing.T) {
+	flows := map[[2]string]map[string]int64{
+		{"Org1MSP", "Org2MSP"}: {"": 13, "EUR": -5, "USD": 0},
+		{"Org2MSP", "Org3MSP"}: {"": -1},
+	}
+	want := []NetPosition{
+		{Debtor: "Org1MSP", Creditor: "Org2MSP", Amount: 13},
+		{Debtor: "Org2MSP", Creditor: "Org1MSP", Asset: "EUR", Amount: 5},
+		{Debtor: "Org3MSP", Creditor: "Org2MSP", Amount: 1},
+	}
+	positions := NetPositions(flows)
+	if !reflect.DeepEqual(positions, want) {
+		t.Fatalf("NetPositions = %+v, want %+v", positions, want)
+	}
+	if got := NetPositions(Flows(positions)); !reflect.DeepEqual(got, want) {
+		t.Errorf("NetPositions(Flows) = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,85 @@
+package schema
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+// Snapshot is the Merkle root of the balances of the accounts committed
+// before the transaction ID that started it at Time (unix seconds), built
+// page by page by the snapshot function. Height is the ledger height
+// reported by the client starting it, the snapshot includes at least the
+// blocks below it. The root is the root of the tree of the page roots, it
+// is set once Done.
+type Snapshot struct {
+	ID       string         `json:"id"`
+	Time     int64          `json:"time"`
+	Height   uint64         `json:"height"`
+	Accounts int            `json:"accounts"`
+	Pages    []SnapshotPage `json:"pages"`
+	Root     string         `json:"root,omitempty"`
+	Done     bool           `json:"done,omitempty"`
+}
+
+// SnapshotPage is a page of a snapshot: the Size accounts from First to
+// Last, in key order, and the root of their tree.
+type SnapshotPage struct {
+	First string `json:"first"`
+	Last  string `json:"last"`
+	Size  int    `json:"size"`
+	Root  string `json:"root"`
+}
+
+// ToBytes marshals the snapshot as JSON.
+func (s *Snapshot) ToBytes() ([]byte, error) {
+	return json.Marshal(s)
+}
+
+// FromBytes unmarshals a JSON snapshot.
+func (s *Snapshot) FromBytes(d []byte) error {
+	return errors.Wrap(json.Unmarshal(d, s), "malformed snapshot")
+}
+
+// SnapshotLeaves are the accounts of a snapshot page and their balances
+// when the page was taken.
+type SnapshotLeaves struct {
+	Keys     []string `json:"keys"`
+	Balances []int64  `json:"balances"`
+}
+
+// ToBytes marshals the leaves as JSON.
+func (l *SnapshotLeaves) ToBytes() ([]byte, error) {
+	return json.Marshal(l)
+}
+
+// FromBytes unmarshals JSON leaves.
+func (l *SnapshotLeaves) FromBytes(d []byte) error {
+	if err := json.Unmarshal(d, l); err != nil {
+		return errors.Wrap(err, "malformed snapshot leaves")
+	}
+	if len(l.Keys) != len(l.Balances) {
+		return errors.Errorf("snapshot leaves have %d keys and %d balances", len(l.Keys), len(l.Balances))
+	}
+	return nil
+}
+
+// SnapshotResult is the response of one snapshot call, the next call
+// resumes from Bookmark until Done.
+type SnapshotResult struct {
+	ID       string `json:"id"`
+	Accounts int    `json:"accounts"`
+	Bookmark string `json:"bookmark,omitempty"`
+	Root     string `json:"root,omitempty"`
+	Done     bool   `json:"done,omitempty"`
+}
+
+// ToBytes marshals the result as JSON.
+func (r *SnapshotResult) ToBytes() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+// FromBytes unmarshals a JSON result.
+func (r *SnapshotResult) FromBytes(d []byte) error {
+	return errors.Wrap(json.Unmarshal(d, r), "malformed snapshot result")
+}
@@ -1,6 +1,5 @@
-#dep init
-go build
-export CLIENT_AMOUNT=1
-export ACCOUNTS=2
-export AMOUNT=80
-./payment-demo-pvt
+# the private demo is run by the payment-demo binary against the private
+# chaincode, pass its ID with -cc if it is not instantiated as mycc
+(cd ../payment-demo && go build) || exit 1
+
+../payment-demo/payment-demo bench -config config-payment.yaml -cc mycc -clients 1 -accounts 2 -amount 80 -encoding json # json or proto
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/schema"
+)
+
+// splitList splits a comma separated flag value, "" is an empty list.
+func splitList(s string) []string {
+	var list []string
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			list = append(list, e)
+		}
+	}
+	return list
+}
+
+func logACL(acl schema.ACL) {
+	if len(acl) == 0 {
+		logger.Infof("acl: every function is open to the channel members")
+	}
+	functions := make([]string, 0, len(acl))
+	for function := range acl {
+		functions = append(functions, function)
+	}
+	sort.Strings(functions)
+	for _, function := range functions {
+		rule := acl[function]
+		logger.Infof("acl: %s allowed to MSPs %v, OUs %v, roles %v", function, rule.MSPs, rule.OUs, rule.Roles)
+	}
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// formatAmount renders amount in units of the asset, e.g. 1050 with 2 decimals is "10.50".
+func formatAmount(amount int64, decimals int) string {
+	if decimals == 0 {
+		return strconv.FormatInt(amount, 10)
+	}
+	sign := ""
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	s := fmt.Sprintf("%0*d", decimals+1, amount)
+	return sign + s[:len(s)-decimals] + "." + s[len(s)-decimals:]
+}
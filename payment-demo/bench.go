@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/GingerMoon/fabric_demo/payment-demo/paymentclient"
+	"github.com/pkg/errors"
+)
+
+// BenchOptions is the scenario of the bench command: create Accounts
+// accounts "0" to "Accounts-1" with Balance each, then make Transfers
+// transfers of Amount between pairs of them drawn by Selection. The total of
+// the balances is queried before and after the transfers. The scheduler, if
+// any, holds back the conflicting transfers.
+type BenchOptions struct {
+	Accounts  int
+	Balance   int64
+	Amount    int64
+	Transfers int
+	Selection AccountSelection
+	scheduler *conflictScheduler
+}
+
+// BenchPhase is the throughput of one phase of the bench.
+type BenchPhase struct {
+	Requests  int     `json:"requests"`
+	Failures  int     `json:"failures"`
+	ElapsedMS int64   `json:"elapsedMs"`
+	PerSecond float64 `json:"perSecond"`
+}
+
+// BenchResult is the outcome of the bench. Fees are the fees charged on the
+// transfers and credited to a fee account outside of the bench accounts,
+// TotalAfter plus Fees must equal TotalBefore.
+type BenchResult struct {
+	Clients     int        `json:"clients"`
+	Accounts    int        `json:"accounts"`
+	Selection   string     `json:"selection"`
+	TotalBefore int64      `json:"totalBefore"`
+	TotalAfter  int64      `json:"totalAfter"`
+	Fees        int64      `json:"fees"`
+	Create      BenchPhase `json:"create"`
+	Transfer    BenchPhase `json:"transfer"`
+	Query       BenchPhase `json:"query"`
+	// Latency is set when the clients record their timings.
+	Latency   []LatencyStats `json:"latency,omitempty"`
+	Retries   []RetryStats   `json:"retries,omitempty"`
+	Commits   *CommitReport  `json:"commits,omitempty"`
+	Conflicts *ConflictStats `json:"conflicts,omitempty"`
+}
+
+// Bench runs the scenario of opts with the clients working concurrently.
+func Bench(ctx context.Context, clients []*paymentclient.Client, opts BenchOptions) (*BenchResult, error) {
+	pickers, err := opts.Selection.pickers(opts.Accounts, len(clients))
+	if err != nil {
+		return nil, err
+	}
+	result := &BenchResult{Clients: len(clients), Accounts: opts.Accounts, Selection: opts.Selection.String()}
+
+	result.Create = runPhase(clients, opts.Accounts, func(c *paymentclient.Client, _, i int) error {
+		_, err := c.CreateAccount(ctx, strconv.Itoa(i), "", opts.Balance)
+		return err
+	})
+	logger.Infof("created %d accounts, %d failed", opts.Accounts-result.Create.Failures, result.Create.Failures)
+
+	if result.TotalBefore, _, err = NetworkTotal(ctx, clients, opts.Accounts); err != nil {
+		return nil, err
+	}
+
+	var fees int64
+	result.Transfer = runPhase(clients, opts.Transfers, func(c *paymentclient.Client, w, _ int) error {
+		a, b := pickers[w]()
+		from, to, err := opts.scheduler.take(ctx, strconv.Itoa(a), strconv.Itoa(b))
+		if err != nil {
+			return err
+		}
+		defer opts.scheduler.release(from, to)
+		receipt, err := c.Transfer(ctx, from, to, "", opts.Amount)
+		if err != nil {
+			return err
+		}
+		// a fee credited to one of the accounts stays in the total
+		if !isBenchAccount(receipt.FeeAccount, opts.Accounts) {
+			atomic.AddInt64(&fees, receipt.Fee)
+		}
+		return nil
+	})
+	result.Fees = fees
+	result.Conflicts = opts.scheduler.report()
+
+	if result.TotalAfter, result.Query, err = NetworkTotal(ctx, clients, opts.Accounts); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// NetworkTotal returns the total of the balances of the accounts "0" to
+// "accounts-1". Unless the fee account is one of them, the fees charged on
+// the transfers between them leave the total, see BenchResult.
+func NetworkTotal(ctx context.Context, clients []*paymentclient.Client, accounts int) (int64, BenchPhase, error) {
+	var total int64
+	phase := runPhase(clients, accounts, func(c *paymentclient.Client, _, i int) error {
+		account, err := c.GetAccount(ctx, strconv.Itoa(i), "")
+		if err != nil {
+			return err
+		}
+		atomic.AddInt64(&total, account.Balance)
+		return nil
+	})
+	if phase.Failures != 0 {
+		return 0, phase, errors.Errorf("%d of %d accounts could not be queried", phase.Failures, accounts)
+	}
+	return total, phase, nil
+}
+
+// isBenchAccount reports whether key is one of the accounts "0" to
+// "accounts-1".
+func isBenchAccount(key string, accounts int) bool {
+	i, err := strconv.Atoi(key)
+	return err == nil && strconv.Itoa(i) == key && i >= 0 && i < accounts
+}
+
+// runPhase runs request n times, the client w making the requests w,
+// w+len(clients), ... with the account picker w.
+func runPhase(clients []*paymentclient.Client, n int, request func(c *paymentclient.Client, w, i int) error) BenchPhase {
+	var failures int32
+	var w sync.WaitGroup
+	start := time.Now()
+	for c := range clients {
+		w.Add(1)
+		go func(cc int) {
+			defer w.Done()
+			for i := cc; i < n; i += len(clients) {
+				if err := request(clients[cc], cc, i); err != nil {
+					atomic.AddInt32(&failures, 1)
+					logger.Errorf("%s", err)
+				}
+			}
+		}(c)
+	}
+	w.Wait()
+
+	elapsed := time.Since(start)
+	phase := BenchPhase{Requests: n, Failures: int(failures), ElapsedMS: int64(elapsed / time.Millisecond)}
+	if elapsed > 0 {
+		phase.PerSecond = float64(n) / elapsed.Seconds()
+	}
+	return phase
+}
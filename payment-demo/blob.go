@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/blob"
+	"github.com/GingerMoon/fabric_demo/payment-demo/paymentclient"
+	"github.com/pkg/errors"
+)
+
+// UploadBlob streams the file at path into the ledger as blob id, one chunk
+// per transaction with one goroutine per client. Running it again after a
+// failure only sends the chunks the ledger is still missing.
+func UploadBlob(ctx context.Context, clients []*paymentclient.Client, id, path string, chunkSize int) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer f.Close()
+
+	logger.Infof("hashing %s in chunks of %d bytes", path, chunkSize)
+	manifest, err := blob.NewManifest(id, f, chunkSize)
+	if err != nil {
+		return errors.WithMessage(err, fmt.Sprintf("build manifest of %s failed.", path))
+	}
+
+	// declaring the same manifest again is accepted, so this also resumes
+	if _, err := clients[0].PutBlobManifest(ctx, manifest); err != nil {
+		return err
+	}
+	status, err := clients[0].GetBlobStatus(ctx, id)
+	if err != nil {
+		return err
+	}
+	logger.Infof("blob %s: %d bytes, %d chunks, %d already stored, root %s",
+		id, manifest.Size, len(manifest.Chunks), status.Stored, manifest.Root)
+
+	start := time.Now()
+	todo := make(chan int)
+	failed := make(chan int, len(status.Missing))
+	var w sync.WaitGroup
+	for c := range clients {
+		w.Add(1)
+		go func(cc int) {
+			defer w.Done()
+			buf := make([]byte, chunkSize)
+			for index := range todo {
+				data := buf[:manifest.ChunkLen(index)]
+				if _, err := f.ReadAt(data, int64(index)*int64(chunkSize)); err != nil {
+					logger.Errorf("read chunk %d of %s failed: %s", index, path, err)
+					failed <- index
+					continue
+				}
+				tx, err := clients[cc].PutBlobChunk(ctx, id, index, data)
+				if err != nil {
+					logger.Errorf("%s", err)
+					failed <- index
+					continue
+				}
+				logger.Infof("putBlob(%s) succeeded. blob %s chunk %d (%d bytes).", tx.TxID, id, index, len(data))
+			}
+		}(c)
+	}
+	for _, index := range status.Missing {
+		todo <- index
+	}
+	close(todo)
+	w.Wait()
+	close(failed)
+
+	elapsed := time.Since(start)
+	if n := len(failed); n != 0 {
+		return errors.Errorf("blob %s: %d of %d chunks failed, run the upload again to resume", id, n, len(status.Missing))
+	}
+	logger.Infof("blob %s: uploaded %d chunks in %v", id, len(status.Missing), elapsed)
+	return nil
+}
+
+// DownloadBlob reassembles blob id into the file at path, verifying every
+// chunk against the manifest and the root hash of the result.
+func DownloadBlob(ctx context.Context, clients []*paymentclient.Client, id, path string) error {
+	status, err := clients[0].GetBlobStatus(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !status.Complete() {
+		return errors.Errorf("blob %s is incomplete: %d chunks missing", id, len(status.Missing))
+	}
+	manifest := status.Manifest
+
+	f, err := os.Create(path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer f.Close()
+
+	todo := make(chan int)
+	errCh := make(chan error, len(manifest.Chunks))
+	var w sync.WaitGroup
+	for c := range clients {
+		w.Add(1)
+		go func(cc int) {
+			defer w.Done()
+			for index := range todo {
+				data, err := clients[cc].GetBlobChunk(ctx, id, index)
+				if err == nil {
+					err = manifest.VerifyChunk(index, data)
+				}
+				if err == nil {
+					_, err = f.WriteAt(data, int64(index)*int64(manifest.ChunkSize))
+				}
+				if err != nil {
+					errCh <- errors.WithMessage(err, fmt.Sprintf("chunk %d", index))
+				}
+			}
+		}(c)
+	}
+	for index := range manifest.Chunks {
+		todo <- index
+	}
+	close(todo)
+	w.Wait()
+	close(errCh)
+
+	if err, ok := <-errCh; ok {
+		return errors.WithMessage(err, fmt.Sprintf("download of blob %s failed.", id))
+	}
+
+	if _, err := f.Seek(0, 0); err != nil {
+		return errors.WithStack(err)
+	}
+	check, err := blob.NewManifest(id, f, manifest.ChunkSize)
+	if err != nil {
+		return err
+	}
+	if check.Root != manifest.Root {
+		return errors.Errorf("blob %s: reassembled root %s does not match manifest %s", id, check.Root, manifest.Root)
+	}
+	logger.Infof("blob %s: %d bytes written to %s, root %s verified", id, manifest.Size, path, manifest.Root)
+	return nil
+}
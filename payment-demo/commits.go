@@ -0,0 +1,152 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/GingerMoon/fabric_demo/payment-demo/paymentclient"
+)
+
+// commitWait bounds the wait for the blocks of the last transactions of a
+// run, their status event may come before the block event.
+const commitWait = 10 * time.Second
+
+// validCode is the validation code of a committed transaction.
+const validCode = "VALID"
+
+// BlockCommits counts the validation codes of the transactions of the run in
+// one block, Foreign counts the other transactions of the block.
+type BlockCommits struct {
+	Number  uint64         `json:"number"`
+	Codes   map[string]int `json:"codes"`
+	Foreign int            `json:"foreign,omitempty"`
+}
+
+// CommitReport is the outcome of the transactions submitted during a run, as
+// seen in the blocks. Invalid breaks the invalidated transactions down by
+// reason, Missing counts the ones not seen in any block.
+type CommitReport struct {
+	Submitted int            `json:"submitted"`
+	Codes     map[string]int `json:"codes"`
+	Invalid   map[string]int `json:"invalid"`
+	Missing   int            `json:"missing"`
+	Foreign   int            `json:"foreign"`
+	Blocks    []BlockCommits `json:"blocks"`
+}
+
+// commitTracker matches the transactions submitted by the clients with the
+// blocks, and records their submit-to-commit latency.
+type commitTracker struct {
+	sync.Mutex
+	latency   *LatencyRecorder
+	watch     *paymentclient.BlockWatch
+	pending   map[string]time.Time
+	report    CommitReport
+	committed chan struct{}
+}
+
+// newCommitTracker adds the submission hook to the options of the clients of
+// the command, the latencies are recorded in latency.
+func newCommitTracker(fs *commandFlags, latency *LatencyRecorder) *commitTracker {
+	t := &commitTracker{latency: latency, pending: map[string]time.Time{}, committed: make(chan struct{}, 1),
+		report: CommitReport{Codes: map[string]int{}, Invalid: map[string]int{}}}
+	fs.options = append(fs.options, paymentclient.WithSubmissions(t.submitted))
+	return t
+}
+
+func (t *commitTracker) submitted(txID string, at time.Time) {
+	t.Lock()
+	defer t.Unlock()
+	t.pending[txID] = at
+	t.report.Submitted++
+}
+
+func (t *commitTracker) block(b *paymentclient.Block) {
+	t.Lock()
+	defer t.Unlock()
+	commits := BlockCommits{Number: b.Number, Codes: map[string]int{}}
+	for _, tx := range b.Transactions {
+		at, ok := t.pending[tx.TxID]
+		if !ok {
+			commits.Foreign++
+			continue
+		}
+		delete(t.pending, tx.TxID)
+		commits.Codes[tx.Code]++
+		t.report.Codes[tx.Code]++
+		if tx.Code != validCode {
+			t.report.Invalid[tx.Code]++
+		}
+		t.latency.RecordDuration("transactions", "submit-to-commit", b.Received.Sub(at))
+	}
+	t.report.Foreign += commits.Foreign
+	if len(commits.Codes) == 0 {
+		return
+	}
+	t.report.Blocks = append(t.report.Blocks, commits)
+	logger.Debugf("block %d: %v, %d other transactions", b.Number, commits.Codes, commits.Foreign)
+	select {
+	case t.committed <- struct{}{}:
+	default:
+	}
+}
+
+// start watches the blocks from now on.
+func (t *commitTracker) start(c *paymentclient.Client) error {
+	watch, err := c.WatchBlocks(t.block)
+	if err != nil {
+		return err
+	}
+	t.watch = watch
+	return nil
+}
+
+// stop waits up to commitWait for the blocks of the pending transactions and
+// returns the report.
+func (t *commitTracker) stop() *CommitReport {
+	timeout := time.After(commitWait)
+	for t.pendingCount() != 0 {
+		select {
+		case <-t.committed:
+			continue
+		case <-timeout:
+		}
+		break
+	}
+	t.watch.Stop()
+
+	t.Lock()
+	defer t.Unlock()
+	t.report.Missing = len(t.pending)
+	return &t.report
+}
+
+func (t *commitTracker) pendingCount() int {
+	t.Lock()
+	defer t.Unlock()
+	return len(t.pending)
+}
+
+// print prints the validation codes of the run, the invalid ones first.
+func (r *CommitReport) print(out *printer) error {
+	codes := make([]string, 0, len(r.Codes))
+	for code := range r.Codes {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		if (codes[i] == validCode) != (codes[j] == validCode) {
+			return codes[j] == validCode
+		}
+		return r.Codes[codes[i]] > r.Codes[codes[j]]
+	})
+	rows := make([][]string, 0, len(codes)+1)
+	for _, code := range codes {
+		rows = append(rows, []string{code, strconv.Itoa(r.Codes[code])})
+	}
+	if r.Missing != 0 {
+		rows = append(rows, []string{"(not in a block)", strconv.Itoa(r.Missing)})
+	}
+	return out.table([]string{"validation code", "transactions"}, rows)
+}
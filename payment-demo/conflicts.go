@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ConflictStats is the work of a conflictScheduler during a run. Delayed
+// counts the transfers that waited for an account of an uncommitted one,
+// Reordered the ones run before an older transfer still waiting.
+type ConflictStats struct {
+	Scheduled int     `json:"scheduled"`
+	Delayed   int     `json:"delayed"`
+	Reordered int     `json:"reordered"`
+	MeanWait  float64 `json:"meanWaitMs"`
+	MaxWait   float64 `json:"maxWaitMs"`
+	// MaxLocked is the highest number of accounts of uncommitted transfers.
+	MaxLocked int `json:"maxLocked"`
+
+	totalWait time.Duration
+}
+
+// pendingTransfer is a transfer waiting in a conflictScheduler.
+type pendingTransfer struct {
+	from, to string
+	queued   time.Time
+	blocked  bool
+}
+
+// conflictScheduler keeps two transfers touching the same account from being
+// in flight together, as the second one would fail validation with an
+// MVCC_READ_CONFLICT: a transfer waits until the transfers holding its
+// accounts are committed, the first waiting transfer whose accounts are free
+// runs first. A nil scheduler runs every transfer right away.
+type conflictScheduler struct {
+	sync.Mutex
+	latency  *LatencyRecorder
+	locked   map[string]bool
+	queue    []*pendingTransfer
+	released chan struct{}
+	stats    ConflictStats
+}
+
+func newConflictScheduler(latency *LatencyRecorder) *conflictScheduler {
+	return &conflictScheduler{latency: latency, locked: map[string]bool{}, released: make(chan struct{})}
+}
+
+// take queues the transfer from one account to another and returns the
+// transfer to run once its accounts are locked, that one or an older one
+// which has become free. The caller releases it after its commit.
+func (s *conflictScheduler) take(ctx context.Context, from, to string) (string, string, error) {
+	if s == nil {
+		return from, to, nil
+	}
+	s.Lock()
+	own := &pendingTransfer{from: from, to: to, queued: time.Now()}
+	s.queue = append(s.queue, own)
+	for {
+		for i, t := range s.queue {
+			if s.locked[t.from] || s.locked[t.to] {
+				t.blocked = true
+				continue
+			}
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			s.locked[t.from], s.locked[t.to] = true, true
+			s.scheduled(t, i != 0)
+			s.Unlock()
+			return t.from, t.to, nil
+		}
+		released := s.released
+		s.Unlock()
+		select {
+		case <-released:
+		case <-ctx.Done():
+			s.Lock()
+			defer s.Unlock()
+			for i, t := range s.queue {
+				if t == own {
+					s.queue = append(s.queue[:i], s.queue[i+1:]...)
+					break
+				}
+			}
+			return "", "", ctx.Err()
+		}
+		s.Lock()
+	}
+}
+
+func (s *conflictScheduler) scheduled(t *pendingTransfer, reordered bool) {
+	wait := time.Since(t.queued)
+	s.stats.Scheduled++
+	if t.blocked {
+		s.stats.Delayed++
+	}
+	if reordered {
+		s.stats.Reordered++
+	}
+	s.stats.totalWait += wait
+	if ms := float64(wait) / float64(time.Millisecond); ms > s.stats.MaxWait {
+		s.stats.MaxWait = ms
+	}
+	if len(s.locked) > s.stats.MaxLocked {
+		s.stats.MaxLocked = len(s.locked)
+	}
+	if s.latency != nil {
+		s.latency.RecordDuration("transfer", "schedule", wait)
+	}
+}
+
+// release unlocks the accounts of a transfer taken, committed or failed, and
+// wakes up the waiting transfers.
+func (s *conflictScheduler) release(from, to string) {
+	if s == nil {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	delete(s.locked, from)
+	delete(s.locked, to)
+	close(s.released)
+	s.released = make(chan struct{})
+}
+
+// report returns the stats of the run, nil without a scheduler.
+func (s *conflictScheduler) report() *ConflictStats {
+	if s == nil {
+		return nil
+	}
+	s.Lock()
+	defer s.Unlock()
+	stats := s.stats
+	if stats.Scheduled != 0 {
+		stats.MeanWait = float64(stats.totalWait) / float64(time.Millisecond) / float64(stats.Scheduled)
+	}
+	return &stats
+}
+
+// conflictFlags defines the -avoidconflicts flag of a command, the returned
+// function gives its scheduler once the flags are parsed, nil if not set.
+func conflictFlags(fs *commandFlags, latency *LatencyRecorder) func() *conflictScheduler {
+	avoid := fs.Bool("avoidconflicts", false, "hold back the transfers touching an account of an uncommitted one")
+	return func() *conflictScheduler {
+		if !*avoid {
+			return nil
+		}
+		return newConflictScheduler(latency)
+	}
+}
+
+// print prints the stats of the scheduler, if any.
+func (c *ConflictStats) print(out *printer) error {
+	if c == nil {
+		return nil
+	}
+	f := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', 3, 64)
+	}
+	return out.table([]string{"scheduled", "delayed", "reordered", "mean_wait_ms", "max_wait_ms", "max_locked"},
+		[][]string{{strconv.Itoa(c.Scheduled), strconv.Itoa(c.Delayed), strconv.Itoa(c.Reordered),
+			f(c.MeanWait), f(c.MaxWait), strconv.Itoa(c.MaxLocked)}})
+}
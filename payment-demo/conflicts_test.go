@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// taken is the outcome of a take running in its own goroutine.
+type taken struct {
+	from, to string
+	err      error
+}
+
+func takeAsync(ctx context.Context, s *conflictScheduler, from, to string) <-chan taken {
+	c := make(chan taken, 1)
+	takeInto(ctx, s, from, to, c)
+	return c
+}
+
+// takeInto takes the transfer in its own goroutine and sends the outcome to c.
+func takeInto(ctx context.Context, s *conflictScheduler, from, to string, c chan<- taken) {
+	go func() {
+		from, to, err := s.take(ctx, from, to)
+		c <- taken{from, to, err}
+	}()
+}
+
+// waitQueued waits until n transfers are waiting in s.
+func waitQueued(t *testing.T, s *conflictScheduler, n int) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		s.Lock()
+		queued := len(s.queue)
+		s.Unlock()
+		if queued == n {
+			return
+		}
+	}
+	t.Fatalf("%d transfers never queued", n)
+}
+
+func expectTaken(t *testing.T, c <-chan taken, from, to string) {
+	t.Helper()
+	select {
+	case got := <-c:
+		if got.err != nil || got.from != from || got.to != to {
+			t.Fatalf("take = %s -> %s, %v, want %s -> %s", got.from, got.to, got.err, from, to)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("transfer %s -> %s not taken", from, to)
+	}
+}
+
+func TestConflictSchedulerNil(t *testing.T) {
+	var s *conflictScheduler
+	from, to, err := s.take(context.Background(), "1", "2")
+	if err != nil || from != "1" || to != "2" {
+		t.Errorf("take on a nil scheduler = %s -> %s, %v", from, to, err)
+	}
+	s.release("1", "2")
+	if s.report() != nil {
+		t.Error("report of a nil scheduler is not nil")
+	}
+}
+
+func TestConflictScheduler(t *testing.T) {
+	ctx := context.Background()
+	latency := NewLatencyRecorder()
+	s := newConflictScheduler(latency)
+
+	expectTaken(t, takeAsync(ctx, s, "1", "2"), "1", "2")
+	// 2 -> 3 waits for the commit of 1 -> 2, 4 -> 5 goes ahead of it
+	blocked := takeAsync(ctx, s, "2", "3")
+	waitQueued(t, s, 1)
+	expectTaken(t, takeAsync(ctx, s, "4", "5"), "4", "5")
+	select {
+	case got := <-blocked:
+		t.Fatalf("2 -> 3 taken while 2 is locked: %+v", got)
+	default:
+	}
+
+	s.release("1", "2")
+	expectTaken(t, blocked, "2", "3")
+	s.release("4", "5")
+	s.release("2", "3")
+
+	stats := s.report()
+	want := ConflictStats{Scheduled: 3, Delayed: 1, Reordered: 1, MaxLocked: 4}
+	if stats.Scheduled != want.Scheduled || stats.Delayed != want.Delayed || stats.Reordered != want.Reordered ||
+		stats.MaxLocked != want.MaxLocked {
+		t.Errorf("report = %+v, want %+v", *stats, want)
+	}
+	if len(s.locked) != 0 {
+		t.Errorf("accounts %v still locked", s.locked)
+	}
+	if n := latency.Stats(); len(n) != 1 || n[0].Phase != "schedule" || n[0].Count != 3 {
+		t.Errorf("latency stats %+v, want 3 schedule waits", n)
+	}
+}
+
+func TestConflictSchedulerReorder(t *testing.T) {
+	ctx := context.Background()
+	s := newConflictScheduler(nil)
+
+	expectTaken(t, takeAsync(ctx, s, "1", "2"), "1", "2")
+	expectTaken(t, takeAsync(ctx, s, "3", "4"), "3", "4")
+	// a waiting take may return any transfer that became free, so both
+	// report to the same channel
+	waiting := make(chan taken, 2)
+	takeInto(ctx, s, "2", "5", waiting)
+	waitQueued(t, s, 1)
+	takeInto(ctx, s, "4", "6", waiting)
+	waitQueued(t, s, 2)
+
+	// releasing 3 -> 4 frees the second waiting transfer, not the first
+	s.release("3", "4")
+	expectTaken(t, waiting, "4", "6")
+	s.release("1", "2")
+	expectTaken(t, waiting, "2", "5")
+
+	if stats := s.report(); stats.Delayed != 2 || stats.Reordered != 1 {
+		t.Errorf("report = %+v, want 2 delayed and 1 reordered", *stats)
+	}
+}
+
+func TestConflictSchedulerCancel(t *testing.T) {
+	s := newConflictScheduler(nil)
+	expectTaken(t, takeAsync(context.Background(), s, "1", "2"), "1", "2")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	blocked := takeAsync(ctx, s, "2", "3")
+	waitQueued(t, s, 1)
+	cancel()
+	select {
+	case got := <-blocked:
+		if got.err != context.Canceled {
+			t.Fatalf("cancelled take = %+v, want %v", got, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("cancelled take still waiting")
+	}
+	if len(s.queue) != 0 {
+		t.Errorf("%d transfers still queued after the cancellation", len(s.queue))
+	}
+
+	s.release("1", "2")
+	expectTaken(t, takeAsync(context.Background(), s, "2", "3"), "2", "3")
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/GingerMoon/fabric_demo/payment-demo/paymentclient"
+	"github.com/pkg/errors"
+)
+
+// DupOptions is the scenario of the stress-dup command: Transactions
+// transfers of Amount between pairs of the existing accounts "0" to
+// "Accounts-1" drawn by Selection, each endorsed and signed once, then
+// broadcast as is. Every
+// envelope is sent Copies times in a row or, with a Ratio, each broadcast
+// resends an envelope already sent with probability Ratio instead of the next
+// one. Concurrency bounds the broadcasts in flight.
+type DupOptions struct {
+	Accounts     int
+	Transactions int
+	Amount       int64
+	Copies       int
+	Ratio        float64
+	Concurrency  int
+	Selection    AccountSelection
+}
+
+// DupResult is the outcome of a stress-dup run. Accepted and Rejected count
+// the copies by the answer of the orderer, Codes by the validation code the
+// peer gave them in a block, and Missing the accepted ones seen in no block.
+// Committed counts the transactions with a valid copy, MultipleValid the ones
+// with more than one, which the peer must never report.
+type DupResult struct {
+	Mode          string         `json:"mode"`
+	Selection     string         `json:"selection"`
+	Transactions  int            `json:"transactions"`
+	Endorse       BenchPhase     `json:"endorse"`
+	Broadcasts    int            `json:"broadcasts"`
+	Duplicates    int            `json:"duplicates"`
+	Accepted      int            `json:"accepted"`
+	Rejected      int            `json:"rejected"`
+	ElapsedMS     int64          `json:"elapsedMs"`
+	PerSecond     float64        `json:"perSecond"`
+	Codes         map[string]int `json:"codes"`
+	Missing       int            `json:"missing"`
+	Committed     int            `json:"committed"`
+	MultipleValid int            `json:"multipleValid"`
+	Blocks        []BlockCommits `json:"blocks"`
+	// Latency is set when the phases are recorded.
+	Latency []LatencyStats `json:"latency,omitempty"`
+}
+
+// dupCopy is a broadcast of a stress-dup run, the first one of an envelope
+// is the original.
+type dupCopy struct {
+	envelope  *paymentclient.Envelope
+	duplicate bool
+}
+
+// dupTracker matches the copies accepted by the orderer with the blocks.
+type dupTracker struct {
+	sync.Mutex
+	latency *LatencyRecorder
+	sent    map[string]time.Time
+	valid   map[string]int
+	seen    int
+	result  *DupResult
+	changed chan struct{}
+}
+
+// sending registers the transaction of a copy before the orderer answers, its
+// block may come first.
+func (t *dupTracker) sending(c dupCopy, at time.Time) {
+	t.Lock()
+	defer t.Unlock()
+	if _, ok := t.sent[c.envelope.TxID]; !ok {
+		t.sent[c.envelope.TxID] = at
+	}
+}
+
+func (t *dupTracker) broadcast(c dupCopy, at time.Time, err error) {
+	operation := "original"
+	if c.duplicate {
+		operation = "duplicate"
+	}
+	t.latency.RecordDuration(operation, "broadcast", time.Since(at))
+	t.Lock()
+	defer t.Unlock()
+	if err != nil {
+		t.result.Rejected++
+		logger.Debugf("%s", err)
+		return
+	}
+	t.result.Accepted++
+}
+
+func (t *dupTracker) block(b *paymentclient.Block) {
+	t.Lock()
+	defer t.Unlock()
+	commits := BlockCommits{Number: b.Number, Codes: map[string]int{}}
+	for _, tx := range b.Transactions {
+		at, ok := t.sent[tx.TxID]
+		if !ok {
+			commits.Foreign++
+			continue
+		}
+		t.seen++
+		commits.Codes[tx.Code]++
+		t.result.Codes[tx.Code]++
+		if tx.Code != validCode {
+			continue
+		}
+		switch t.valid[tx.TxID]++; t.valid[tx.TxID] {
+		case 1:
+			t.result.Committed++
+			t.latency.RecordDuration("original", "submit-to-commit", b.Received.Sub(at))
+		case 2:
+			t.result.MultipleValid++
+		}
+	}
+	if len(commits.Codes) == 0 {
+		return
+	}
+	t.result.Blocks = append(t.result.Blocks, commits)
+	logger.Debugf("block %d: %v, %d other transactions", b.Number, commits.Codes, commits.Foreign)
+	select {
+	case t.changed <- struct{}{}:
+	default:
+	}
+}
+
+// wait waits for the blocks of the accepted copies, up to commitWait after
+// the last block.
+func (t *dupTracker) wait() {
+	for {
+		t.Lock()
+		missing := t.result.Accepted - t.seen
+		t.Unlock()
+		if missing <= 0 {
+			return
+		}
+		select {
+		case <-t.changed:
+		case <-time.After(commitWait):
+			return
+		}
+	}
+}
+
+// RunDup runs the scenario of opts: it endorses the transactions with the
+// clients, then broadcasts their copies while following the blocks to see how
+// the peer reports each of them.
+func RunDup(ctx context.Context, clients []*paymentclient.Client, opts DupOptions, latency *LatencyRecorder) (*DupResult, error) {
+	if opts.Ratio < 0 || opts.Ratio >= 1 || (opts.Ratio == 0 && opts.Copies < 1) || opts.Concurrency < 1 {
+		return nil, errors.Errorf("stress-dup needs at least 1 copy or a ratio in [0, 1), and a positive concurrency, got %d, %v and %d",
+			opts.Copies, opts.Ratio, opts.Concurrency)
+	}
+	pickers, err := opts.Selection.pickers(opts.Accounts, len(clients))
+	if err != nil {
+		return nil, err
+	}
+	result := &DupResult{Mode: strconv.Itoa(opts.Copies) + " copies", Selection: opts.Selection.String(),
+		Transactions: opts.Transactions, Codes: map[string]int{}}
+	if opts.Ratio > 0 {
+		result.Mode = strconv.FormatFloat(opts.Ratio, 'f', -1, 64) + " duplicate ratio"
+	}
+
+	envelopes := make([]*paymentclient.Envelope, opts.Transactions)
+	result.Endorse = runPhase(clients, opts.Transactions, func(c *paymentclient.Client, w, i int) error {
+		from, to := pickers[w]()
+		start := time.Now()
+		e, err := c.EndorseTransfer(ctx, strconv.Itoa(from), strconv.Itoa(to), "", opts.Amount)
+		if err != nil {
+			return err
+		}
+		latency.RecordDuration("original", "endorse", time.Since(start))
+		envelopes[i] = e
+		return nil
+	})
+	logger.Infof("endorsed %d transactions, %d failed", opts.Transactions-result.Endorse.Failures, result.Endorse.Failures)
+	endorsed := envelopes[:0]
+	for _, e := range envelopes {
+		if e != nil {
+			endorsed = append(endorsed, e)
+		}
+	}
+
+	// the copies of an envelope follow each other, or the duplicates are
+	// mixed with the originals
+	var copies []dupCopy
+	if opts.Ratio == 0 {
+		for _, e := range endorsed {
+			for i := 0; i < opts.Copies; i++ {
+				copies = append(copies, dupCopy{e, i != 0})
+			}
+		}
+	} else {
+		// the generator of the worker after the last one
+		r := opts.Selection.rand(len(clients))
+		for next := 0; next < len(endorsed); {
+			if next != 0 && r.Float64() < opts.Ratio {
+				copies = append(copies, dupCopy{endorsed[r.Intn(next)], true})
+				continue
+			}
+			copies = append(copies, dupCopy{endorsed[next], false})
+			next++
+		}
+	}
+
+	t := &dupTracker{latency: latency, sent: map[string]time.Time{}, valid: map[string]int{}, result: result,
+		changed: make(chan struct{}, 1)}
+	watch, err := clients[0].WatchBlocks(t.block)
+	if err != nil {
+		return nil, err
+	}
+	queue := make(chan dupCopy)
+	var w sync.WaitGroup
+	for i := 0; i < opts.Concurrency; i++ {
+		w.Add(1)
+		go func() {
+			defer w.Done()
+			for c := range queue {
+				at := time.Now()
+				t.sending(c, at)
+				_, err := c.envelope.Broadcast(ctx)
+				t.broadcast(c, at, err)
+			}
+		}()
+	}
+	start := time.Now()
+	for _, c := range copies {
+		if ctx.Err() != nil {
+			break
+		}
+		queue <- c
+		result.Broadcasts++
+		if c.duplicate {
+			result.Duplicates++
+		}
+	}
+	close(queue)
+	w.Wait()
+	elapsed := time.Since(start)
+	t.wait()
+	watch.Stop()
+
+	t.Lock()
+	defer t.Unlock()
+	result.ElapsedMS = int64(elapsed / time.Millisecond)
+	if elapsed > 0 {
+		result.PerSecond = float64(result.Broadcasts) / elapsed.Seconds()
+	}
+	if result.Missing = result.Accepted - t.seen; result.Missing < 0 {
+		result.Missing = 0
+	}
+	return result, ctx.Err()
+}
+
+// print prints the copies of the run by validation code.
+func (r *DupResult) print(out *printer) error {
+	codes := make([]string, 0, len(r.Codes))
+	for code := range r.Codes {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	rows := make([][]string, 0, len(codes)+1)
+	for _, code := range codes {
+		rows = append(rows, []string{code, strconv.Itoa(r.Codes[code])})
+	}
+	if r.Missing != 0 {
+		rows = append(rows, []string{"(not in a block)", strconv.Itoa(r.Missing)})
+	}
+	return out.table([]string{"validation code", "copies"}, rows)
+}
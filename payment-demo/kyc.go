@@ -0,0 +1,34 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/schema"
+	"github.com/pkg/errors"
+)
+
+// parseThresholds parses the amount:level pairs of the -thresholds flag,
+// e.g. "1000:1,100000:2".
+func parseThresholds(s string) ([]schema.KYCThreshold, error) {
+	var thresholds []schema.KYCThreshold
+	for _, pair := range strings.Split(s, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		parts := strings.Split(pair, ":")
+		if len(parts) != 2 {
+			return nil, errors.Errorf("malformed threshold %q, expecting amount:level", pair)
+		}
+		amount, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "malformed threshold amount %q", parts[0])
+		}
+		level, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return nil, errors.Wrapf(err, "malformed threshold level %q", parts[1])
+		}
+		thresholds = append(thresholds, schema.KYCThreshold{Amount: amount, Level: level})
+	}
+	return thresholds, nil
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io/ioutil"
+	"math/bits"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/GingerMoon/fabric_demo/payment-demo/paymentclient"
+	"github.com/pkg/errors"
+)
+
+// subBuckets is the number of buckets per power of two of a histogram, the
+// relative error of its quantiles is below 1/subBuckets.
+const (
+	subBucketBits = 7
+	subBuckets    = 1 << subBucketBits
+)
+
+// The phases of a request, queries are only endorsed, in the order of the
+// reports. schedule is the wait of a transfer for the commit of the ones
+// touching its accounts, batch the wait of an endorsed transaction of a
+// pipeline for its burst, submit-to-commit is measured from the block events.
+var latencyPhases = []string{"schedule", "endorse", "batch", "broadcast", "commit", "total", "submit-to-commit"}
+
+// histogram counts durations in microseconds in log-linear buckets, as HDR
+// histograms do: the values below subBuckets exactly, the others in
+// subBuckets buckets per power of two.
+type histogram struct {
+	counts []int64
+	count  int64
+	sum    int64
+	max    int64
+}
+
+func bucketOf(v int64) int {
+	if v < subBuckets {
+		return int(v)
+	}
+	shift := bits.Len64(uint64(v)) - subBucketBits - 1
+	return subBuckets + shift*subBuckets + int(v>>uint(shift)) - subBuckets
+}
+
+// bucketMax returns the highest value counted in bucket i.
+func bucketMax(i int) int64 {
+	if i < subBuckets {
+		return int64(i)
+	}
+	shift := uint((i - subBuckets) / subBuckets)
+	sub := int64(i%subBuckets + subBuckets)
+	return (sub+1)<<shift - 1
+}
+
+func (h *histogram) record(d time.Duration) {
+	v := int64(d / time.Microsecond)
+	if v < 0 {
+		v = 0
+	}
+	i := bucketOf(v)
+	if i >= len(h.counts) {
+		counts := make([]int64, i+1)
+		copy(counts, h.counts)
+		h.counts = counts
+	}
+	h.counts[i]++
+	h.count++
+	h.sum += v
+	if v > h.max {
+		h.max = v
+	}
+}
+
+// quantile returns the value below which a fraction q of the values fall, in
+// microseconds.
+func (h *histogram) quantile(q float64) int64 {
+	if h.count == 0 {
+		return 0
+	}
+	rank := int64(q*float64(h.count) + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+	var seen int64
+	for i, n := range h.counts {
+		if seen += n; seen >= rank {
+			if v := bucketMax(i); v < h.max {
+				return v
+			}
+			break
+		}
+	}
+	return h.max
+}
+
+// LatencyStats are the quantiles of one phase of one operation, in
+// milliseconds.
+type LatencyStats struct {
+	Operation string  `json:"operation"`
+	Phase     string  `json:"phase"`
+	Count     int64   `json:"count"`
+	Errors    int     `json:"errors"`
+	Mean      float64 `json:"meanMs"`
+	P50       float64 `json:"p50Ms"`
+	P90       float64 `json:"p90Ms"`
+	P99       float64 `json:"p99Ms"`
+	P999      float64 `json:"p999Ms"`
+	Max       float64 `json:"maxMs"`
+}
+
+type latencyKey struct {
+	operation, phase string
+}
+
+// LatencyRecorder keeps a histogram per operation and phase of the
+// successful requests and counts the failed ones, the transactions retried
+// aside. Record is the timings callback of the clients.
+type LatencyRecorder struct {
+	sync.Mutex
+	histograms map[latencyKey]*histogram
+	errors     map[string]int
+	retries    map[string]*RetryStats
+}
+
+func NewLatencyRecorder() *LatencyRecorder {
+	return &LatencyRecorder{histograms: map[latencyKey]*histogram{}, errors: map[string]int{},
+		retries: map[string]*RetryStats{}}
+}
+
+func (l *LatencyRecorder) Record(t *paymentclient.Timing) {
+	l.Lock()
+	defer l.Unlock()
+	if t.Attempt > 0 {
+		r, ok := l.retries[t.Fcn]
+		if !ok {
+			r = &RetryStats{Operation: t.Fcn}
+			l.retries[t.Fcn] = r
+		}
+		r.record(t)
+		if t.Retried {
+			return
+		}
+	}
+	if t.Err != nil {
+		l.errors[t.Fcn]++
+		return
+	}
+	durations := []time.Duration{t.Endorse, t.Broadcast, t.Commit, t.Total}
+	for i, phase := range []string{"endorse", "broadcast", "commit", "total"} {
+		// a committed transaction went through every phase
+		if t.Commit == 0 && (phase == "broadcast" || phase == "commit") {
+			continue
+		}
+		l.record(t.Fcn, phase, durations[i])
+	}
+}
+
+// RecordDuration records d in the histogram of the phase of operation.
+func (l *LatencyRecorder) RecordDuration(operation, phase string, d time.Duration) {
+	l.Lock()
+	defer l.Unlock()
+	l.record(operation, phase, d)
+}
+
+func (l *LatencyRecorder) record(operation, phase string, d time.Duration) {
+	key := latencyKey{operation, phase}
+	h, ok := l.histograms[key]
+	if !ok {
+		h = &histogram{}
+		l.histograms[key] = h
+	}
+	h.record(d)
+}
+
+// Stats returns the quantiles of every operation and phase recorded.
+func (l *LatencyRecorder) Stats() []LatencyStats {
+	l.Lock()
+	defer l.Unlock()
+	ms := func(us int64) float64 {
+		return float64(us) / 1000
+	}
+	var stats []LatencyStats
+	for key, h := range l.histograms {
+		stats = append(stats, LatencyStats{
+			Operation: key.operation,
+			Phase:     key.phase,
+			Count:     h.count,
+			Errors:    l.errors[key.operation],
+			Mean:      ms(h.sum / h.count),
+			P50:       ms(h.quantile(0.5)),
+			P90:       ms(h.quantile(0.9)),
+			P99:       ms(h.quantile(0.99)),
+			P999:      ms(h.quantile(0.999)),
+			Max:       ms(h.max),
+		})
+	}
+	// the operations that always failed
+	for operation, n := range l.errors {
+		if _, ok := l.histograms[latencyKey{operation, "total"}]; !ok {
+			stats = append(stats, LatencyStats{Operation: operation, Phase: "total", Errors: n})
+		}
+	}
+	order := map[string]int{}
+	for i, phase := range latencyPhases {
+		order[phase] = i
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Operation != stats[j].Operation {
+			return stats[i].Operation < stats[j].Operation
+		}
+		return order[stats[i].Phase] < order[stats[j].Phase]
+	})
+	return stats
+}
+
+// latencyOutput is the recorder of a command and the files its stats are
+// exported to.
+type latencyOutput struct {
+	recorder          *LatencyRecorder
+	jsonPath, csvPath string
+}
+
+// latencyFlags defines the export flags of a command recording the timings of
+// its clients.
+func latencyFlags(fs *commandFlags) *latencyOutput {
+	l := &latencyOutput{recorder: NewLatencyRecorder()}
+	fs.StringVar(&l.jsonPath, "latencyjson", "", "file the latency stats are written to as JSON")
+	fs.StringVar(&l.csvPath, "latencycsv", "", "file the latency stats are written to as CSV")
+	fs.options = append(fs.options, paymentclient.WithTimings(l.recorder.Record))
+	return l
+}
+
+// report prints the latency table after the result of the command and
+// exports it.
+func (l *latencyOutput) report(out *printer, stats []LatencyStats) error {
+	rows := make([][]string, len(stats))
+	for i, s := range stats {
+		rows[i] = s.row()
+	}
+	if err := out.table(latencyHeader, rows); err != nil {
+		return err
+	}
+	return exportLatency(stats, l.jsonPath, l.csvPath)
+}
+
+var latencyHeader = []string{"operation", "phase", "count", "errors", "mean_ms", "p50_ms", "p90_ms", "p99_ms", "p999_ms", "max_ms"}
+
+func (s LatencyStats) row() []string {
+	f := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', 3, 64)
+	}
+	return []string{s.Operation, s.Phase, strconv.FormatInt(s.Count, 10), strconv.Itoa(s.Errors),
+		f(s.Mean), f(s.P50), f(s.P90), f(s.P99), f(s.P999), f(s.Max)}
+}
+
+// exportLatency writes the stats as JSON to jsonPath and as CSV to csvPath,
+// an empty path is skipped.
+func exportLatency(stats []LatencyStats, jsonPath, csvPath string) error {
+	if jsonPath != "" {
+		d, err := json.MarshalIndent(stats, "", "  ")
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if err := ioutil.WriteFile(jsonPath, d, 0644); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	if csvPath != "" {
+		f, err := os.Create(csvPath)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		defer f.Close()
+		w := csv.NewWriter(f)
+		w.Write(latencyHeader)
+		for _, s := range stats {
+			w.Write(s.row())
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return errors.WithStack(err)
+		}
+		return errors.WithStack(f.Close())
+	}
+	return nil
+}
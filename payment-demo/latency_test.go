@@ -0,0 +1,90 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestBucketOf(t *testing.T) {
+	tests := []struct {
+		v      int64
+		bucket int
+		max    int64
+	}{
+		{0, 0, 0},
+		{1, 1, 1},
+		{subBuckets - 1, subBuckets - 1, subBuckets - 1},
+		{subBuckets, subBuckets, subBuckets},
+		{2*subBuckets - 1, 2*subBuckets - 1, 2*subBuckets - 1},
+		{2 * subBuckets, 2 * subBuckets, 2*subBuckets + 1},
+		{2*subBuckets + 1, 2 * subBuckets, 2*subBuckets + 1},
+		{4 * subBuckets, 3 * subBuckets, 4*subBuckets + 3},
+	}
+	for _, tt := range tests {
+		if got := bucketOf(tt.v); got != tt.bucket {
+			t.Errorf("bucketOf(%d) = %d, want %d", tt.v, got, tt.bucket)
+		}
+		if got := bucketMax(tt.bucket); got != tt.max {
+			t.Errorf("bucketMax(%d) = %d, want %d", tt.bucket, got, tt.max)
+		}
+	}
+}
+
+func TestBucketBounds(t *testing.T) {
+	values := []int64{0, 1, 100, 127, 128, 129, 1000, 4095, 4096, 123456, 1 << 40, math.MaxInt64 / 3, math.MaxInt64}
+	for _, v := range values {
+		i := bucketOf(v)
+		max := bucketMax(i)
+		if max < v {
+			t.Errorf("bucketMax(bucketOf(%d)) = %d, below the value", v, max)
+		}
+		if i > 0 && bucketMax(i-1) >= v {
+			t.Errorf("bucketMax(%d) = %d, the previous bucket already holds %d", i-1, bucketMax(i-1), v)
+		}
+		if float64(max-v) > float64(v)/subBuckets {
+			t.Errorf("bucket of %d goes up to %d, beyond the relative error 1/%d", v, max, subBuckets)
+		}
+	}
+}
+
+func TestQuantile(t *testing.T) {
+	var empty histogram
+	if got := empty.quantile(0.5); got != 0 {
+		t.Errorf("quantile of an empty histogram = %d, want 0", got)
+	}
+
+	var h histogram
+	for v := 1; v <= 10000; v++ {
+		h.record(time.Duration(v) * time.Microsecond)
+	}
+	tests := []struct {
+		q    float64
+		want int64
+	}{
+		{0, 1},
+		{0.5, 5000},
+		{0.9, 9000},
+		{0.99, 9900},
+		{0.999, 9990},
+		{1, 10000},
+	}
+	for _, tt := range tests {
+		got := h.quantile(tt.q)
+		if got < tt.want || float64(got-tt.want) > float64(tt.want)/subBuckets {
+			t.Errorf("quantile(%v) = %d, want %d within 1/%d", tt.q, got, tt.want, subBuckets)
+		}
+		if got > h.max {
+			t.Errorf("quantile(%v) = %d, above the max %d", tt.q, got, h.max)
+		}
+	}
+	if h.count != 10000 || h.max != 10000 || h.sum != 10000*10001/2 {
+		t.Errorf("count %d, max %d, sum %d", h.count, h.max, h.sum)
+	}
+
+	var negative histogram
+	negative.record(-time.Second)
+	if negative.max != 0 || negative.quantile(1) != 0 {
+		t.Errorf("a negative duration recorded as %d", negative.max)
+	}
+}
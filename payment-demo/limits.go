@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/schema"
+	"github.com/GingerMoon/fabric_demo/payment-demo/paymentclient"
+)
+
+func formatLimit(n int64) string {
+	if n == schema.Unlimited {
+		return "unlimited"
+	}
+	return fmt.Sprint(n)
+}
+
+// ShowLimits logs the limit status of account.
+func ShowLimits(ctx context.Context, c *paymentclient.Client, account string) error {
+	status, err := c.GetLimits(ctx, account)
+	if err != nil {
+		return err
+	}
+	logger.Infof("account %s: spent %d in the last %d hours, remaining daily %s, max transfer %s",
+		account, status.Spent, schema.WindowHours, formatLimit(status.RemainingDaily), formatLimit(status.MaxTransfer))
+	return nil
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GingerMoon/fabric_demo/payment-demo/paymentclient"
+	"github.com/pkg/errors"
+)
+
+// saturationIntervals is the number of consecutive intervals behind the
+// offered load after which a run is reported as saturated, a single one is
+// often the latency of a step up.
+const saturationIntervals = 3
+
+// LoadProfile is the offered rate of a load run, in requests per second, as a
+// function of the time since the end of the warmup.
+type LoadProfile struct {
+	Kind  string // constant, step or ramp
+	Rates []float64
+	// Step is the length of each rate of a step profile.
+	Step time.Duration
+}
+
+// ParseLoadProfile parses "constant:R", "step:R1,R2,.../DURATION" (each rate
+// held for DURATION, the last one until the end) or "ramp:FROM-TO" (linear
+// over the run).
+func ParseLoadProfile(s string) (LoadProfile, error) {
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 {
+		return LoadProfile{}, errors.Errorf("malformed load profile %q, expecting constant:R, step:R1,R2/DURATION or ramp:FROM-TO", s)
+	}
+	p := LoadProfile{Kind: parts[0]}
+	var rates []string
+	switch p.Kind {
+	case "constant":
+		rates = []string{parts[1]}
+	case "step":
+		steps := strings.SplitN(parts[1], "/", 2)
+		if len(steps) != 2 {
+			return LoadProfile{}, errors.Errorf("malformed step profile %q, expecting step:R1,R2/DURATION", s)
+		}
+		step, err := time.ParseDuration(steps[1])
+		if err != nil || step <= 0 {
+			return LoadProfile{}, errors.Errorf("malformed step duration %q", steps[1])
+		}
+		p.Step = step
+		rates = strings.Split(steps[0], ",")
+	case "ramp":
+		if rates = strings.SplitN(parts[1], "-", 2); len(rates) != 2 {
+			return LoadProfile{}, errors.Errorf("malformed ramp profile %q, expecting ramp:FROM-TO", s)
+		}
+	default:
+		return LoadProfile{}, errors.Errorf("unknown load profile %q", p.Kind)
+	}
+	for _, r := range rates {
+		rate, err := strconv.ParseFloat(strings.TrimSpace(r), 64)
+		if err != nil || rate <= 0 {
+			return LoadProfile{}, errors.Errorf("malformed rate %q, expecting a positive number", r)
+		}
+		p.Rates = append(p.Rates, rate)
+	}
+	return p, nil
+}
+
+// Rate returns the offered rate at t of a run measured for duration.
+func (p LoadProfile) Rate(t, duration time.Duration) float64 {
+	switch p.Kind {
+	case "step":
+		i := int(t / p.Step)
+		if i >= len(p.Rates) {
+			i = len(p.Rates) - 1
+		}
+		return p.Rates[i]
+	case "ramp":
+		if t >= duration {
+			return p.Rates[1]
+		}
+		return p.Rates[0] + (p.Rates[1]-p.Rates[0])*float64(t)/float64(duration)
+	}
+	return p.Rates[0]
+}
+
+// LoadOptions configures RunLoad. The requests of the warmup are not counted.
+type LoadOptions struct {
+	Profile  LoadProfile
+	Duration time.Duration
+	Warmup   time.Duration
+	Interval time.Duration
+	// MaxInFlight bounds the requests waiting for their response, the
+	// requests due beyond it are dropped.
+	MaxInFlight int
+}
+
+// LoadInterval is the load offered and served during one interval. Offered
+// counts the requests issued in the interval, Completed and Errors the
+// responses received in it.
+type LoadInterval struct {
+	StartMS     int64   `json:"startMs"`
+	TargetTPS   float64 `json:"targetTps"`
+	Offered     int     `json:"offered"`
+	Completed   int     `json:"completed"`
+	Errors      int     `json:"errors"`
+	Dropped     int     `json:"dropped"`
+	AchievedTPS float64 `json:"achievedTps"`
+	InFlight    int     `json:"inFlight"`
+	MaxLagMS    int64   `json:"maxLagMs"`
+	// Behind is set when the dispatcher fell behind the schedule, requests
+	// were dropped or less than 90% of the target rate got a response.
+	Behind bool `json:"behind"`
+}
+
+// LoadResult is the outcome of a load run. The run is Saturated when it was
+// behind for saturationIntervals consecutive intervals, first at the target
+// rate SaturatedAt.
+type LoadResult struct {
+	Profile     string         `json:"profile"`
+	Selection   string         `json:"selection"`
+	Offered     int            `json:"offered"`
+	Completed   int            `json:"completed"`
+	Errors      int            `json:"errors"`
+	Dropped     int            `json:"dropped"`
+	AchievedTPS float64        `json:"achievedTps"`
+	Intervals   []LoadInterval `json:"intervals"`
+	Saturated   bool           `json:"saturated"`
+	SaturatedAt float64        `json:"saturatedAt,omitempty"`
+	// Latency is set when the clients record their timings.
+	Latency   []LatencyStats `json:"latency,omitempty"`
+	Retries   []RetryStats   `json:"retries,omitempty"`
+	Commits   *CommitReport  `json:"commits,omitempty"`
+	Conflicts *ConflictStats `json:"conflicts,omitempty"`
+}
+
+// loadRequest is one request of a load run, drawn by the dispatcher.
+type loadRequest func(ctx context.Context, c *paymentclient.Client) error
+
+// randomTransfers draws transfers of amount for client c with the picker c,
+// held back by scheduler, if any, while they conflict.
+func randomTransfers(pickers []accountPicker, amount int64, scheduler *conflictScheduler) func(c int) loadRequest {
+	return func(c int) loadRequest {
+		a, b := pickers[c]()
+		return func(ctx context.Context, c *paymentclient.Client) error {
+			from, to, err := scheduler.take(ctx, strconv.Itoa(a), strconv.Itoa(b))
+			if err != nil {
+				return err
+			}
+			defer scheduler.release(from, to)
+			_, err = c.Transfer(ctx, from, to, "", amount)
+			return err
+		}
+	}
+}
+
+// loadStats collects the counts of the intervals of a run.
+type loadStats struct {
+	sync.Mutex
+	start     time.Time // end of the warmup
+	interval  time.Duration
+	intervals []LoadInterval
+	inFlight  int
+}
+
+// at returns the interval of time t, nil during the warmup or after the run.
+func (s *loadStats) at(t time.Time) *LoadInterval {
+	if t.Before(s.start) {
+		return nil
+	}
+	i := int(t.Sub(s.start) / s.interval)
+	if i >= len(s.intervals) {
+		return nil
+	}
+	return &s.intervals[i]
+}
+
+func (s *loadStats) issued(now time.Time, lag time.Duration, dropped bool) {
+	s.Lock()
+	defer s.Unlock()
+	if !dropped {
+		s.inFlight++
+	}
+	in := s.at(now)
+	if in == nil {
+		return
+	}
+	if dropped {
+		in.Dropped++
+	} else {
+		in.Offered++
+	}
+	if ms := int64(lag / time.Millisecond); ms > in.MaxLagMS {
+		in.MaxLagMS = ms
+	}
+}
+
+func (s *loadStats) completed(now time.Time, err error) {
+	s.Lock()
+	defer s.Unlock()
+	s.inFlight--
+	in := s.at(now)
+	if in == nil {
+		return
+	}
+	if err != nil {
+		in.Errors++
+	} else {
+		in.Completed++
+	}
+}
+
+// close finishes interval i once it is over and returns a copy.
+func (s *loadStats) close(i int) LoadInterval {
+	s.Lock()
+	defer s.Unlock()
+	in := &s.intervals[i]
+	in.InFlight = s.inFlight
+	in.AchievedTPS = float64(in.Completed) / s.interval.Seconds()
+	in.Behind = in.Dropped != 0 || time.Duration(in.MaxLagMS)*time.Millisecond > s.interval ||
+		float64(in.Completed+in.Errors)/s.interval.Seconds() < 0.9*in.TargetTPS
+	return *in
+}
+
+// RunLoad offers the requests drawn by next to the clients in turn at the
+// rate of opts.Profile, whether the previous ones have completed or not, and
+// reports the throughput of every interval as it closes. next draws the
+// requests of the client of the given index.
+func RunLoad(ctx context.Context, clients []*paymentclient.Client, opts LoadOptions, next func(c int) loadRequest) (*LoadResult, error) {
+	if opts.Duration <= 0 || opts.Interval <= 0 || opts.Duration%opts.Interval != 0 {
+		return nil, errors.Errorf("load needs a positive duration, multiple of the interval, got %v and %v", opts.Duration, opts.Interval)
+	}
+	if opts.MaxInFlight < 1 {
+		return nil, errors.Errorf("max in-flight must be positive, got %d", opts.MaxInFlight)
+	}
+
+	begin := time.Now()
+	stats := &loadStats{start: begin.Add(opts.Warmup), interval: opts.Interval,
+		intervals: make([]LoadInterval, int(opts.Duration/opts.Interval))}
+	for i := range stats.intervals {
+		t := time.Duration(i) * opts.Interval
+		stats.intervals[i].StartMS = int64(t / time.Millisecond)
+		// the average rate of the interval for a ramp
+		stats.intervals[i].TargetTPS = opts.Profile.Rate(t+opts.Interval/2, opts.Duration)
+	}
+
+	// the reporter logs every interval as it closes
+	reported := make(chan struct{})
+	go func() {
+		defer close(reported)
+		for i := range stats.intervals {
+			select {
+			case <-time.After(time.Until(stats.start.Add(time.Duration(i+1) * opts.Interval))):
+			case <-ctx.Done():
+				return
+			}
+			in := stats.close(i)
+			behind := ""
+			if in.Behind {
+				behind = ", BEHIND"
+			}
+			logger.Infof("load %6.1fs: target %.0f TPS, offered %d, completed %d (%.0f TPS), errors %d, dropped %d, in flight %d, max lag %dms%s",
+				float64(in.StartMS)/1000, in.TargetTPS, in.Offered, in.Completed, in.AchievedTPS, in.Errors, in.Dropped, in.InFlight, in.MaxLagMS, behind)
+		}
+	}()
+
+	end := stats.start.Add(opts.Duration)
+	var w sync.WaitGroup
+	c := 0
+	for due := begin; due.Before(end); {
+		if d := time.Until(due); d > 0 {
+			select {
+			case <-time.After(d):
+			case <-ctx.Done():
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		now := time.Now()
+		request := next(c)
+
+		stats.Lock()
+		full := stats.inFlight >= opts.MaxInFlight
+		stats.Unlock()
+		stats.issued(now, now.Sub(due), full)
+		if !full {
+			w.Add(1)
+			go func(client *paymentclient.Client) {
+				defer w.Done()
+				err := request(ctx, client)
+				if err != nil {
+					logger.Debugf("%s", err)
+				}
+				stats.completed(time.Now(), err)
+			}(clients[c])
+			c = (c + 1) % len(clients)
+		}
+
+		// the schedule does not move with the dispatcher, a late request is
+		// followed by the overdue ones right away
+		t := due.Sub(stats.start)
+		if t < 0 {
+			t = 0
+		}
+		due = due.Add(time.Duration(float64(time.Second) / opts.Profile.Rate(t, opts.Duration)))
+	}
+	<-reported
+	w.Wait()
+
+	result := &LoadResult{Profile: opts.String()}
+	behind := 0
+	for i, in := range stats.intervals {
+		result.Offered += in.Offered
+		result.Completed += in.Completed
+		result.Errors += in.Errors
+		result.Dropped += in.Dropped
+		if !in.Behind {
+			behind = 0
+			continue
+		}
+		if behind++; behind == saturationIntervals && !result.Saturated {
+			result.Saturated = true
+			result.SaturatedAt = stats.intervals[i-saturationIntervals+1].TargetTPS
+		}
+	}
+	result.AchievedTPS = float64(result.Completed) / opts.Duration.Seconds()
+	result.Intervals = stats.intervals
+	return result, ctx.Err()
+}
+
+// String describes the profile, the warmup and the duration of the run.
+func (o LoadOptions) String() string {
+	rates := make([]string, len(o.Profile.Rates))
+	for i, r := range o.Profile.Rates {
+		rates[i] = strconv.FormatFloat(r, 'f', -1, 64)
+	}
+	s := o.Profile.Kind + ":"
+	switch o.Profile.Kind {
+	case "step":
+		s += strings.Join(rates, ",") + "/" + o.Profile.Step.String()
+	case "ramp":
+		s += strings.Join(rates, "-")
+	default:
+		s += rates[0]
+	}
+	return s + " for " + o.Duration.String() + " after " + o.Warmup.String() + " of warmup"
+}
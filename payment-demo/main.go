@@ -1,11 +1,1280 @@
-// You can edit this code!
-// Click here and start typing.
 package main
 
-import "log"
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/blob"
+	"github.com/GingerMoon/fabric_demo/payment-common/merkle"
+	"github.com/GingerMoon/fabric_demo/payment-common/schema"
+	"github.com/GingerMoon/fabric_demo/payment-demo/paymentclient"
+	"github.com/hyperledger/fabric-sdk-go/pkg/core/config"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fabsdk"
+	"github.com/pkg/errors"
+)
+
+// commands are the payment-demo commands, each parsing its own flags:
+//
+//	payment-demo create -account <key> -amount n [-asset code]
+//	payment-demo transfer -from <key> -to <key> -amount n [-asset code]
+//	payment-demo query -account <key> [-asset code]
+//	payment-demo total -accounts n [-clients n]
+//	payment-demo bench -accounts n [-transfers n] [-balance n] [-amount n] [-clients n]
+//	payment-demo load -accounts n [-rate r | -profile p] [-duration d] [-warmup d] [-interval d] [-clients n]
+//	payment-demo pipeline -accounts n [-transfers n] [-endorsers n] [-burst n] [-linger d] [-window n] [-clients n]
+//	payment-demo stress-dup -accounts n [-transactions n] [-copies n | -ratio r] [-concurrency n] [-clients n]
+//	payment-demo putblob -id <id> [-chunk bytes] [-clients n] <file>
+//	payment-demo getblob -id <id> [-clients n] <file>
+//	payment-demo populate [-accounts n] [-size bytes] [-values profile] [-clients n] [-resume]
+//	payment-demo setlimits -account <key> [-daily n] [-pertx n]
+//	payment-demo limits -account <key>
+//	payment-demo setfees [-flat n] [-bps n] [-min n] [-max n] [-account key]
+//	payment-demo fees
+//	payment-demo setkyc -thresholds <amount:level,...>
+//	payment-demo kyc
+//	payment-demo setacl -function <name> [-msps ids] [-ous ous] [-roles roles]
+//	payment-demo acl
+//	payment-demo registerasset -code <code> [-symbol s] [-name s] [-decimals n]
+//	payment-demo assets
+//	payment-demo balance -account <key> [-asset code]
+//	payment-demo swap -a <key> -asseta <code> -amounta n -b <key> -assetb <code> -amountb n
+//	payment-demo schedule -from <key> -to <key> -amount n [-asset code] [-first time] [-every duration] [-count n]
+//	payment-demo scheduled -id <id> [-cancel]
+//	payment-demo scheduler [-interval duration] [-limit n]
+//	payment-demo positions [-org1 msp -org2 msp]
+//	payment-demo settle [-to time] [-limit n]
+//	payment-demo dispute -tx <txid> [-reason s]
+//	payment-demo approvereversal -tx <txid>
+//	payment-demo repaydebt -tx <txid> [-amount n]
+//	payment-demo history -account <key>
+//	payment-demo registeralias -alias <name> -account <key>
+//	payment-demo alias -alias <name>
+//	payment-demo transferalias -alias <name> -owner <id>
+//	payment-demo whoami
+//	payment-demo rotate -key <hex> [-oldkey hex] [-page n]
+//	payment-demo snapshot [-page n]
+//	payment-demo proof -account <key> [-id snapshot] [-out file]
+//	payment-demo verify -proof <file> -root <hex>
+//
+// Every command but verify also accepts the flags of commandFlags. The
+// first eight commands print their result as text or, with -output json, as
+// JSON, the transactions with their id and block number. An alias can be
+// given wherever an account key is expected. bench, load, pipeline and
+// stress-dup draw the accounts of their transfers as -select says, uniform by
+// default, from -seed, and bench, load and pipeline take -avoidconflicts to
+// hold back the transfers touching the accounts of uncommitted ones.
+//
+// An invoke invalidated by an MVCC or phantom read conflict is endorsed
+// again, with fresh reads, up to -commitretries times after a backoff
+// starting at -commitbackoff; bench and load report the invokes committed at
+// their first try apart from the ones committed after retries. The pipeline
+// and stress-dup transactions are never retried.
+var commands = map[string]func(args []string) error{
+	"create":          createCommand,
+	"transfer":        transferCommand,
+	"query":           queryCommand,
+	"total":           totalCommand,
+	"bench":           benchCommand,
+	"load":            loadCommand,
+	"pipeline":        pipelineCommand,
+	"stress-dup":      stressDupCommand,
+	"putblob":         putBlobCommand,
+	"getblob":         getBlobCommand,
+	"populate":        populateCommand,
+	"setlimits":       setLimitsCommand,
+	"limits":          limitsCommand,
+	"setfees":         setFeesCommand,
+	"fees":            feesCommand,
+	"setkyc":          setKYCCommand,
+	"kyc":             kycCommand,
+	"setacl":          setACLCommand,
+	"acl":             aclCommand,
+	"registerasset":   registerAssetCommand,
+	"assets":          assetsCommand,
+	"balance":         balanceCommand,
+	"swap":            swapCommand,
+	"schedule":        scheduleCommand,
+	"scheduled":       scheduledCommand,
+	"scheduler":       schedulerCommand,
+	"positions":       positionsCommand,
+	"settle":          settleCommand,
+	"dispute":         disputeCommand,
+	"approvereversal": approveReversalCommand,
+	"repaydebt":       repayDebtCommand,
+	"history":         historyCommand,
+	"registeralias":   registerAliasCommand,
+	"alias":           aliasCommand,
+	"transferalias":   transferAliasCommand,
+	"whoami":          whoAmICommand,
+	"rotate":          rotateCommand,
+	"snapshot":        snapshotCommand,
+	"proof":           proofCommand,
+	"verify":          verifyCommand,
+}
 
 func main() {
-	if error := Demo(); error != nil {
-		log.Fatalf(error.Error())
+	if len(os.Args) < 2 || os.Args[1] == "-h" || os.Args[1] == "-help" {
+		names := make([]string, 0, len(commands))
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Fprintln(os.Stderr, "usage: payment-demo <command> [flags], payment-demo <command> -h lists the flags of a command.")
+		fmt.Fprintln(os.Stderr, "commands:", strings.Join(names, ", "))
+		os.Exit(2)
+	}
+	command, ok := commands[os.Args[1]]
+	if !ok {
+		log.Fatalf("unknown command %s", os.Args[1])
+	}
+	if err := command(os.Args[2:]); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// commandFlags is the flag set of a command, with the flags every command
+// connecting to the network accepts: -config, -channel and -cc to pick the
+// network and the chaincode, the public or the private one, -encoding,
+// -user and -org to pick the enrolled identity signing the requests, e.g. a
+// user whose certificate carries a kyc.level attribute, and -key and
+// -oldkey, AES_KEY and AES_KEY_OLD by default, for the keys of the encrypted
+// account states, and -commitretries and -commitbackoff for the retries of
+// the invokes invalidated by a read conflict.
+type commandFlags struct {
+	*flag.FlagSet
+	configPath, channel, cc, encoding *string
+	user, org, key, oldKey            *string
+	commitRetry                       paymentclient.CommitRetry
+	// the flags naming accounts, their aliases are resolved to account keys by run
+	accounts []*string
+	// the options of the clients added by the flags of the command
+	options []paymentclient.Option
+}
+
+func newCommandFlags(name string) *commandFlags {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	f := &commandFlags{
+		FlagSet:    fs,
+		configPath: fs.String("config", "config-payment.yaml", "SDK configuration"),
+		channel:    fs.String("channel", paymentclient.DefaultChannel, "channel of the chaincode"),
+		cc:         fs.String("cc", paymentclient.DefaultChaincode, "chaincode ID, e.g. the one of the private chaincode"),
+		encoding:   fs.String("encoding", os.Getenv("ENCODING"), "encoding of the payloads and account states, json (default) or proto"),
+		user:       fs.String("user", paymentclient.DefaultUser, "enrolled user signing the requests"),
+		org:        fs.String("org", "", "org of the user, the client org of config-payment.yaml by default"),
+		key:        fs.String("key", os.Getenv("AES_KEY"), "hex AES key of the account states, the new key for rotate"),
+		oldKey:     fs.String("oldkey", os.Getenv("AES_KEY_OLD"), "hex AES key being rotated out"),
+	}
+	f.commitRetry = paymentclient.DefaultCommitRetry
+	fs.IntVar(&f.commitRetry.Attempts, "commitretries", f.commitRetry.Attempts, "retries of an invoke invalidated by a read conflict, 0 for none")
+	fs.DurationVar(&f.commitRetry.InitialBackoff, "commitbackoff", f.commitRetry.InitialBackoff, "backoff before the first retry of an invalidated invoke, doubled at each retry")
+	return f
+}
+
+// account defines a flag naming an account, by its key or its alias.
+func (f *commandFlags) account(name, usage string) *string {
+	p := new(string)
+	f.accountVar(p, name, usage)
+	return p
+}
+
+// accountVar is account storing the flag in p.
+func (f *commandFlags) accountVar(p *string, name, usage string) {
+	f.StringVar(p, name, "", usage)
+	f.accounts = append(f.accounts, p)
+}
+
+// output defines the -output flag of the commands printing their result.
+func (f *commandFlags) output() *string {
+	return f.String("output", "text", "output of the result, text or json")
+}
+
+// clients defines the -clients flag of the commands sharing their requests
+// between concurrent clients.
+func (f *commandFlags) clients() *int {
+	return f.Int("clients", 4, "number of concurrent clients")
+}
+
+// run connects n clients with the parsed flags, resolves the account flags
+// and calls run with the clients. An interrupt cancels the requests in
+// flight.
+func (f *commandFlags) run(n int, run func(ctx context.Context, clients []*paymentclient.Client) error) error {
+	encoding, err := schema.ParseEncoding(*f.encoding)
+	if err != nil {
+		return err
+	}
+	current, err := paymentclient.ParseKey(*f.key)
+	if err != nil {
+		return err
+	}
+	previous, err := paymentclient.ParseKey(*f.oldKey)
+	if err != nil {
+		return err
+	}
+
+	sdk, err := fabsdk.New(config.FromFile(*f.configPath))
+	if err != nil {
+		return errors.WithMessage(err, "Failed to create new SDK: %s")
+	}
+	defer sdk.Close()
+
+	clients, err := newClients(sdk, n, append([]paymentclient.Option{
+		paymentclient.WithChannel(*f.channel),
+		paymentclient.WithChaincode(*f.cc),
+		paymentclient.WithUser(*f.user),
+		paymentclient.WithOrg(*f.org),
+		paymentclient.WithEncoding(encoding),
+		paymentclient.WithKeys(current, previous),
+		paymentclient.WithCommitRetry(f.commitRetry)}, f.options...)...)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := interruptContext()
+	defer cancel()
+	for _, account := range f.accounts {
+		if *account, err = clients[0].ResolveAccount(ctx, *account); err != nil {
+			return err
+		}
+	}
+	return run(ctx, clients)
+}
+
+// runOne is run with a single client.
+func (f *commandFlags) runOne(run func(ctx context.Context, c *paymentclient.Client) error) error {
+	return f.run(1, func(ctx context.Context, clients []*paymentclient.Client) error {
+		return run(ctx, clients[0])
+	})
+}
+
+func newClients(sdk *fabsdk.FabricSDK, n int, opts ...paymentclient.Option) ([]*paymentclient.Client, error) {
+	if n < 1 {
+		return nil, errors.Errorf("need at least one client, got %d", n)
+	}
+	clients := make([]*paymentclient.Client, n)
+	for i := range clients {
+		client, err := paymentclient.New(sdk, opts...)
+		if err != nil {
+			return nil, err
+		}
+		clients[i] = client
+	}
+	return clients, nil
+}
+
+// interruptContext returns a context cancelled by the first interrupt, the
+// second one kills the process.
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		select {
+		case <-interrupt:
+			logger.Infof("interrupted, cancelling the requests in flight")
+			signal.Stop(interrupt)
+			cancel()
+		case <-ctx.Done():
+			signal.Stop(interrupt)
+		}
+	}()
+	return ctx, cancel
+}
+
+func createCommand(args []string) error {
+	fs := newCommandFlags("create")
+	output := fs.output()
+	account := fs.String("account", "", "account key")
+	amount := fs.Int64("amount", 0, "initial balance")
+	asset := fs.String("asset", "", "asset code, the default asset if empty")
+	fs.Parse(args)
+	if *account == "" || *amount <= 0 {
+		fs.Usage()
+		return errors.New("create expects -account and a positive -amount")
+	}
+	out, err := newPrinter(*output)
+	if err != nil {
+		return err
+	}
+	return fs.runOne(func(ctx context.Context, c *paymentclient.Client) error {
+		tx, err := c.CreateAccount(ctx, *account, *asset, *amount)
+		if err != nil {
+			return err
+		}
+		return out.print(map[string]interface{}{"account": *account, "asset": *asset, "amount": *amount, "txID": tx.TxID, "blockNumber": tx.BlockNumber},
+			"created account %s with %d %s in transaction %s, block %d", *account, *amount, *asset, tx.TxID, tx.BlockNumber)
+	})
+}
+
+func transferCommand(args []string) error {
+	fs := newCommandFlags("transfer")
+	output := fs.output()
+	from := fs.account("from", "account paying")
+	to := fs.account("to", "account paid")
+	amount := fs.Int64("amount", 0, "amount")
+	asset := fs.String("asset", "", "asset code, the default asset if empty")
+	fs.Parse(args)
+	if *from == "" || *to == "" || *amount <= 0 {
+		fs.Usage()
+		return errors.New("transfer expects -from, -to and a positive -amount")
+	}
+	out, err := newPrinter(*output)
+	if err != nil {
+		return err
+	}
+	return fs.runOne(func(ctx context.Context, c *paymentclient.Client) error {
+		receipt, err := c.Transfer(ctx, *from, *to, *asset, *amount)
+		if err != nil {
+			return err
+		}
+		return out.print(receipt, "transferred %d %s from %s to %s, fee %d, in transaction %s, block %d",
+			receipt.Amount, receipt.Asset, receipt.From, receipt.To, receipt.Fee, receipt.TxID, receipt.BlockNumber)
+	})
+}
+
+func queryCommand(args []string) error {
+	fs := newCommandFlags("query")
+	output := fs.output()
+	account := fs.account("account", "account key")
+	asset := fs.String("asset", "", "asset code, the default asset if empty")
+	fs.Parse(args)
+	if *account == "" {
+		fs.Usage()
+		return errors.New("query expects -account")
+	}
+	out, err := newPrinter(*output)
+	if err != nil {
+		return err
+	}
+	return fs.runOne(func(ctx context.Context, c *paymentclient.Client) error {
+		state, err := c.GetAccount(ctx, *account, *asset)
+		if err != nil {
+			return err
+		}
+		return out.print(state, "account %s: %d %s", *account, state.Balance, *asset)
+	})
+}
+
+func totalCommand(args []string) error {
+	fs := newCommandFlags("total")
+	output := fs.output()
+	n := fs.clients()
+	accounts := fs.Int("accounts", 0, "number of accounts, \"0\" to \"accounts-1\"")
+	fs.Parse(args)
+	if *accounts <= 0 {
+		fs.Usage()
+		return errors.New("total expects -accounts")
+	}
+	out, err := newPrinter(*output)
+	if err != nil {
+		return err
+	}
+	return fs.run(*n, func(ctx context.Context, clients []*paymentclient.Client) error {
+		total, phase, err := NetworkTotal(ctx, clients, *accounts)
+		if err != nil {
+			return err
+		}
+		return out.print(map[string]interface{}{"accounts": *accounts, "total": total, "query": phase},
+			"total of %d accounts: %d (%.0f queries/s)", *accounts, total, phase.PerSecond)
+	})
+}
+
+func benchCommand(args []string) error {
+	fs := newCommandFlags("bench")
+	output := fs.output()
+	n := fs.clients()
+	var opts BenchOptions
+	fs.IntVar(&opts.Accounts, "accounts", 100, "accounts created, \"0\" to \"accounts-1\"")
+	fs.IntVar(&opts.Transfers, "transfers", 0, "transfers between random accounts, as many as accounts by default")
+	fs.Int64Var(&opts.Balance, "balance", 100, "initial balance of the accounts")
+	fs.Int64Var(&opts.Amount, "amount", 80, "amount of the transfers")
+	selection := selectionFlags(fs)
+	latency := latencyFlags(fs)
+	commits := newCommitTracker(fs, latency.recorder)
+	scheduler := conflictFlags(fs, latency.recorder)
+	fs.Parse(args)
+	if opts.Transfers == 0 {
+		opts.Transfers = opts.Accounts
+	}
+	opts.scheduler = scheduler()
+	var err error
+	if opts.Selection, err = selection(); err != nil {
+		return err
+	}
+	out, err := newPrinter(*output)
+	if err != nil {
+		return err
+	}
+	return fs.run(*n, func(ctx context.Context, clients []*paymentclient.Client) error {
+		if err := commits.start(clients[0]); err != nil {
+			return err
+		}
+		result, err := Bench(ctx, clients, opts)
+		report := commits.stop()
+		if err != nil {
+			return err
+		}
+		result.Commits = report
+		result.Latency = latency.recorder.Stats()
+		result.Retries = latency.recorder.Retries()
+		if err := out.print(result, "%d clients, %d accounts, total %d before and %d after the transfers, %d in fees\n"+
+			"create:   %d (%d failed) in %dms, %.0f TPS\n"+
+			"transfer: %d (%d failed) in %dms, %.0f TPS\n"+
+			"query:    %d in %dms, %.0f QPS",
+			result.Clients, result.Accounts, result.TotalBefore, result.TotalAfter, result.Fees,
+			result.Create.Requests, result.Create.Failures, result.Create.ElapsedMS, result.Create.PerSecond,
+			result.Transfer.Requests, result.Transfer.Failures, result.Transfer.ElapsedMS, result.Transfer.PerSecond,
+			result.Query.Requests, result.Query.ElapsedMS, result.Query.PerSecond); err != nil {
+			return err
+		}
+		if err := latency.report(out, result.Latency); err != nil {
+			return err
+		}
+		if err := printRetries(out, result.Retries); err != nil {
+			return err
+		}
+		if err := result.Conflicts.print(out); err != nil {
+			return err
+		}
+		return report.print(out)
+	})
+}
+
+func loadCommand(args []string) error {
+	fs := newCommandFlags("load")
+	output := fs.output()
+	n := fs.clients()
+	var opts LoadOptions
+	accounts := fs.Int("accounts", 100, "existing accounts \"0\" to \"accounts-1\" the transfers are made between")
+	amount := fs.Int64("amount", 1, "amount of the transfers")
+	rate := fs.Float64("rate", 100, "offered transfers per second")
+	profile := fs.String("profile", "", "load profile, constant:R, step:R1,R2/DURATION or ramp:FROM-TO, instead of -rate")
+	fs.DurationVar(&opts.Duration, "duration", time.Minute, "measured duration of the run")
+	fs.DurationVar(&opts.Warmup, "warmup", 10*time.Second, "warmup before the measures, at the initial rate")
+	fs.DurationVar(&opts.Interval, "interval", time.Second, "report interval")
+	fs.IntVar(&opts.MaxInFlight, "maxinflight", 5000, "requests waiting for a response beyond which the due ones are dropped")
+	selection := selectionFlags(fs)
+	latency := latencyFlags(fs)
+	commits := newCommitTracker(fs, latency.recorder)
+	scheduler := conflictFlags(fs, latency.recorder)
+	fs.Parse(args)
+	if *accounts < 2 {
+		fs.Usage()
+		return errors.Errorf("load needs at least 2 accounts, got %d", *accounts)
+	}
+	if *profile == "" {
+		*profile = "constant:" + strconv.FormatFloat(*rate, 'f', -1, 64)
+	}
+	var err error
+	if opts.Profile, err = ParseLoadProfile(*profile); err != nil {
+		return err
+	}
+	sel, err := selection()
+	if err != nil {
+		return err
+	}
+	out, err := newPrinter(*output)
+	if err != nil {
+		return err
+	}
+	return fs.run(*n, func(ctx context.Context, clients []*paymentclient.Client) error {
+		pickers, err := sel.pickers(*accounts, len(clients))
+		if err != nil {
+			return err
+		}
+		if err := commits.start(clients[0]); err != nil {
+			return err
+		}
+		s := scheduler()
+		result, err := RunLoad(ctx, clients, opts, randomTransfers(pickers, *amount, s))
+		report := commits.stop()
+		if err != nil {
+			return err
+		}
+		result.Selection = sel.String()
+		result.Conflicts = s.report()
+		result.Commits = report
+		result.Latency = latency.recorder.Stats()
+		result.Retries = latency.recorder.Retries()
+		saturated := "kept up with the offered load"
+		if result.Saturated {
+			saturated = fmt.Sprintf("SATURATED from %.0f TPS", result.SaturatedAt)
+		}
+		if err := out.print(result, "%s: offered %d, completed %d (%.0f TPS), errors %d, dropped %d, %s",
+			result.Profile, result.Offered, result.Completed, result.AchievedTPS, result.Errors, result.Dropped, saturated); err != nil {
+			return err
+		}
+		if err := latency.report(out, result.Latency); err != nil {
+			return err
+		}
+		if err := printRetries(out, result.Retries); err != nil {
+			return err
+		}
+		if err := result.Conflicts.print(out); err != nil {
+			return err
+		}
+		return report.print(out)
+	})
+}
+
+func pipelineCommand(args []string) error {
+	fs := newCommandFlags("pipeline")
+	output := fs.output()
+	n := fs.clients()
+	var opts PipelineOptions
+	fs.IntVar(&opts.Accounts, "accounts", 100, "existing accounts \"0\" to \"accounts-1\" the transfers are made between")
+	fs.IntVar(&opts.Transfers, "transfers", 10000, "transfers between random accounts")
+	fs.Int64Var(&opts.Amount, "amount", 1, "amount of the transfers")
+	fs.IntVar(&opts.Endorsers, "endorsers", 16, "proposals endorsed concurrently per client")
+	fs.IntVar(&opts.Burst, "burst", 50, "endorsed transactions broadcast together")
+	fs.DurationVar(&opts.Linger, "linger", 20*time.Millisecond, "longest wait for a burst to fill up")
+	fs.IntVar(&opts.Window, "window", 1000, "transactions per client broadcast and not committed yet")
+	selection := selectionFlags(fs)
+	latency := latencyFlags(fs)
+	commits := newCommitTracker(fs, latency.recorder)
+	scheduler := conflictFlags(fs, latency.recorder)
+	fs.Parse(args)
+	var err error
+	if opts.Selection, err = selection(); err != nil {
+		return err
+	}
+	opts.scheduler = scheduler()
+	out, err := newPrinter(*output)
+	if err != nil {
+		return err
+	}
+	return fs.run(*n, func(ctx context.Context, clients []*paymentclient.Client) error {
+		if err := commits.start(clients[0]); err != nil {
+			return err
+		}
+		result, err := RunPipeline(ctx, clients, opts, latency.recorder)
+		report := commits.stop()
+		if err != nil {
+			return err
+		}
+		result.Commits = report
+		result.Latency = latency.recorder.Stats()
+		if err := out.print(result, "%d clients, %d transfers: %d endorsed, %d committed, invalid %v, %d failed in %dms, %.0f TPS",
+			result.Clients, result.Transfers, result.Endorsed, result.Committed, result.Invalid,
+			result.Failures, result.ElapsedMS, result.PerSecond); err != nil {
+			return err
+		}
+		if err := latency.report(out, result.Latency); err != nil {
+			return err
+		}
+		if err := result.Conflicts.print(out); err != nil {
+			return err
+		}
+		return report.print(out)
+	})
+}
+
+func stressDupCommand(args []string) error {
+	fs := newCommandFlags("stress-dup")
+	output := fs.output()
+	n := fs.clients()
+	var opts DupOptions
+	fs.IntVar(&opts.Accounts, "accounts", 100, "existing accounts \"0\" to \"accounts-1\" the transfers are made between")
+	fs.IntVar(&opts.Transactions, "transactions", 10, "transfers endorsed and signed once")
+	fs.Int64Var(&opts.Amount, "amount", 1, "amount of the transfers")
+	fs.IntVar(&opts.Copies, "copies", 1000, "broadcasts of every envelope")
+	fs.Float64Var(&opts.Ratio, "ratio", 0, "probability of a broadcast to resend an envelope already sent, instead of -copies")
+	fs.IntVar(&opts.Concurrency, "concurrency", 100, "broadcasts in flight")
+	selection := selectionFlags(fs)
+	latency := latencyFlags(fs)
+	fs.Parse(args)
+	var err error
+	if opts.Selection, err = selection(); err != nil {
+		return err
+	}
+	out, err := newPrinter(*output)
+	if err != nil {
+		return err
+	}
+	return fs.run(*n, func(ctx context.Context, clients []*paymentclient.Client) error {
+		result, err := RunDup(ctx, clients, opts, latency.recorder)
+		if err != nil {
+			return err
+		}
+		result.Latency = latency.recorder.Stats()
+		if err := out.print(result, "%s: %d transactions endorsed (%d failed), %d broadcasts with %d duplicates in %dms (%.0f/s)\n"+
+			"orderer: %d accepted, %d rejected\n"+
+			"peer: %d committed, %d with several valid copies, %d copies not in a block",
+			result.Mode, result.Transactions-result.Endorse.Failures, result.Endorse.Failures, result.Broadcasts, result.Duplicates,
+			result.ElapsedMS, result.PerSecond, result.Accepted, result.Rejected,
+			result.Committed, result.MultipleValid, result.Missing); err != nil {
+			return err
+		}
+		if err := latency.report(out, result.Latency); err != nil {
+			return err
+		}
+		return result.print(out)
+	})
+}
+
+func putBlobCommand(args []string) error {
+	fs := newCommandFlags("putblob")
+	n := fs.clients()
+	id := fs.String("id", "", "blob id")
+	chunkSize := fs.Int("chunk", blob.DefaultChunkSize, "chunk size in bytes")
+	fs.Parse(args)
+	if *id == "" || fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("putblob expects -id and a file")
+	}
+	return fs.run(*n, func(ctx context.Context, clients []*paymentclient.Client) error {
+		return UploadBlob(ctx, clients, *id, fs.Arg(0), *chunkSize)
+	})
+}
+
+func getBlobCommand(args []string) error {
+	fs := newCommandFlags("getblob")
+	n := fs.clients()
+	id := fs.String("id", "", "blob id")
+	fs.Parse(args)
+	if *id == "" || fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("getblob expects -id and a file")
+	}
+	return fs.run(*n, func(ctx context.Context, clients []*paymentclient.Client) error {
+		return DownloadBlob(ctx, clients, *id, fs.Arg(0))
+	})
+}
+
+func populateCommand(args []string) error {
+	fs := newCommandFlags("populate")
+	n := fs.clients()
+	opts := PopulateOptions{}
+	fs.Int64Var(&opts.First, "first", 0, "index of the first account")
+	fs.Int64Var(&opts.Accounts, "accounts", 0, "target number of accounts")
+	size := fs.String("size", "", "target ledger state size, e.g. 1G")
+	values := fs.String("values", "fixed:1K", "value size profile: fixed:N or uniform:MIN-MAX")
+	fs.Int64Var(&opts.Amount, "amount", 100, "balance of every account")
+	fs.IntVar(&opts.Batch, "batch", 100, "accounts per createBatch transaction")
+	fs.StringVar(&opts.Progress, "progress", "populate.progress", "progress file")
+	fs.BoolVar(&opts.Resume, "resume", false, "resume from the progress file")
+	interval := fs.Duration("interval", 5*time.Second, "progress report interval")
+	fs.Parse(args)
+
+	var err error
+	if *size != "" {
+		if opts.Bytes, err = parseBytes(*size); err != nil {
+			return err
+		}
+	}
+	if opts.Profile, err = ParseValueProfile(*values); err != nil {
+		return err
+	}
+	// the accounts are written in the encoding of the requests
+	if opts.Encoding, err = schema.ParseEncoding(*fs.encoding); err != nil {
+		return err
+	}
+	return fs.run(*n, func(ctx context.Context, clients []*paymentclient.Client) error {
+		return Populate(ctx, clients, opts, *interval)
+	})
+}
+
+func setLimitsCommand(args []string) error {
+	fs := newCommandFlags("setlimits")
+	account := fs.account("account", "account key")
+	limits := schema.Limits{}
+	fs.Int64Var(&limits.Daily, "daily", 0, "daily limit, 0 for none")
+	fs.Int64Var(&limits.PerTx, "pertx", 0, "per transaction limit, 0 for none")
+	fs.Parse(args)
+	if *account == "" {
+		fs.Usage()
+		return errors.New("setlimits expects -account")
+	}
+	return fs.runOne(func(ctx context.Context, c *paymentclient.Client) error {
+		if _, err := c.SetLimits(ctx, *account, &limits); err != nil {
+			return err
+		}
+		return ShowLimits(ctx, c, *account)
+	})
+}
+
+func limitsCommand(args []string) error {
+	fs := newCommandFlags("limits")
+	account := fs.account("account", "account key")
+	fs.Parse(args)
+	if *account == "" {
+		fs.Usage()
+		return errors.New("limits expects -account")
+	}
+	return fs.runOne(func(ctx context.Context, c *paymentclient.Client) error {
+		return ShowLimits(ctx, c, *account)
+	})
+}
+
+func setFeesCommand(args []string) error {
+	fs := newCommandFlags("setfees")
+	fees := schema.FeeSchedule{}
+	fs.Int64Var(&fees.Flat, "flat", 0, "flat fee per transfer")
+	fs.Int64Var(&fees.Bps, "bps", 0, "fee rate in basis points of the amount")
+	fs.Int64Var(&fees.Min, "min", 0, "minimum fee")
+	fs.Int64Var(&fees.Max, "max", 0, "maximum fee, 0 for none")
+	fs.accountVar(&fees.Account, "account", "account credited with the fees")
+	fs.Parse(args)
+	return fs.runOne(func(ctx context.Context, c *paymentclient.Client) error {
+		if _, err := c.SetFees(ctx, &fees); err != nil {
+			return err
+		}
+		return logFees(ctx, c)
+	})
+}
+
+func feesCommand(args []string) error {
+	fs := newCommandFlags("fees")
+	fs.Parse(args)
+	return fs.runOne(logFees)
+}
+
+// logFees logs the fee schedule of transfers.
+func logFees(ctx context.Context, c *paymentclient.Client) error {
+	current, err := c.GetFees(ctx)
+	if err != nil {
+		return err
+	}
+	logger.Infof("fees: flat %d, %d bps, min %d, max %d, credited to %q",
+		current.Flat, current.Bps, current.Min, current.Max, current.Account)
+	return nil
+}
+
+func setKYCCommand(args []string) error {
+	fs := newCommandFlags("setkyc")
+	thresholds := fs.String("thresholds", "", "amount:level pairs, e.g. 1000:1,100000:2")
+	fs.Parse(args)
+	parsed, err := parseThresholds(*thresholds)
+	if err != nil {
+		return err
+	}
+	return fs.runOne(func(ctx context.Context, c *paymentclient.Client) error {
+		if _, err := c.SetKYC(ctx, &schema.KYCPolicy{Thresholds: parsed}); err != nil {
+			return err
+		}
+		return logKYC(ctx, c)
+	})
+}
+
+func kycCommand(args []string) error {
+	fs := newCommandFlags("kyc")
+	fs.Parse(args)
+	return fs.runOne(logKYC)
+}
+
+// logKYC logs the KYC thresholds of transfers.
+func logKYC(ctx context.Context, c *paymentclient.Client) error {
+	current, err := c.GetKYC(ctx)
+	if err != nil {
+		return err
+	}
+	if current.IsZero() {
+		logger.Infof("kyc: no threshold, every transfer is allowed")
+	}
+	for _, t := range current.Thresholds {
+		logger.Infof("kyc: transfers above %d require level %d", t.Amount, t.Level)
+	}
+	return nil
+}
+
+func setACLCommand(args []string) error {
+	fs := newCommandFlags("setacl")
+	function := fs.String("function", "", "chaincode function, * for the default rule")
+	msps := fs.String("msps", "", "comma separated MSP IDs allowed, none removes the rule")
+	ous := fs.String("ous", "", "comma separated organizational units allowed")
+	roles := fs.String("roles", "", "comma separated roles allowed")
+	fs.Parse(args)
+	if *function == "" {
+		fs.Usage()
+		return errors.New("setacl expects -function")
+	}
+	return fs.runOne(func(ctx context.Context, c *paymentclient.Client) error {
+		rule := schema.ACLRule{MSPs: splitList(*msps), OUs: splitList(*ous), Roles: splitList(*roles)}
+		if _, err := c.SetACLRule(ctx, *function, rule); err != nil {
+			return err
+		}
+		return showACL(ctx, c)
+	})
+}
+
+func aclCommand(args []string) error {
+	fs := newCommandFlags("acl")
+	fs.Parse(args)
+	return fs.runOne(showACL)
+}
+
+// showACL logs the access control list of the chaincode functions.
+func showACL(ctx context.Context, c *paymentclient.Client) error {
+	acl, err := c.GetACL(ctx)
+	if err != nil {
+		return err
+	}
+	logACL(acl)
+	return nil
+}
+
+func registerAssetCommand(args []string) error {
+	fs := newCommandFlags("registerasset")
+	asset := schema.Asset{}
+	fs.StringVar(&asset.Code, "code", "", "asset code")
+	fs.StringVar(&asset.Symbol, "symbol", "", "asset symbol")
+	fs.StringVar(&asset.Name, "name", "", "asset name")
+	fs.IntVar(&asset.Decimals, "decimals", 0, "decimals of the asset")
+	fs.Parse(args)
+	return fs.runOne(func(ctx context.Context, c *paymentclient.Client) error {
+		tx, err := c.RegisterAsset(ctx, &asset)
+		if err != nil {
+			return err
+		}
+		logger.Infof("registered asset %s in transaction %s, block %d", asset.Code, tx.TxID, tx.BlockNumber)
+		return nil
+	})
+}
+
+func assetsCommand(args []string) error {
+	fs := newCommandFlags("assets")
+	fs.Parse(args)
+	return fs.runOne(func(ctx context.Context, c *paymentclient.Client) error {
+		assets, err := c.GetAssets(ctx)
+		if err != nil {
+			return err
+		}
+		for _, a := range assets {
+			logger.Infof("%s %q %s, %d decimals", a.Code, a.Symbol, a.Name, a.Decimals)
+		}
+		return nil
+	})
+}
+
+func balanceCommand(args []string) error {
+	fs := newCommandFlags("balance")
+	account := fs.account("account", "account key")
+	asset := fs.String("asset", "", "asset code, the default asset if empty")
+	fs.Parse(args)
+	if *account == "" {
+		fs.Usage()
+		return errors.New("balance expects -account")
+	}
+	return fs.runOne(func(ctx context.Context, c *paymentclient.Client) error {
+		state, err := c.GetAccount(ctx, *account, *asset)
+		if err != nil {
+			return err
+		}
+		balance := state.Balance
+		if *asset == "" {
+			logger.Infof("account %s: %d", *account, balance)
+			return nil
+		}
+		assets, err := c.GetAssets(ctx)
+		if err != nil {
+			return err
+		}
+		for _, a := range assets {
+			if a.Code == *asset {
+				logger.Infof("account %s: %s %s", *account, formatAmount(balance, a.Decimals), a.Code)
+			}
+		}
+		return nil
+	})
+}
+
+func swapCommand(args []string) error {
+	fs := newCommandFlags("swap")
+	swap := schema.Swap{}
+	fs.accountVar(&swap.A, "a", "first account")
+	fs.StringVar(&swap.AssetA, "asseta", "", "asset given by the first account")
+	fs.Int64Var(&swap.AmountA, "amounta", 0, "amount given by the first account")
+	fs.accountVar(&swap.B, "b", "second account")
+	fs.StringVar(&swap.AssetB, "assetb", "", "asset given by the second account")
+	fs.Int64Var(&swap.AmountB, "amountb", 0, "amount given by the second account")
+	fs.Parse(args)
+	return fs.runOne(func(ctx context.Context, c *paymentclient.Client) error {
+		tx, err := c.Swap(ctx, &swap)
+		if err != nil {
+			return err
+		}
+		logger.Infof("swapped %d %s of %s for %d %s of %s in transaction %s, block %d",
+			swap.AmountA, swap.AssetA, swap.A, swap.AmountB, swap.AssetB, swap.B, tx.TxID, tx.BlockNumber)
+		return nil
+	})
+}
+
+func scheduleCommand(args []string) error {
+	fs := newCommandFlags("schedule")
+	schedule := schema.Schedule{}
+	fs.accountVar(&schedule.From, "from", "account paying")
+	fs.accountVar(&schedule.To, "to", "account paid")
+	fs.Int64Var(&schedule.Amount, "amount", 0, "amount of every payment")
+	fs.StringVar(&schedule.Asset, "asset", "", "asset code, the default asset if empty")
+	first := fs.String("first", "", "first execution time, RFC 3339 (default now)")
+	every := fs.Duration("every", 24*time.Hour, "interval between payments")
+	fs.IntVar(&schedule.Count, "count", 1, "number of payments")
+	fs.Parse(args)
+
+	start := time.Now()
+	if *first != "" {
+		var err error
+		if start, err = time.Parse(time.RFC3339, *first); err != nil {
+			return errors.Wrapf(err, "malformed -first %q", *first)
+		}
+	}
+	schedule.First = start.Unix()
+	schedule.Interval = int64(*every / time.Second)
+	return fs.runOne(func(ctx context.Context, c *paymentclient.Client) error {
+		id, err := c.SchedulePayment(ctx, &schedule)
+		if err != nil {
+			return err
+		}
+		logger.Infof("scheduled payment %s", id)
+		return nil
+	})
+}
+
+func scheduledCommand(args []string) error {
+	fs := newCommandFlags("scheduled")
+	id := fs.String("id", "", "scheduled payment id")
+	cancel := fs.Bool("cancel", false, "cancel the scheduled payment")
+	fs.Parse(args)
+	if *id == "" {
+		fs.Usage()
+		return errors.New("scheduled expects -id")
+	}
+	return fs.runOne(func(ctx context.Context, c *paymentclient.Client) error {
+		if *cancel {
+			if _, err := c.CancelSchedule(ctx, *id); err != nil {
+				return err
+			}
+		}
+		p, err := c.GetScheduledPayment(ctx, *id)
+		if err != nil {
+			return err
+		}
+		next := "finished"
+		if p.Next != 0 {
+			next = time.Unix(p.Next, 0).Format(time.RFC3339)
+		}
+		logger.Infof("scheduled payment %s: %d of %d executed, %d failed, next %s, last error %q",
+			p.ID, p.Executed, p.Schedule.Count, p.Failed, next, p.LastError)
+		return nil
+	})
+}
+
+func schedulerCommand(args []string) error {
+	fs := newCommandFlags("scheduler")
+	interval := fs.Duration("interval", time.Minute, "time between ticks")
+	limit := fs.Int("limit", 100, "payments executed per tick")
+	fs.Parse(args)
+	return fs.runOne(func(ctx context.Context, c *paymentclient.Client) error {
+		return RunScheduler(ctx, c, *interval, *limit)
+	})
+}
+
+func positionsCommand(args []string) error {
+	fs := newCommandFlags("positions")
+	org1 := fs.String("org1", "", "MSP ID of the first org of the pair")
+	org2 := fs.String("org2", "", "MSP ID of the second org of the pair")
+	fs.Parse(args)
+	if (*org1 == "") != (*org2 == "") {
+		fs.Usage()
+		return errors.New("positions expects both -org1 and -org2 or none")
+	}
+	return fs.runOne(func(ctx context.Context, c *paymentclient.Client) error {
+		open, err := c.GetPositions(ctx, *org1, *org2)
+		if err != nil {
+			return err
+		}
+		if open.More {
+			logger.Infof("more than %d position records are open, the positions are partial", open.Entries)
+		}
+		logPositions(open.Positions)
+		return nil
+	})
+}
+
+func settleCommand(args []string) error {
+	fs := newCommandFlags("settle")
+	to := fs.String("to", "", "end of the window to settle, RFC3339, now by default")
+	limit := fs.Int("limit", 1000, "maximum number of position records netted per transaction")
+	fs.Parse(args)
+	end := time.Now()
+	if *to != "" {
+		var err error
+		if end, err = time.Parse(time.RFC3339, *to); err != nil {
+			return errors.Wrapf(err, "malformed -to %q", *to)
+		}
+	}
+	return fs.runOne(func(ctx context.Context, c *paymentclient.Client) error {
+		settlement, err := RunSettle(ctx, c, end, *limit)
+		if err != nil {
+			return err
+		}
+		logger.Infof("settlement %s of %s to %s, %d records netted",
+			settlement.ID, time.Unix(settlement.From, 0).Format(time.RFC3339), time.Unix(settlement.To, 0).Format(time.RFC3339), settlement.Entries)
+		logPositions(settlement.Positions)
+		return nil
+	})
+}
+
+func disputeCommand(args []string) error {
+	fs := newCommandFlags("dispute")
+	txID := fs.String("tx", "", "transaction id of the transfer, id/occurrence for a scheduled payment")
+	reason := fs.String("reason", "", "reason of the dispute")
+	fs.Parse(args)
+	if *txID == "" {
+		fs.Usage()
+		return errors.New("dispute expects -tx")
+	}
+	return fs.runOne(func(ctx context.Context, c *paymentclient.Client) error {
+		record, err := c.Dispute(ctx, *txID, *reason)
+		if err != nil {
+			return err
+		}
+		logTransferRecord(record)
+		return nil
+	})
+}
+
+func approveReversalCommand(args []string) error {
+	fs := newCommandFlags("approvereversal")
+	txID := fs.String("tx", "", "transaction id of the transfer, id/occurrence for a scheduled payment")
+	fs.Parse(args)
+	if *txID == "" {
+		fs.Usage()
+		return errors.New("approvereversal expects -tx")
+	}
+	return fs.runOne(func(ctx context.Context, c *paymentclient.Client) error {
+		record, err := c.ApproveReversal(ctx, *txID)
+		if err != nil {
+			return err
+		}
+		logTransferRecord(record)
+		return nil
+	})
+}
+
+// logTransferRecord logs the status of a disputed transfer.
+func logTransferRecord(record *schema.TransferRecord) {
+	r := record.Receipt
+	logger.Infof("transfer %s of %d from %s to %s is %s", record.ID, r.Amount, r.From, r.To, record.Status)
+}
+
+func repayDebtCommand(args []string) error {
+	fs := newCommandFlags("repaydebt")
+	txID := fs.String("tx", "", "transaction id of the indebted transfer")
+	amount := fs.Int64("amount", 0, "amount to repay, all that is left by default")
+	fs.Parse(args)
+	if *txID == "" {
+		fs.Usage()
+		return errors.New("repaydebt expects -tx")
+	}
+	return fs.runOne(func(ctx context.Context, c *paymentclient.Client) error {
+		debt, err := c.RepayDebt(ctx, *txID, *amount)
+		if err != nil {
+			return err
+		}
+		logger.Infof("%s repaid %d of %d %s to %s (transfer %s)", debt.Debtor, debt.Repaid, debt.Amount, debt.Asset, debt.Creditor, debt.Transfer)
+		return nil
+	})
+}
+
+func historyCommand(args []string) error {
+	fs := newCommandFlags("history")
+	account := fs.account("account", "account id")
+	fs.Parse(args)
+	if *account == "" {
+		fs.Usage()
+		return errors.New("history expects -account")
+	}
+	return fs.runOne(func(ctx context.Context, c *paymentclient.Client) error {
+		history, err := c.GetHistory(ctx, *account)
+		if err != nil {
+			return err
+		}
+		for _, h := range history {
+			logger.Infof("%s %s: transfer %s of %d %s with %s %s",
+				time.Unix(h.Time, 0).Format(time.RFC3339), h.TxID, h.Transfer, h.Amount, h.Asset, h.Counterparty, h.Event)
+		}
+		debts, err := c.GetDebts(ctx, *account)
+		if err != nil {
+			return err
+		}
+		for _, d := range debts {
+			logger.Infof("%s owes %s %d %s (transfer %s)", d.Debtor, d.Creditor, d.Owed(), d.Asset, d.Transfer)
+		}
+		return nil
+	})
+}
+
+func registerAliasCommand(args []string) error {
+	fs := newCommandFlags("registeralias")
+	alias := fs.String("alias", "", "alias, e.g. alice@bank1")
+	account := fs.String("account", "", "account key")
+	fs.Parse(args)
+	if *alias == "" || *account == "" {
+		fs.Usage()
+		return errors.New("registeralias expects -alias and -account")
+	}
+	return fs.runOne(func(ctx context.Context, c *paymentclient.Client) error {
+		if _, err := c.RegisterAlias(ctx, *alias, *account); err != nil {
+			return err
+		}
+		return logAlias(ctx, c, *alias)
+	})
+}
+
+func aliasCommand(args []string) error {
+	fs := newCommandFlags("alias")
+	alias := fs.String("alias", "", "alias, e.g. alice@bank1")
+	fs.Parse(args)
+	if *alias == "" {
+		fs.Usage()
+		return errors.New("alias expects -alias")
+	}
+	return fs.runOne(func(ctx context.Context, c *paymentclient.Client) error {
+		return logAlias(ctx, c, *alias)
+	})
+}
+
+func transferAliasCommand(args []string) error {
+	fs := newCommandFlags("transferalias")
+	alias := fs.String("alias", "", "alias, e.g. alice@bank1")
+	owner := fs.String("owner", "", "identity of the new owner, as shown by whoami")
+	fs.Parse(args)
+	if *alias == "" || *owner == "" {
+		fs.Usage()
+		return errors.New("transferalias expects -alias and -owner")
+	}
+	return fs.runOne(func(ctx context.Context, c *paymentclient.Client) error {
+		if _, err := c.TransferAlias(ctx, *alias, *owner); err != nil {
+			return err
+		}
+		return logAlias(ctx, c, *alias)
+	})
+}
+
+// logAlias logs the account and the owner of alias.
+func logAlias(ctx context.Context, c *paymentclient.Client, alias string) error {
+	registered, err := c.GetAlias(ctx, alias)
+	if err != nil {
+		return err
+	}
+	logger.Infof("alias %s: account %s, owner %s", registered.Name, registered.Account, registered.Owner)
+	return nil
+}
+
+func whoAmICommand(args []string) error {
+	fs := newCommandFlags("whoami")
+	fs.Parse(args)
+	return fs.runOne(func(ctx context.Context, c *paymentclient.Client) error {
+		identity, err := c.WhoAmI(ctx)
+		if err != nil {
+			return err
+		}
+		logger.Infof("%s of %s", identity.ID, identity.MSPID)
+		return nil
+	})
+}
+
+func rotateCommand(args []string) error {
+	fs := newCommandFlags("rotate")
+	page := fs.Int("page", 100, "accounts re-encrypted per transaction")
+	fs.Parse(args)
+	if *fs.key == "" {
+		fs.Usage()
+		return errors.New("rotate expects the new -key")
+	}
+	return fs.runOne(func(ctx context.Context, c *paymentclient.Client) error {
+		if err := c.RotateAll(ctx, *page); err != nil {
+			return err
+		}
+		state, err := c.GetKeyState(ctx)
+		if err != nil {
+			return err
+		}
+		logger.Infof("account states are encrypted with key %d", state.Version)
+		return nil
+	})
+}
+
+func snapshotCommand(args []string) error {
+	fs := newCommandFlags("snapshot")
+	page := fs.Int("page", 100, "accounts per transaction")
+	fs.Parse(args)
+	return fs.runOne(func(ctx context.Context, c *paymentclient.Client) error {
+		snapshot, err := c.TakeSnapshot(ctx, *page)
+		if err != nil {
+			return err
+		}
+		logger.Infof("snapshot %s of %d accounts at height %d, the balances committed before its transaction, %s: root %s",
+			snapshot.ID, snapshot.Accounts, snapshot.Height, time.Unix(snapshot.Time, 0).Format(time.RFC3339), snapshot.Root)
+		return nil
+	})
+}
+
+func proofCommand(args []string) error {
+	fs := newCommandFlags("proof")
+	account := fs.account("account", "account id")
+	id := fs.String("id", "", "snapshot id, the latest snapshot by default")
+	out := fs.String("out", "", "file the proof is written to, for verify")
+	fs.Parse(args)
+	if *account == "" {
+		fs.Usage()
+		return errors.New("proof expects -account")
+	}
+	return fs.runOne(func(ctx context.Context, c *paymentclient.Client) error {
+		proof, err := c.GetProof(ctx, *account, *id)
+		if err != nil {
+			return err
+		}
+		// the root recorded by the chaincode, not the one the proof claims
+		snapshot, err := c.GetSnapshot(ctx, proof.Snapshot)
+		if err != nil {
+			return err
+		}
+		if err := proof.Verify(snapshot.Root); err != nil {
+			return err
+		}
+		logger.Infof("account %s held %d at height %d, before transaction %s (snapshot root %s)", proof.Key, proof.Balance, proof.Height, proof.Snapshot, snapshot.Root)
+		if *out == "" {
+			return nil
+		}
+		d, err := proof.ToBytes()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		return errors.WithStack(ioutil.WriteFile(*out, d, 0644))
+	})
+}
+
+// verifyCommand checks a proof offline, without the SDK.
+func verifyCommand(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	path := fs.String("proof", "", "proof file written by the proof command")
+	root := fs.String("root", "", "hex root of the snapshot, from a trusted source")
+	fs.Parse(args)
+	if *path == "" || *root == "" {
+		fs.Usage()
+		return errors.New("verify expects -proof and -root")
+	}
+	d, err := ioutil.ReadFile(*path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	var proof merkle.Proof
+	if err := proof.FromBytes(d); err != nil {
+		return err
+	}
+	if err := proof.Verify(*root); err != nil {
+		return err
 	}
+	logger.Infof("valid: account %s held %d at height %d, before transaction %s, %s", proof.Key, proof.Balance, proof.Height, proof.Snapshot, time.Unix(proof.Time, 0).Format(time.RFC3339))
+	return nil
 }
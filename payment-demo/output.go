@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+)
+
+// printer writes the result of a command to stdout, as a line of text or as
+// JSON for scripts. The logs go to stderr.
+type printer struct {
+	json bool
+}
+
+// newPrinter returns the printer of the -output flag, text or json.
+func newPrinter(format string) (*printer, error) {
+	switch format {
+	case "text":
+		return &printer{}, nil
+	case "json":
+		return &printer{json: true}, nil
+	}
+	return nil, errors.Errorf("unknown output %q, expecting text or json", format)
+}
+
+// table writes the rows as aligned columns, JSON outputs print them as part
+// of their result instead.
+func (p *printer) table(header []string, rows [][]string) error {
+	if p.json {
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return errors.WithStack(w.Flush())
+}
+
+// print writes v as JSON, or the text of format and args.
+func (p *printer) print(v interface{}, format string, args ...interface{}) error {
+	if p.json {
+		return errors.WithStack(json.NewEncoder(os.Stdout).Encode(v))
+	}
+	_, err := fmt.Fprintf(os.Stdout, format+"\n", args...)
+	return errors.WithStack(err)
+}
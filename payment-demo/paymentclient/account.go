@@ -0,0 +1,111 @@
+package paymentclient
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/schema"
+	"github.com/pkg/errors"
+)
+
+// AccountInfo is the state of the account Key in Asset, the default asset
+// if empty.
+type AccountInfo struct {
+	Key   string `json:"account"`
+	Asset string `json:"asset,omitempty"`
+	schema.Account
+}
+
+// TransferReceipt is the receipt of a committed transfer.
+type TransferReceipt struct {
+	Tx
+	schema.TransferReceipt
+}
+
+// CreateAccount creates the asset balance of account with amount, the
+// default asset if asset is empty.
+func (c *Client) CreateAccount(ctx context.Context, account, asset string, amount int64) (*Tx, error) {
+	tmp := schema.Payload{To: account, Amount: amount, Asset: asset}
+	if err := tmp.Validate(schema.Create); err != nil {
+		return nil, errors.WithMessage(err, "CreateAccount failed (invalid payload).")
+	}
+	payload, err := tmp.Encode(c.encoding)
+	if err != nil {
+		return nil, errors.WithMessage(err, "CreateAccount failed (marshall payload).")
+	}
+
+	_, tx, err := c.execute(ctx, "create", payload)
+	if err != nil {
+		return nil, errors.WithMessage(err, fmt.Sprintf("create %s balance of account %s failed.", asset, account))
+	}
+	return tx, nil
+}
+
+// Transfer moves amount of asset, the default asset if empty, from one
+// account to another.
+func (c *Client) Transfer(ctx context.Context, from, to, asset string, amount int64) (*TransferReceipt, error) {
+	payload, err := c.transferPayload(from, to, asset, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	response, tx, err := c.execute(ctx, "transfer", payload)
+	if err != nil {
+		return nil, errors.WithMessage(err, fmt.Sprintf("transfer of %d %s from %s to %s failed.", amount, asset, from, to))
+	}
+	// the private chaincode returns no receipt
+	receipt := &TransferReceipt{Tx: *tx, TransferReceipt: schema.TransferReceipt{From: from, To: to, Asset: asset, Amount: amount}}
+	if len(response) != 0 {
+		if err := receipt.TransferReceipt.FromBytes(response); err != nil {
+			return nil, err
+		}
+	}
+	return receipt, nil
+}
+
+func (c *Client) transferPayload(from, to, asset string, amount int64) ([]byte, error) {
+	tmp := schema.Payload{From: from, To: to, Amount: amount, Asset: asset}
+	if err := tmp.Validate(schema.Transfer); err != nil {
+		return nil, errors.WithMessage(err, "Transfer failed (invalid payload).")
+	}
+	payload, err := tmp.Encode(c.encoding)
+	if err != nil {
+		return nil, errors.WithMessage(err, "Transfer failed (marshall payload).")
+	}
+	return payload, nil
+}
+
+// GetAccount returns the asset balance of account, the default asset if asset is empty.
+func (c *Client) GetAccount(ctx context.Context, account, asset string) (*AccountInfo, error) {
+	args := [][]byte{[]byte(account), []byte(c.encoding.String())}
+	// the private chaincode only takes the account and the encoding
+	if asset != "" {
+		args = append(args, []byte(asset))
+	}
+	response, err := c.query(ctx, "query", args...)
+	if err != nil {
+		return nil, errors.WithMessage(err, fmt.Sprintf("query %s balance of account %s failed.", asset, account))
+	}
+	info := &AccountInfo{Key: account, Asset: asset}
+	if err := info.Account.FromBytes(response); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// CreateBatch creates the accounts of a batch in one transaction.
+func (c *Client) CreateBatch(ctx context.Context, b *schema.Batch) (*Tx, error) {
+	if err := b.Validate(); err != nil {
+		return nil, errors.WithMessage(err, "CreateBatch failed (invalid batch).")
+	}
+	batch, err := b.ToBytes()
+	if err != nil {
+		return nil, errors.WithMessage(err, "CreateBatch failed (marshall batch).")
+	}
+
+	_, tx, err := c.execute(ctx, "createBatch", batch)
+	if err != nil {
+		return nil, errors.WithMessage(err, fmt.Sprintf("createBatch of accounts %d-%d failed.", b.First, b.First+int64(len(b.Paddings))-1))
+	}
+	return tx, nil
+}
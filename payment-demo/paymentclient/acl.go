@@ -0,0 +1,51 @@
+package paymentclient
+
+import (
+	"context"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/schema"
+	"github.com/pkg/errors"
+)
+
+// SetACL replaces the access control list of the chaincode functions, the
+// client user must belong to an admin MSP.
+func (c *Client) SetACL(ctx context.Context, acl schema.ACL) (*Tx, error) {
+	if err := acl.Validate(); err != nil {
+		return nil, errors.WithMessage(err, "SetACL failed (invalid acl).")
+	}
+	d, err := acl.ToBytes()
+	if err != nil {
+		return nil, errors.WithMessage(err, "SetACL failed (marshall acl).")
+	}
+
+	_, tx, err := c.execute(ctx, "setACL", d)
+	if err != nil {
+		return nil, errors.WithMessage(err, "set acl failed.")
+	}
+	return tx, nil
+}
+
+// GetACL returns the access control list of the chaincode functions.
+func (c *Client) GetACL(ctx context.Context) (schema.ACL, error) {
+	response, err := c.query(ctx, "acl")
+	if err != nil {
+		return nil, errors.WithMessage(err, "get acl failed.")
+	}
+
+	return schema.DecodeACL(response)
+}
+
+// SetACLRule replaces the rule of function in the access control list, a
+// rule without MSPs removes it.
+func (c *Client) SetACLRule(ctx context.Context, function string, rule schema.ACLRule) (*Tx, error) {
+	acl, err := c.GetACL(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(rule.MSPs) == 0 {
+		delete(acl, function)
+	} else {
+		acl[function] = rule
+	}
+	return c.SetACL(ctx, acl)
+}
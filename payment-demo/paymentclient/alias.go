@@ -0,0 +1,71 @@
+package paymentclient
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/schema"
+	"github.com/pkg/errors"
+)
+
+// RegisterAlias maps alias to the account key, the client user becomes its
+// owner and must belong to the org of the account.
+func (c *Client) RegisterAlias(ctx context.Context, alias, account string) (*Tx, error) {
+	if err := schema.ValidateAlias(alias); err != nil {
+		return nil, errors.WithMessage(err, "RegisterAlias failed.")
+	}
+	_, tx, err := c.execute(ctx, "registerAlias", []byte(alias), []byte(account))
+	if err != nil {
+		return nil, errors.WithMessage(err, "register alias "+alias+" failed.")
+	}
+	return tx, nil
+}
+
+// TransferAlias gives alias, owned by the client user, to the identity owner.
+func (c *Client) TransferAlias(ctx context.Context, alias, owner string) (*Tx, error) {
+	_, tx, err := c.execute(ctx, "transferAlias", []byte(alias), []byte(owner))
+	if err != nil {
+		return nil, errors.WithMessage(err, "transfer alias "+alias+" failed.")
+	}
+	return tx, nil
+}
+
+// GetAlias returns the registry entry of alias.
+func (c *Client) GetAlias(ctx context.Context, alias string) (*schema.Alias, error) {
+	response, err := c.query(ctx, "alias", []byte(alias))
+	if err != nil {
+		return nil, errors.WithMessage(err, "get alias "+alias+" failed.")
+	}
+
+	var registered schema.Alias
+	if err := registered.FromBytes(response); err != nil {
+		return nil, err
+	}
+	return &registered, nil
+}
+
+// ResolveAccount returns the account key of name, an alias or a key.
+func (c *Client) ResolveAccount(ctx context.Context, name string) (string, error) {
+	if !schema.IsAlias(name) {
+		return name, nil
+	}
+	alias, err := c.GetAlias(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	return alias.Account, nil
+}
+
+// WhoAmI returns the identity of the client user as seen by the chaincode.
+func (c *Client) WhoAmI(ctx context.Context) (*schema.Identity, error) {
+	response, err := c.query(ctx, "whoami")
+	if err != nil {
+		return nil, errors.WithMessage(err, "whoami failed.")
+	}
+
+	var identity schema.Identity
+	if err := json.Unmarshal(response, &identity); err != nil {
+		return nil, errors.Wrap(err, "malformed identity")
+	}
+	return &identity, nil
+}
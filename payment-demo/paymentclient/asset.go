@@ -0,0 +1,58 @@
+package paymentclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/schema"
+	"github.com/pkg/errors"
+)
+
+// RegisterAsset adds or updates an asset in the registry, the client user must belong to an admin MSP.
+func (c *Client) RegisterAsset(ctx context.Context, asset *schema.Asset) (*Tx, error) {
+	if err := asset.Validate(); err != nil {
+		return nil, errors.WithMessage(err, "RegisterAsset failed (invalid asset).")
+	}
+	d, err := asset.ToBytes()
+	if err != nil {
+		return nil, errors.WithMessage(err, "RegisterAsset failed (marshall asset).")
+	}
+
+	_, tx, err := c.execute(ctx, "registerAsset", d)
+	if err != nil {
+		return nil, errors.WithMessage(err, fmt.Sprintf("register asset %s failed.", asset.Code))
+	}
+	return tx, nil
+}
+
+// GetAssets returns the registered assets.
+func (c *Client) GetAssets(ctx context.Context) ([]schema.Asset, error) {
+	response, err := c.query(ctx, "assets")
+	if err != nil {
+		return nil, errors.WithMessage(err, "get assets failed.")
+	}
+
+	var assets []schema.Asset
+	if err := json.Unmarshal(response, &assets); err != nil {
+		return nil, errors.Wrap(err, "malformed asset list")
+	}
+	return assets, nil
+}
+
+// Swap exchanges two assets between two accounts atomically.
+func (c *Client) Swap(ctx context.Context, swap *schema.Swap) (*Tx, error) {
+	if err := swap.Validate(); err != nil {
+		return nil, errors.WithMessage(err, "Swap failed (invalid swap).")
+	}
+	d, err := swap.ToBytes()
+	if err != nil {
+		return nil, errors.WithMessage(err, "Swap failed (marshall swap).")
+	}
+
+	_, tx, err := c.execute(ctx, "swap", d)
+	if err != nil {
+		return nil, errors.WithMessage(err, fmt.Sprintf("swap between %s and %s failed.", swap.A, swap.B))
+	}
+	return tx, nil
+}
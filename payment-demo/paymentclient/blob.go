@@ -0,0 +1,57 @@
+package paymentclient
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/blob"
+	"github.com/pkg/errors"
+)
+
+// PutBlobManifest declares the chunks of a blob, declaring the same
+// manifest again is accepted.
+func (c *Client) PutBlobManifest(ctx context.Context, m *blob.Manifest) (*Tx, error) {
+	manifest, err := m.ToBytes()
+	if err != nil {
+		return nil, errors.WithMessage(err, "PutBlobManifest failed (marshall manifest).")
+	}
+
+	_, tx, err := c.execute(ctx, "putBlob", []byte(m.ID), manifest)
+	if err != nil {
+		return nil, errors.WithMessage(err, fmt.Sprintf("put manifest of blob %s failed.", m.ID))
+	}
+	return tx, nil
+}
+
+// PutBlobChunk stores the chunk index of blob id, checked against its manifest.
+func (c *Client) PutBlobChunk(ctx context.Context, id string, index int, data []byte) (*Tx, error) {
+	_, tx, err := c.execute(ctx, "putBlob", []byte(id), []byte(strconv.Itoa(index)), data)
+	if err != nil {
+		return nil, errors.WithMessage(err, fmt.Sprintf("put chunk %d of blob %s failed.", index, id))
+	}
+	return tx, nil
+}
+
+// GetBlobStatus returns the manifest of blob id and its missing chunks.
+func (c *Client) GetBlobStatus(ctx context.Context, id string) (*blob.Status, error) {
+	response, err := c.query(ctx, "getBlob", []byte(id))
+	if err != nil {
+		return nil, errors.WithMessage(err, fmt.Sprintf("get status of blob %s failed.", id))
+	}
+
+	var status blob.Status
+	if err := status.FromBytes(response); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// GetBlobChunk returns the chunk index of blob id, not verified.
+func (c *Client) GetBlobChunk(ctx context.Context, id string, index int) ([]byte, error) {
+	response, err := c.query(ctx, "getBlob", []byte(id), []byte(strconv.Itoa(index)))
+	if err != nil {
+		return nil, errors.WithMessage(err, fmt.Sprintf("get chunk %d of blob %s failed.", index, id))
+	}
+	return response, nil
+}
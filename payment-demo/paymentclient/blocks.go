@@ -0,0 +1,77 @@
+package paymentclient
+
+import (
+	"time"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	"github.com/pkg/errors"
+)
+
+// Block is a filtered block of the channel: the validation code of each of
+// its transactions, VALID or the reason of its invalidation, e.g.
+// MVCC_READ_CONFLICT.
+type Block struct {
+	Number       uint64
+	Received     time.Time
+	Transactions []BlockTx
+}
+
+// BlockTx is a transaction of a Block.
+type BlockTx struct {
+	TxID string
+	Code string
+}
+
+// WithSubmissions calls submitted with the id of every transaction when it is
+// sent to the orderer, to match it with the blocks, retries included.
+func WithSubmissions(submitted func(txID string, at time.Time)) Option {
+	return func(o *options) error {
+		o.submissions = submitted
+		return nil
+	}
+}
+
+// BlockWatch is the registration of WatchBlocks.
+type BlockWatch struct {
+	events       fab.EventService
+	registration fab.Registration
+	done         chan struct{}
+}
+
+// WatchBlocks calls record with every block committed on the channel from
+// now on, in order, until Stop.
+func (c *Client) WatchBlocks(record func(*Block)) (*BlockWatch, error) {
+	channelContext, err := c.context()
+	if err != nil {
+		return nil, errors.WithMessage(err, "create channel context failed.")
+	}
+	events, err := channelContext.ChannelService().EventService()
+	if err != nil {
+		return nil, errors.WithMessage(err, "create event service failed.")
+	}
+	registration, blocks, err := events.RegisterFilteredBlockEvent()
+	if err != nil {
+		return nil, errors.WithMessage(err, "register filtered block events failed.")
+	}
+
+	w := &BlockWatch{events: events, registration: registration, done: make(chan struct{})}
+	go func() {
+		defer close(w.done)
+		for event := range blocks {
+			fb := event.FilteredBlock
+			block := &Block{Number: fb.Number, Received: time.Now()}
+			for _, tx := range fb.FilteredTransactions {
+				block.Transactions = append(block.Transactions, BlockTx{TxID: tx.Txid, Code: tx.TxValidationCode.String()})
+			}
+			record(block)
+		}
+	}()
+	return w, nil
+}
+
+// Stop unregisters the watch and waits for the last record call to return.
+func (w *BlockWatch) Stop() {
+	// the event service closes the channel of the registration
+	w.events.Unregister(w.registration)
+	<-w.done
+}
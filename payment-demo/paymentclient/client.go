@@ -0,0 +1,269 @@
+// Package paymentclient is the Go client of the payment chaincode. A Client
+// signs its requests as one enrolled user and waits for the commit of every
+// invoke, which returns the id and the block number of its transaction.
+//
+//	sdk, err := fabsdk.New(config.FromFile("config-payment.yaml"))
+//	...
+//	client, err := paymentclient.New(sdk, paymentclient.WithUser("User1"))
+//	...
+//	receipt, err := client.Transfer(ctx, "1", "2", "", 10)
+package paymentclient
+
+import (
+	"context"
+	"encoding/hex"
+	"time"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/schema"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel/invoke"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/retry"
+	contextApi "github.com/hyperledger/fabric-sdk-go/pkg/common/providers/context"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fabsdk"
+	"github.com/hyperledger/fabric/common/flogging"
+	"github.com/pkg/errors"
+)
+
+// Defaults of the options.
+const (
+	DefaultChannel   = "mychannel"
+	DefaultChaincode = "mycc"
+	DefaultUser      = "User1"
+)
+
+// Transient map entries of the AES keys of the account states: the current
+// key and the key being rotated out.
+const (
+	AESKEY     = "AESKEY"
+	AESKEY_OLD = "AESKEY_OLD"
+)
+
+var logger = flogging.MustGetLogger("paymentclient")
+
+type options struct {
+	channel, chaincode string
+	user, org          string
+	encoding           schema.Encoding
+	keys               map[string][]byte
+	retry              retry.Opts
+	commitRetry        CommitRetry
+	timings            func(*Timing)
+	submissions        func(txID string, at time.Time)
+}
+
+// Option configures a Client.
+type Option func(*options) error
+
+// WithChannel sets the channel of the chaincode, DefaultChannel by default.
+func WithChannel(id string) Option {
+	return func(o *options) error {
+		o.channel = id
+		return nil
+	}
+}
+
+// WithChaincode sets the chaincode ID, DefaultChaincode by default. The
+// private chaincode only supports CreateAccount, Transfer and GetAccount.
+func WithChaincode(id string) Option {
+	return func(o *options) error {
+		o.chaincode = id
+		return nil
+	}
+}
+
+// WithUser sets the enrolled user signing the requests, DefaultUser by default.
+func WithUser(user string) Option {
+	return func(o *options) error {
+		o.user = user
+		return nil
+	}
+}
+
+// WithOrg sets the org of the user, the client org of the SDK configuration
+// by default.
+func WithOrg(org string) Option {
+	return func(o *options) error {
+		o.org = org
+		return nil
+	}
+}
+
+// WithEncoding sets the encoding of the payloads and of the account states
+// returned by the queries, JSON by default.
+func WithEncoding(enc schema.Encoding) Option {
+	return func(o *options) error {
+		o.encoding = enc
+		return nil
+	}
+}
+
+// WithKeys sets the AES keys of the encrypted account states passed in the
+// transient map of every request, they never reach the ledger. key is the
+// current key and oldKey the key being rotated out, nil when there is none.
+func WithKeys(key, oldKey []byte) Option {
+	return func(o *options) error {
+		o.keys = map[string][]byte{}
+		for name, k := range map[string][]byte{AESKEY: key, AESKEY_OLD: oldKey} {
+			if k == nil {
+				continue
+			}
+			if err := checkKeySize(k); err != nil {
+				return err
+			}
+			o.keys[name] = k
+		}
+		return nil
+	}
+}
+
+// WithRetry sets the retries of the requests, retry.DefaultChannelOpts by
+// default. The validation codes are left to WithCommitRetry.
+func WithRetry(opts retry.Opts) Option {
+	return func(o *options) error {
+		o.retry = opts
+		return nil
+	}
+}
+
+// ParseKey decodes a hex AES key, "" is no key.
+func ParseKey(s string) ([]byte, error) {
+	if s == "" {
+		return nil, nil
+	}
+	key, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, errors.Wrap(err, "malformed AES key, expecting hex")
+	}
+	if err := checkKeySize(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func checkKeySize(key []byte) error {
+	switch len(key) {
+	case 16, 24, 32:
+		return nil
+	}
+	return errors.Errorf("AES key must be 16, 24 or 32 bytes, got %d", len(key))
+}
+
+// Client invokes the payment chaincode. It is safe for concurrent use.
+type Client struct {
+	context     contextApi.ChannelProvider
+	channel     *channel.Client
+	channelID   string
+	chaincode   string
+	encoding    schema.Encoding
+	keys        map[string][]byte
+	retry       retry.Opts
+	commitRetry CommitRetry
+	timings     func(*Timing)
+	submissions func(txID string, at time.Time)
+	orderers    ordererCache
+}
+
+// New returns a client of the chaincode configured by opts.
+func New(sdk *fabsdk.FabricSDK, opts ...Option) (*Client, error) {
+	o := options{channel: DefaultChannel, chaincode: DefaultChaincode, user: DefaultUser,
+		encoding: schema.JSON, retry: retry.DefaultChannelOpts, commitRetry: DefaultCommitRetry}
+	for _, opt := range opts {
+		if err := opt(&o); err != nil {
+			return nil, err
+		}
+	}
+
+	contextOptions := []fabsdk.ContextOption{fabsdk.WithUser(o.user)}
+	if o.org != "" {
+		contextOptions = append(contextOptions, fabsdk.WithOrg(o.org))
+	}
+	channelContext := sdk.ChannelContext(o.channel, contextOptions...)
+	client, err := channel.New(channelContext)
+	if err != nil {
+		return nil, errors.WithMessage(err, "create channel client failed.")
+	}
+	return &Client{context: channelContext, channel: client, channelID: o.channel, chaincode: o.chaincode, encoding: o.encoding,
+		keys: o.keys, retry: withoutValidationCodes(o.retry), commitRetry: o.commitRetry,
+		timings: o.timings, submissions: o.submissions}, nil
+}
+
+// Tx identifies the committed transaction of an invoke, Attempts is the
+// number of transactions it took, see WithCommitRetry.
+type Tx struct {
+	TxID        string `json:"txID"`
+	BlockNumber uint64 `json:"blockNumber"`
+	Attempts    int    `json:"attempts"`
+}
+
+// execute invokes fcn, waits for the commit of its transaction and returns
+// the chaincode response. An invoke invalidated at commit is endorsed again
+// as long as the commit retry policy allows it.
+func (c *Client) execute(ctx context.Context, fcn string, args ...[]byte) ([]byte, *Tx, error) {
+	for attempt := 1; ; attempt++ {
+		payload, tx, timing, err := c.attempt(ctx, fcn, args)
+		code, retryable := c.commitRetry.retryable(err)
+		retried := retryable && attempt <= c.commitRetry.Attempts
+		if c.timings != nil {
+			timing.Attempt, timing.Retried = attempt, retried
+			c.timings(timing)
+		}
+		if err == nil {
+			tx.Attempts = attempt
+			return payload, tx, nil
+		}
+		if !retried {
+			return nil, nil, err
+		}
+		logger.Debugf("%s(%s) invalidated with %s, retrying", fcn, tx.TxID, code)
+		if err := c.commitRetry.backoff(ctx, attempt); err != nil {
+			return nil, nil, errors.WithMessage(err, fcn+" retry failed.")
+		}
+	}
+}
+
+// attempt runs the handler chain, with the retries of the SDK. Each attempt
+// endorses a new proposal, which reads the current state. The transaction is
+// returned even when it is invalid.
+func (c *Client) attempt(ctx context.Context, fcn string, args [][]byte) ([]byte, *Tx, *Timing, error) {
+	p := &phases{}
+	handler := &startHandler{p, invoke.NewProposalProcessorHandler(
+		invoke.NewEndorsementHandler(
+			invoke.NewEndorsementValidationHandler(
+				invoke.NewSignatureValidationHandler(&commitHandler{p, c.submissions}),
+			),
+		),
+	)}
+	start := time.Now()
+	response, err := c.channel.InvokeHandler(handler, c.request(fcn, args), c.requestOptions(ctx)...)
+	timing := p.timing(fcn, time.Since(start), err)
+	p.Lock()
+	tx := &Tx{TxID: string(response.TransactionID), BlockNumber: p.blockNumber}
+	p.Unlock()
+	if err != nil {
+		return nil, tx, timing, err
+	}
+	logger.Debugf("%s(%s) committed in block %d", fcn, tx.TxID, tx.BlockNumber)
+	return response.Payload, tx, timing, nil
+}
+
+// query evaluates fcn on the endorsers without submitting a transaction.
+func (c *Client) query(ctx context.Context, fcn string, args ...[]byte) ([]byte, error) {
+	start := time.Now()
+	response, err := c.channel.Query(c.request(fcn, args), c.requestOptions(ctx)...)
+	if c.timings != nil {
+		total := time.Since(start)
+		c.timings(&Timing{Fcn: fcn, Endorse: total, Total: total, Err: err})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return response.Payload, nil
+}
+
+func (c *Client) request(fcn string, args [][]byte) channel.Request {
+	return channel.Request{ChaincodeID: c.chaincode, Fcn: fcn, Args: args, TransientMap: c.keys}
+}
+
+func (c *Client) requestOptions(ctx context.Context) []channel.RequestOption {
+	return []channel.RequestOption{channel.WithRetry(c.retry), channel.WithParentContext(ctx)}
+}
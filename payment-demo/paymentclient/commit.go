@@ -0,0 +1,66 @@
+package paymentclient
+
+import (
+	"time"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel/invoke"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/status"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	pb "github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/peer"
+	"github.com/pkg/errors"
+)
+
+// commitHandler is the commit step of the SDK execute handler, which drops
+// the block number of the transaction status event, keeping it with the
+// times of the phases.
+type commitHandler struct {
+	*phases
+	submissions func(txID string, at time.Time)
+}
+
+// Handle sends the endorsed transaction to the orderer and waits for its
+// status event. An invalid transaction fails with the status of its
+// validation code, as in the SDK.
+func (h *commitHandler) Handle(requestContext *invoke.RequestContext, clientContext *invoke.ClientContext) {
+	h.mark(&h.endorsed)
+	txID := string(requestContext.Response.TransactionID)
+	registration, statusNotifier, err := clientContext.EventService.RegisterTxStatusEvent(txID)
+	if err != nil {
+		requestContext.Error = errors.Wrap(err, "error registering for TxStatus event")
+		return
+	}
+	defer clientContext.EventService.Unregister(registration)
+
+	tx, err := clientContext.Transactor.CreateTransaction(fab.TransactionRequest{
+		Proposal:          requestContext.Response.Proposal,
+		ProposalResponses: requestContext.Response.Responses,
+	})
+	if err != nil {
+		requestContext.Error = errors.WithMessage(err, "CreateTransaction failed")
+		return
+	}
+	if h.submissions != nil {
+		h.submissions(txID, time.Now())
+	}
+	if _, err := clientContext.Transactor.SendTransaction(tx); err != nil {
+		requestContext.Error = errors.WithMessage(err, "SendTransaction failed")
+		return
+	}
+	h.mark(&h.broadcast)
+
+	select {
+	case txStatus := <-statusNotifier:
+		h.Lock()
+		h.committed = time.Now()
+		h.blockNumber = txStatus.BlockNumber
+		h.Unlock()
+		requestContext.Response.TxValidationCode = txStatus.TxValidationCode
+		if txStatus.TxValidationCode != pb.TxValidationCode_VALID {
+			requestContext.Error = status.New(status.EventServerStatus, int32(txStatus.TxValidationCode),
+				"received invalid transaction", nil)
+		}
+	case <-requestContext.Ctx.Done():
+		requestContext.Error = status.New(status.ClientStatus, status.Timeout.ToInt32(),
+			"Execute didn't receive block event", nil)
+	}
+}
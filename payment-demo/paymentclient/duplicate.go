@@ -0,0 +1,125 @@
+package paymentclient
+
+import (
+	"context"
+	mrand "math/rand"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel/invoke"
+	contextApi "github.com/hyperledger/fabric-sdk-go/pkg/common/providers/context"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	contextImpl "github.com/hyperledger/fabric-sdk-go/pkg/context"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fab/txn"
+	"github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/common"
+	protos_utils "github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/utils"
+	"github.com/pkg/errors"
+)
+
+// Envelope is a transaction endorsed and signed once. Every Broadcast sends
+// the very same envelope to the orderer, as a client resending it would: the
+// peers commit the first copy and flag the others DUPLICATE_TXID.
+type Envelope struct {
+	TxID     string
+	envelope *fab.SignedEnvelope
+	context  contextApi.Channel
+	orderers *ordererCache
+}
+
+// ordererCache holds the orderers of the channel, created on the first
+// Broadcast.
+type ordererCache struct {
+	sync.Once
+	orderers []fab.Orderer
+	err      error
+}
+
+// Endorse endorses the invoke of fcn and signs its transaction without
+// sending it.
+func (c *Client) Endorse(ctx context.Context, fcn string, args ...[]byte) (*Envelope, error) {
+	response, err := c.channel.InvokeHandler(invoke.NewQueryHandler(), c.request(fcn, args), c.requestOptions(ctx)...)
+	if err != nil {
+		return nil, errors.WithMessage(err, fcn+" endorsement failed.")
+	}
+	tx, err := txn.New(fab.TransactionRequest{Proposal: response.Proposal, ProposalResponses: response.Responses})
+	if err != nil {
+		return nil, errors.WithMessage(err, "create transaction failed.")
+	}
+
+	// the payload txn.Send signs
+	header, err := protos_utils.GetHeader(tx.Proposal.Proposal.Header)
+	if err != nil {
+		return nil, errors.Wrap(err, "unmarshal proposal header failed")
+	}
+	data, err := protos_utils.GetBytesTransaction(tx.Transaction)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := proto.Marshal(&common.Payload{Header: header, Data: data})
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal payload failed")
+	}
+	channelContext, err := c.context()
+	if err != nil {
+		return nil, errors.WithMessage(err, "create channel context failed.")
+	}
+	signature, err := channelContext.SigningManager().Sign(payload, channelContext.PrivateKey())
+	if err != nil {
+		return nil, errors.WithMessage(err, "sign payload failed.")
+	}
+	return &Envelope{TxID: string(response.TransactionID), envelope: &fab.SignedEnvelope{Payload: payload, Signature: signature},
+		context: channelContext, orderers: &c.orderers}, nil
+}
+
+// EndorseTransfer endorses a transfer, see Client.Transfer.
+func (c *Client) EndorseTransfer(ctx context.Context, from, to, asset string, amount int64) (*Envelope, error) {
+	payload, err := c.transferPayload(from, to, asset, amount)
+	if err != nil {
+		return nil, err
+	}
+	return c.Endorse(ctx, "transfer", payload)
+}
+
+// Broadcast sends a copy of the envelope to the orderers of the channel in a
+// random order until one of them accepts it, and returns its URL. It does not
+// wait for the commit, see WatchBlocks.
+func (e *Envelope) Broadcast(ctx context.Context) (string, error) {
+	orderers, err := e.orderers.get(e.context)
+	if err != nil {
+		return "", err
+	}
+	reqCtx, cancel := contextImpl.NewRequest(e.context, contextImpl.WithTimeoutType(fab.OrdererResponse),
+		contextImpl.WithParent(ctx))
+	defer cancel()
+
+	for _, i := range mrand.Perm(len(orderers)) {
+		if _, err = orderers[i].SendBroadcast(reqCtx, e.envelope); err == nil {
+			return orderers[i].URL(), nil
+		}
+		err = errors.WithMessage(err, "broadcast of "+e.TxID+" to "+orderers[i].URL()+" failed.")
+	}
+	return "", err
+}
+
+// get creates the orderers of the channel listed in the configuration, all
+// the orderers configured if none is.
+func (o *ordererCache) get(ctx contextApi.Channel) ([]fab.Orderer, error) {
+	o.Do(func() {
+		configs, ok := ctx.EndpointConfig().ChannelOrderers(ctx.ChannelID())
+		if !ok || len(configs) == 0 {
+			configs = ctx.EndpointConfig().OrderersConfig()
+		}
+		for i := range configs {
+			orderer, err := ctx.InfraProvider().CreateOrdererFromConfig(&configs[i])
+			if err != nil {
+				o.err = errors.WithMessage(err, "create orderer failed.")
+				return
+			}
+			o.orderers = append(o.orderers, orderer)
+		}
+		if len(o.orderers) == 0 {
+			o.err = errors.Errorf("no orderer configured for channel %s", ctx.ChannelID())
+		}
+	})
+	return o.orderers, o.err
+}
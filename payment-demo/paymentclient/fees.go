@@ -0,0 +1,35 @@
+package paymentclient
+
+import (
+	"context"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/schema"
+	"github.com/pkg/errors"
+)
+
+// SetFees replaces the fee schedule of transfers, the client user must belong to an admin MSP.
+func (c *Client) SetFees(ctx context.Context, fees *schema.FeeSchedule) (*Tx, error) {
+	if err := fees.Validate(); err != nil {
+		return nil, errors.WithMessage(err, "SetFees failed (invalid fee schedule).")
+	}
+	d, err := fees.ToBytes()
+	if err != nil {
+		return nil, errors.WithMessage(err, "SetFees failed (marshall fee schedule).")
+	}
+
+	_, tx, err := c.execute(ctx, "setFees", d)
+	if err != nil {
+		return nil, errors.WithMessage(err, "set fee schedule failed.")
+	}
+	return tx, nil
+}
+
+// GetFees returns the fee schedule of transfers.
+func (c *Client) GetFees(ctx context.Context) (*schema.FeeSchedule, error) {
+	response, err := c.query(ctx, "fees")
+	if err != nil {
+		return nil, errors.WithMessage(err, "get fee schedule failed.")
+	}
+
+	return schema.DecodeFeeSchedule(response)
+}
@@ -0,0 +1,76 @@
+package paymentclient
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/schema"
+	"github.com/pkg/errors"
+)
+
+// rotateAttempts is the number of tries of a rotate page, a page conflicts
+// with the transfers committed meanwhile on the same accounts.
+const rotateAttempts = 3
+
+// Rotate re-encrypts one page of accounts with the key set by WithKeys and
+// returns the bookmark of the next page. The client user must belong to an
+// admin MSP.
+func (c *Client) Rotate(ctx context.Context, bookmark string, page int) (*schema.RotateResult, error) {
+	response, _, err := c.execute(ctx, "rotate", []byte(bookmark), []byte(strconv.Itoa(page)))
+	if err != nil {
+		return nil, errors.WithMessage(err, "rotate failed.")
+	}
+
+	var result schema.RotateResult
+	if err := result.FromBytes(response); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// RotateAll drives a rotation to the key set by WithKeys to completion, or
+// resumes the rotation in progress.
+func (c *Client) RotateAll(ctx context.Context, page int) error {
+	state, err := c.GetKeyState(ctx)
+	if err != nil {
+		return err
+	}
+	if state.Rotating {
+		// the pages already rotated are skipped quickly
+		logger.Infof("resuming the rotation from key %d to %d", state.Previous, state.Version)
+	}
+
+	bookmark, total := "", 0
+	for {
+		var result *schema.RotateResult
+		for attempt := 1; ; attempt++ {
+			if result, err = c.Rotate(ctx, bookmark, page); err == nil {
+				break
+			}
+			if attempt == rotateAttempts || ctx.Err() != nil {
+				return errors.WithMessage(err, "bookmark "+strconv.Quote(bookmark))
+			}
+			logger.Infof("rotate page failed, retrying. %s", err)
+		}
+		total += result.Rotated
+		logger.Infof("key %d: %d account states re-encrypted", result.Version, total)
+		if result.Done {
+			return nil
+		}
+		bookmark = result.Bookmark
+	}
+}
+
+// GetKeyState returns the state of the account encryption.
+func (c *Client) GetKeyState(ctx context.Context) (*schema.KeyState, error) {
+	response, err := c.query(ctx, "keyState")
+	if err != nil {
+		return nil, errors.WithMessage(err, "get key state failed.")
+	}
+
+	var state schema.KeyState
+	if err := state.FromBytes(response); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
@@ -0,0 +1,35 @@
+package paymentclient
+
+import (
+	"context"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/schema"
+	"github.com/pkg/errors"
+)
+
+// SetKYC replaces the KYC policy of transfers, the client user must belong to an admin MSP.
+func (c *Client) SetKYC(ctx context.Context, policy *schema.KYCPolicy) (*Tx, error) {
+	if err := policy.Validate(); err != nil {
+		return nil, errors.WithMessage(err, "SetKYC failed (invalid kyc policy).")
+	}
+	d, err := policy.ToBytes()
+	if err != nil {
+		return nil, errors.WithMessage(err, "SetKYC failed (marshall kyc policy).")
+	}
+
+	_, tx, err := c.execute(ctx, "setKYC", d)
+	if err != nil {
+		return nil, errors.WithMessage(err, "set kyc policy failed.")
+	}
+	return tx, nil
+}
+
+// GetKYC returns the KYC policy of transfers.
+func (c *Client) GetKYC(ctx context.Context) (*schema.KYCPolicy, error) {
+	response, err := c.query(ctx, "kyc")
+	if err != nil {
+		return nil, errors.WithMessage(err, "get kyc policy failed.")
+	}
+
+	return schema.DecodeKYCPolicy(response)
+}
@@ -0,0 +1,40 @@
+package paymentclient
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/schema"
+	"github.com/pkg/errors"
+)
+
+// SetLimits replaces the spending limits of account, the client user must belong to an admin MSP.
+func (c *Client) SetLimits(ctx context.Context, account string, limits *schema.Limits) (*Tx, error) {
+	if err := limits.Validate(); err != nil {
+		return nil, errors.WithMessage(err, "SetLimits failed (invalid limits).")
+	}
+	d, err := limits.ToBytes()
+	if err != nil {
+		return nil, errors.WithMessage(err, "SetLimits failed (marshall limits).")
+	}
+
+	_, tx, err := c.execute(ctx, "setLimits", []byte(account), d)
+	if err != nil {
+		return nil, errors.WithMessage(err, fmt.Sprintf("set limits of account %s failed.", account))
+	}
+	return tx, nil
+}
+
+// GetLimits returns the limits of account and what remains of them.
+func (c *Client) GetLimits(ctx context.Context, account string) (*schema.LimitStatus, error) {
+	response, err := c.query(ctx, "limits", []byte(account))
+	if err != nil {
+		return nil, errors.WithMessage(err, fmt.Sprintf("get limits of account %s failed.", account))
+	}
+
+	var status schema.LimitStatus
+	if err := status.FromBytes(response); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
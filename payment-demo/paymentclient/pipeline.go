@@ -0,0 +1,254 @@
+package paymentclient
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel/invoke"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/retry"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/status"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	pb "github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/peer"
+	"github.com/pkg/errors"
+)
+
+// pipelineCommitTimeout bounds the wait for the commit event of a
+// transaction of a pipeline.
+const pipelineCommitTimeout = 2 * time.Minute
+
+// PipelineOptions configures a Pipeline.
+type PipelineOptions struct {
+	// Endorsers is the number of proposals endorsed concurrently.
+	Endorsers int
+	// Burst is the number of endorsed transactions broadcast together, Linger
+	// the longest wait for a burst to fill up.
+	Burst  int
+	Linger time.Duration
+	// Window bounds the transactions broadcast and not committed yet, a burst
+	// waits for room in the window.
+	Window int
+}
+
+// Submission is the outcome of a transaction given to a pipeline, with the
+// time it reached each stage. Err is set when it failed to be endorsed,
+// broadcast or committed as valid, Code is its validation code once in a
+// block. Accounts are the accounts of a transfer.
+type Submission struct {
+	Fcn         string
+	Accounts    []string
+	TxID        string
+	Submitted   time.Time
+	Endorsed    time.Time
+	Broadcast   time.Time
+	Committed   time.Time
+	BlockNumber uint64
+	Code        string
+	Err         error
+}
+
+// Pipeline endorses transactions concurrently, then broadcasts them to the
+// orderer in bursts while a bounded window of them waits for their commit
+// events, instead of one transaction at a time from endorsement to commit.
+type Pipeline struct {
+	client   *Client
+	opts     PipelineOptions
+	done     func(*Submission)
+	requests chan *pipelineTx
+	endorsed chan *pipelineTx
+	window   chan struct{}
+
+	endorsers, batcher, commits sync.WaitGroup
+}
+
+type pipelineTx struct {
+	ctx      context.Context
+	args     [][]byte
+	response channel.Response
+	*Submission
+}
+
+// NewPipeline starts a pipeline calling done with the outcome of every
+// transaction, concurrently.
+func (c *Client) NewPipeline(opts PipelineOptions, done func(*Submission)) (*Pipeline, error) {
+	if opts.Endorsers < 1 || opts.Burst < 1 || opts.Window < opts.Burst {
+		return nil, errors.Errorf("pipeline needs endorsers and a burst of at least 1 and a window of at least the burst, got %d, %d and %d",
+			opts.Endorsers, opts.Burst, opts.Window)
+	}
+	p := &Pipeline{client: c, opts: opts, done: done,
+		requests: make(chan *pipelineTx, opts.Endorsers),
+		endorsed: make(chan *pipelineTx, opts.Burst),
+		window:   make(chan struct{}, opts.Window)}
+	for i := 0; i < opts.Endorsers; i++ {
+		p.endorsers.Add(1)
+		go p.endorse()
+	}
+	p.batcher.Add(1)
+	go p.batch()
+	return p, nil
+}
+
+// Submit gives the invoke of fcn to the pipeline, it blocks while the
+// endorsers are busy.
+func (p *Pipeline) Submit(ctx context.Context, fcn string, args ...[]byte) {
+	p.submit(ctx, &Submission{Fcn: fcn}, args)
+}
+
+func (p *Pipeline) submit(ctx context.Context, s *Submission, args [][]byte) {
+	s.Submitted = time.Now()
+	p.requests <- &pipelineTx{ctx: ctx, args: args, Submission: s}
+}
+
+// Transfer submits a transfer, see Client.Transfer.
+func (p *Pipeline) Transfer(ctx context.Context, from, to, asset string, amount int64) error {
+	payload, err := p.client.transferPayload(from, to, asset, amount)
+	if err != nil {
+		return err
+	}
+	p.submit(ctx, &Submission{Fcn: "transfer", Accounts: []string{from, to}}, [][]byte{payload})
+	return nil
+}
+
+// Close waits for the outcome of the transactions submitted and stops the
+// pipeline.
+func (p *Pipeline) Close() {
+	close(p.requests)
+	p.endorsers.Wait()
+	close(p.endorsed)
+	p.batcher.Wait()
+	p.commits.Wait()
+}
+
+func (p *Pipeline) endorse() {
+	defer p.endorsers.Done()
+	for tx := range p.requests {
+		response, err := p.client.channel.InvokeHandler(invoke.NewQueryHandler(),
+			p.client.request(tx.Fcn, tx.args), p.client.requestOptions(tx.ctx)...)
+		if err != nil {
+			tx.Err = errors.WithMessage(err, tx.Fcn+" endorsement failed.")
+			p.done(tx.Submission)
+			continue
+		}
+		tx.TxID, tx.Endorsed, tx.response = string(response.TransactionID), time.Now(), response
+		p.endorsed <- tx
+	}
+}
+
+// batch gathers the endorsed transactions in bursts.
+func (p *Pipeline) batch() {
+	defer p.batcher.Done()
+	var burst []*pipelineTx
+	var linger <-chan time.Time
+	for {
+		select {
+		case tx, ok := <-p.endorsed:
+			if !ok {
+				p.broadcast(burst)
+				return
+			}
+			if burst = append(burst, tx); len(burst) == 1 {
+				linger = time.After(p.opts.Linger)
+			}
+			if len(burst) < p.opts.Burst {
+				continue
+			}
+		case <-linger:
+		}
+		p.broadcast(burst)
+		burst, linger = nil, nil
+	}
+}
+
+// broadcast sends a burst through the handler chain of the channel client,
+// which provides the transactor and the event service.
+func (p *Pipeline) broadcast(burst []*pipelineTx) {
+	if len(burst) == 0 {
+		return
+	}
+	for range burst {
+		p.window <- struct{}{}
+	}
+	h := &broadcastHandler{p, burst, 0}
+	// a retry would send the burst again
+	_, err := p.client.channel.InvokeHandler(h, p.client.request("broadcast", nil), channel.WithRetry(retry.Opts{}))
+	if err != nil && atomic.LoadInt32(&h.started) == 0 {
+		for _, tx := range burst {
+			p.finish(tx, errors.WithMessage(err, "broadcast failed."))
+		}
+	}
+}
+
+// finish reports a transaction that took room in the window.
+func (p *Pipeline) finish(tx *pipelineTx, err error) {
+	if err != nil && tx.Err == nil {
+		tx.Err = err
+	}
+	<-p.window
+	p.done(tx.Submission)
+}
+
+// broadcastHandler sends the transactions of a burst concurrently and
+// registers for their status events, the handler ends once they are sent.
+type broadcastHandler struct {
+	*Pipeline
+	burst   []*pipelineTx
+	started int32
+}
+
+func (h *broadcastHandler) Handle(requestContext *invoke.RequestContext, clientContext *invoke.ClientContext) {
+	atomic.StoreInt32(&h.started, 1)
+	var w sync.WaitGroup
+	for _, tx := range h.burst {
+		w.Add(1)
+		go func(tx *pipelineTx) {
+			defer w.Done()
+			h.send(tx, clientContext)
+		}(tx)
+	}
+	w.Wait()
+}
+
+func (p *Pipeline) send(tx *pipelineTx, clientContext *invoke.ClientContext) {
+	events := clientContext.EventService
+	registration, statusNotifier, err := events.RegisterTxStatusEvent(tx.TxID)
+	if err != nil {
+		p.finish(tx, errors.Wrap(err, "error registering for TxStatus event"))
+		return
+	}
+	envelope, err := clientContext.Transactor.CreateTransaction(fab.TransactionRequest{
+		Proposal:          tx.response.Proposal,
+		ProposalResponses: tx.response.Responses,
+	})
+	if err == nil {
+		tx.Broadcast = time.Now()
+		if p.client.submissions != nil {
+			p.client.submissions(tx.TxID, tx.Broadcast)
+		}
+		_, err = clientContext.Transactor.SendTransaction(envelope)
+	}
+	if err != nil {
+		events.Unregister(registration)
+		p.finish(tx, errors.WithMessage(err, "broadcast failed."))
+		return
+	}
+
+	p.commits.Add(1)
+	go func() {
+		defer p.commits.Done()
+		defer events.Unregister(registration)
+		select {
+		case txStatus := <-statusNotifier:
+			tx.Committed, tx.BlockNumber, tx.Code = time.Now(), txStatus.BlockNumber, txStatus.TxValidationCode.String()
+			if txStatus.TxValidationCode != pb.TxValidationCode_VALID {
+				err = status.New(status.EventServerStatus, int32(txStatus.TxValidationCode), "received invalid transaction", nil)
+			}
+		case <-tx.ctx.Done():
+			err = errors.WithMessage(tx.ctx.Err(), "wait for commit failed.")
+		case <-time.After(pipelineCommitTimeout):
+			err = errors.Errorf("no commit event for transaction %s in %v", tx.TxID, pipelineCommitTimeout)
+		}
+		p.finish(tx, err)
+	}()
+}
@@ -0,0 +1,96 @@
+package paymentclient
+
+import (
+	"context"
+	"time"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/retry"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/status"
+	pb "github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/peer"
+	"github.com/pkg/errors"
+)
+
+// CommitRetry is the retry policy of the transactions invalidated at commit
+// with one of Codes: the invoke is endorsed again, reading the state anew,
+// after a backoff growing from InitialBackoff by BackoffFactor up to
+// MaxBackoff, at most Attempts times. Only validation codes are retried: an
+// invalid transaction wrote nothing, whereas a transaction whose commit event
+// did not come may have committed.
+type CommitRetry struct {
+	Attempts       int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	BackoffFactor  float64
+	Codes          []pb.TxValidationCode
+}
+
+// DefaultCommitRetry retries the read conflicts with the backoff of the SDK.
+var DefaultCommitRetry = CommitRetry{
+	Attempts:       retry.DefaultAttempts,
+	InitialBackoff: retry.DefaultInitialBackoff,
+	MaxBackoff:     retry.DefaultMaxBackoff,
+	BackoffFactor:  retry.DefaultBackoffFactor,
+	Codes:          []pb.TxValidationCode{pb.TxValidationCode_MVCC_READ_CONFLICT, pb.TxValidationCode_PHANTOM_READ_CONFLICT},
+}
+
+// WithCommitRetry sets the retries of the invalidated transactions,
+// DefaultCommitRetry by default. Every attempt is reported to WithTimings.
+func WithCommitRetry(policy CommitRetry) Option {
+	return func(o *options) error {
+		if policy.Attempts < 0 || (policy.Attempts > 0 && (policy.InitialBackoff < 0 || policy.BackoffFactor < 1)) {
+			return errors.Errorf("commit retry needs positive attempts, backoff and a backoff factor of at least 1, got %d, %v and %v",
+				policy.Attempts, policy.InitialBackoff, policy.BackoffFactor)
+		}
+		o.commitRetry = policy
+		return nil
+	}
+}
+
+// withoutValidationCodes removes the validation codes from the retryable
+// codes of the SDK: the SDK would retry them silently, DUPLICATE_TXID
+// included, which may follow the commit of a copy of the transaction.
+func withoutValidationCodes(opts retry.Opts) retry.Opts {
+	codes := opts.RetryableCodes
+	if len(codes) == 0 {
+		// the codes the SDK retries when none are given
+		codes = retry.DefaultRetryableCodes
+	}
+	opts.RetryableCodes = map[status.Group][]status.Code{}
+	for group, c := range codes {
+		if group != status.EventServerStatus {
+			opts.RetryableCodes[group] = c
+		}
+	}
+	return opts
+}
+
+// retryable returns the validation code of err if the policy retries it.
+func (p *CommitRetry) retryable(err error) (pb.TxValidationCode, bool) {
+	s, ok := status.FromError(err)
+	if !ok || s.Group != status.EventServerStatus {
+		return 0, false
+	}
+	for _, code := range p.Codes {
+		if s.Code == int32(code) {
+			return code, true
+		}
+	}
+	return 0, false
+}
+
+// backoff waits before the retry following attempt, unless ctx is done.
+func (p *CommitRetry) backoff(ctx context.Context, attempt int) error {
+	backoff := p.InitialBackoff
+	for i := 1; i < attempt && backoff < p.MaxBackoff; i++ {
+		backoff = time.Duration(float64(backoff) * p.BackoffFactor)
+	}
+	if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+	select {
+	case <-time.After(backoff):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,85 @@
+package paymentclient
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/schema"
+	"github.com/pkg/errors"
+)
+
+// Dispute marks the transfer made by the transaction txID as disputed.
+func (c *Client) Dispute(ctx context.Context, txID, reason string) (*schema.TransferRecord, error) {
+	args := [][]byte{[]byte(txID)}
+	if reason != "" {
+		args = append(args, []byte(reason))
+	}
+	return c.executeRecord(ctx, "dispute", args)
+}
+
+// ApproveReversal reverses the disputed transfer txID, the client user must
+// belong to an admin MSP. The record is indebted if the receiver could not
+// pay the amount back.
+func (c *Client) ApproveReversal(ctx context.Context, txID string) (*schema.TransferRecord, error) {
+	return c.executeRecord(ctx, "approveReversal", [][]byte{[]byte(txID)})
+}
+
+// RepayDebt pays back amount of the debt of the indebted transfer txID, all
+// that is left if amount is 0. The client user must belong to the org of the
+// debtor or an admin MSP.
+func (c *Client) RepayDebt(ctx context.Context, txID string, amount int64) (*schema.Debt, error) {
+	args := [][]byte{[]byte(txID)}
+	if amount != 0 {
+		args = append(args, []byte(strconv.FormatInt(amount, 10)))
+	}
+	response, _, err := c.execute(ctx, "repayDebt", args...)
+	if err != nil {
+		return nil, errors.WithMessage(err, "repayDebt failed.")
+	}
+
+	var debt schema.Debt
+	if err := debt.FromBytes(response); err != nil {
+		return nil, err
+	}
+	return &debt, nil
+}
+
+func (c *Client) executeRecord(ctx context.Context, fcn string, args [][]byte) (*schema.TransferRecord, error) {
+	response, _, err := c.execute(ctx, fcn, args...)
+	if err != nil {
+		return nil, errors.WithMessage(err, fcn+" failed.")
+	}
+
+	var record schema.TransferRecord
+	if err := record.FromBytes(response); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// GetHistory returns the disputes and reversals involving account.
+func (c *Client) GetHistory(ctx context.Context, account string) ([]schema.HistoryEntry, error) {
+	var history []schema.HistoryEntry
+	if err := c.queryJSON(ctx, "history", account, &history); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// GetDebts returns the open debts of account.
+func (c *Client) GetDebts(ctx context.Context, account string) ([]schema.Debt, error) {
+	var debts []schema.Debt
+	if err := c.queryJSON(ctx, "debts", account, &debts); err != nil {
+		return nil, err
+	}
+	return debts, nil
+}
+
+func (c *Client) queryJSON(ctx context.Context, fcn, account string, v interface{}) error {
+	response, err := c.query(ctx, fcn, []byte(account))
+	if err != nil {
+		return errors.WithMessage(err, fcn+" of account "+account+" failed.")
+	}
+	return errors.Wrapf(json.Unmarshal(response, v), "malformed %s", fcn)
+}
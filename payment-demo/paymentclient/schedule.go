@@ -0,0 +1,63 @@
+package paymentclient
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/schema"
+	"github.com/pkg/errors"
+)
+
+// SchedulePayment registers a standing order and returns its id.
+func (c *Client) SchedulePayment(ctx context.Context, schedule *schema.Schedule) (string, error) {
+	if err := schedule.Validate(); err != nil {
+		return "", errors.WithMessage(err, "SchedulePayment failed (invalid schedule).")
+	}
+	d, err := schedule.ToBytes()
+	if err != nil {
+		return "", errors.WithMessage(err, "SchedulePayment failed (marshall schedule).")
+	}
+
+	response, _, err := c.execute(ctx, "schedulePayment", d)
+	if err != nil {
+		return "", errors.WithMessage(err, fmt.Sprintf("schedule payment from %s to %s failed.", schedule.From, schedule.To))
+	}
+	return string(response), nil
+}
+
+// CancelSchedule stops a scheduled payment.
+func (c *Client) CancelSchedule(ctx context.Context, id string) (*Tx, error) {
+	_, tx, err := c.execute(ctx, "cancelSchedule", []byte(id))
+	if err != nil {
+		return nil, errors.WithMessage(err, fmt.Sprintf("cancel scheduled payment %s failed.", id))
+	}
+	return tx, nil
+}
+
+// GetScheduledPayment returns the state of a scheduled payment.
+func (c *Client) GetScheduledPayment(ctx context.Context, id string) (*schema.ScheduledPayment, error) {
+	response, err := c.query(ctx, "scheduledPayment", []byte(id))
+	if err != nil {
+		return nil, errors.WithMessage(err, fmt.Sprintf("get scheduled payment %s failed.", id))
+	}
+	var payment schema.ScheduledPayment
+	if err := payment.FromBytes(response); err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// Tick executes at most limit due payments, the client user must belong to
+// an admin or ticker MSP.
+func (c *Client) Tick(ctx context.Context, limit int) (*schema.TickResult, error) {
+	response, _, err := c.execute(ctx, "tick", []byte(strconv.Itoa(limit)))
+	if err != nil {
+		return nil, errors.WithMessage(err, "tick failed.")
+	}
+	var result schema.TickResult
+	if err := result.FromBytes(response); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -0,0 +1,46 @@
+package paymentclient
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/schema"
+	"github.com/pkg/errors"
+)
+
+// GetPositions returns the open net positions between the orgs, of every
+// org pair or of the pair org1, org2 when they are given, as the settlement
+// of the window ending now.
+func (c *Client) GetPositions(ctx context.Context, org1, org2 string) (*schema.Settlement, error) {
+	var args [][]byte
+	if org1 != "" || org2 != "" {
+		args = [][]byte{[]byte(org1), []byte(org2)}
+	}
+	response, err := c.query(ctx, "positions", args...)
+	if err != nil {
+		return nil, errors.WithMessage(err, "get open positions failed.")
+	}
+
+	var open schema.Settlement
+	if err := open.FromBytes(response); err != nil {
+		return nil, err
+	}
+	return &open, nil
+}
+
+// Settle nets and resets the positions of the window ending at to, at most
+// limit position records, the client user must belong to an admin or
+// operator MSP. The settlement has More set if settle must be run again.
+func (c *Client) Settle(ctx context.Context, to time.Time, limit int) (*schema.Settlement, error) {
+	response, _, err := c.execute(ctx, "settle", []byte(strconv.FormatInt(to.Unix(), 10)), []byte(strconv.Itoa(limit)))
+	if err != nil {
+		return nil, errors.WithMessage(err, "settle failed.")
+	}
+
+	var settlement schema.Settlement
+	if err := settlement.FromBytes(response); err != nil {
+		return nil, err
+	}
+	return &settlement, nil
+}
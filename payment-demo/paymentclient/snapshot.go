@@ -0,0 +1,128 @@
+package paymentclient
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/merkle"
+	"github.com/GingerMoon/fabric_demo/payment-common/schema"
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
+	"github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/common"
+	"github.com/pkg/errors"
+)
+
+// snapshotAttempts is the number of tries of a snapshot page, a page
+// conflicts with the transfers committed meanwhile on the same accounts.
+const snapshotAttempts = 3
+
+// LedgerHeight returns the height of the ledger info of the channel, the
+// number of blocks, as answered by the query system chaincode to QueryInfo.
+func (c *Client) LedgerHeight(ctx context.Context) (uint64, error) {
+	response, err := c.channel.Query(channel.Request{ChaincodeID: "qscc", Fcn: "GetChainInfo", Args: [][]byte{[]byte(c.channelID)}},
+		c.requestOptions(ctx)...)
+	if err != nil {
+		return 0, errors.WithMessage(err, "query ledger info failed.")
+	}
+	var info common.BlockchainInfo
+	if err := proto.Unmarshal(response.Payload, &info); err != nil {
+		return 0, errors.Wrap(err, "malformed ledger info")
+	}
+	return info.Height, nil
+}
+
+// StartSnapshot starts a snapshot of the balances committed before its
+// transaction, at the ledger height, and returns the bookmark of its first
+// page. The client user must belong to an admin MSP.
+func (c *Client) StartSnapshot(ctx context.Context, height uint64) (*schema.SnapshotResult, *Tx, error) {
+	response, tx, err := c.execute(ctx, "snapshot", []byte(strconv.FormatUint(height, 10)))
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "start snapshot failed.")
+	}
+	var result schema.SnapshotResult
+	if err := result.FromBytes(response); err != nil {
+		return nil, nil, err
+	}
+	return &result, tx, nil
+}
+
+// SnapshotPage adds the page of up to page accounts after bookmark to the
+// snapshot in progress.
+func (c *Client) SnapshotPage(ctx context.Context, bookmark string, page int) (*schema.SnapshotResult, error) {
+	response, _, err := c.execute(ctx, "snapshot", []byte(bookmark), []byte(strconv.Itoa(page)))
+	if err != nil {
+		return nil, errors.WithMessage(err, "snapshot failed.")
+	}
+
+	var result schema.SnapshotResult
+	if err := result.FromBytes(response); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// TakeSnapshot takes a snapshot of the balances at the current ledger height
+// page by page and returns it once complete.
+func (c *Client) TakeSnapshot(ctx context.Context, page int) (*schema.Snapshot, error) {
+	height, err := c.LedgerHeight(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result, tx, err := c.StartSnapshot(ctx, height)
+	if err != nil {
+		return nil, err
+	}
+	logger.Infof("snapshot %s started at height %d in transaction %s", result.ID, height, tx.TxID)
+
+	for !result.Done {
+		bookmark := result.Bookmark
+		for attempt := 1; ; attempt++ {
+			if result, err = c.SnapshotPage(ctx, bookmark, page); err == nil {
+				break
+			}
+			if attempt == snapshotAttempts || ctx.Err() != nil {
+				return nil, errors.WithMessage(err, "bookmark "+strconv.Quote(bookmark))
+			}
+			logger.Infof("snapshot page failed, retrying. %s", err)
+		}
+		logger.Infof("snapshot %s: %d accounts", result.ID, result.Accounts)
+	}
+	return c.GetSnapshot(ctx, result.ID)
+}
+
+// GetSnapshot returns the snapshot id, the latest completed one if id is empty.
+func (c *Client) GetSnapshot(ctx context.Context, id string) (*schema.Snapshot, error) {
+	var args [][]byte
+	if id != "" {
+		args = [][]byte{[]byte(id)}
+	}
+	response, err := c.query(ctx, "snapshotInfo", args...)
+	if err != nil {
+		return nil, errors.WithMessage(err, "get snapshot failed.")
+	}
+
+	var snapshot schema.Snapshot
+	if err := snapshot.FromBytes(response); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// GetProof returns the proof of the balance of account in the snapshot id,
+// the latest completed one if id is empty. The proof is not verified.
+func (c *Client) GetProof(ctx context.Context, account, id string) (*merkle.Proof, error) {
+	args := [][]byte{[]byte(account)}
+	if id != "" {
+		args = append(args, []byte(id))
+	}
+	response, err := c.query(ctx, "proof", args...)
+	if err != nil {
+		return nil, errors.WithMessage(err, "get proof failed.")
+	}
+
+	var proof merkle.Proof
+	if err := proof.FromBytes(response); err != nil {
+		return nil, err
+	}
+	return &proof, nil
+}
@@ -0,0 +1,81 @@
+package paymentclient
+
+import (
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel/invoke"
+)
+
+// Timing is the duration of the phases of one request: the proposal and its
+// endorsement, the broadcast of the transaction to the orderer and the wait
+// for its commit event. Queries are only endorsed. The phases are the ones of
+// the last attempt of the SDK, Total includes its retries.
+//
+// An invoke reports each transaction it commits, Attempt counts them from 1,
+// and Retried is set on the ones invalidated and retried by WithCommitRetry.
+// Attempt is 0 for queries.
+type Timing struct {
+	Fcn       string
+	Endorse   time.Duration
+	Broadcast time.Duration
+	Commit    time.Duration
+	Total     time.Duration
+	Attempt   int
+	Retried   bool
+	Err       error
+}
+
+// WithTimings calls record with the timing of every request once it
+// returns. record is called concurrently by the goroutines using the client.
+func WithTimings(record func(*Timing)) Option {
+	return func(o *options) error {
+		o.timings = record
+		return nil
+	}
+}
+
+// phases are the times the handler chain reached the end of each phase. The
+// chain keeps running in its own goroutine when the request is cancelled,
+// hence the lock.
+type phases struct {
+	sync.Mutex
+	start, endorsed, broadcast, committed time.Time
+	blockNumber                           uint64
+}
+
+func (p *phases) mark(t *time.Time) {
+	p.Lock()
+	*t = time.Now()
+	p.Unlock()
+}
+
+// timing returns the durations of the phases reached.
+func (p *phases) timing(fcn string, total time.Duration, err error) *Timing {
+	p.Lock()
+	defer p.Unlock()
+	t := &Timing{Fcn: fcn, Total: total, Err: err}
+	since := func(from, to time.Time) time.Duration {
+		if from.IsZero() || to.IsZero() {
+			return 0
+		}
+		return to.Sub(from)
+	}
+	t.Endorse = since(p.start, p.endorsed)
+	t.Broadcast = since(p.endorsed, p.broadcast)
+	t.Commit = since(p.broadcast, p.committed)
+	return t
+}
+
+// startHandler starts every attempt of the handler chain.
+type startHandler struct {
+	*phases
+	next invoke.Handler
+}
+
+func (h *startHandler) Handle(requestContext *invoke.RequestContext, clientContext *invoke.ClientContext) {
+	h.Lock()
+	h.start, h.endorsed, h.broadcast, h.committed = time.Now(), time.Time{}, time.Time{}, time.Time{}
+	h.Unlock()
+	h.next.Handle(requestContext, clientContext)
+}
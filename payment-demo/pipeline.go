@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/GingerMoon/fabric_demo/payment-demo/paymentclient"
+)
+
+// PipelineOptions is the scenario of the pipeline command: Transfers
+// transfers of Amount between pairs of the existing accounts "0" to
+// "Accounts-1" drawn by Selection, given to a pipeline per client as fast as
+// they endorse them. The scheduler, if any, holds back the conflicting
+// transfers until the commit of the ones they conflict with.
+type PipelineOptions struct {
+	Accounts  int
+	Transfers int
+	Amount    int64
+	Selection AccountSelection
+	paymentclient.PipelineOptions
+	scheduler *conflictScheduler
+}
+
+// PipelineResult is the outcome of a pipeline run. Committed counts the
+// valid transactions, Invalid the others by validation code and Failures the
+// ones never endorsed, broadcast or seen in a block.
+type PipelineResult struct {
+	Clients   int            `json:"clients"`
+	Selection string         `json:"selection"`
+	Transfers int            `json:"transfers"`
+	Endorsed  int            `json:"endorsed"`
+	Committed int            `json:"committed"`
+	Invalid   map[string]int `json:"invalid,omitempty"`
+	Failures  int            `json:"failures"`
+	ElapsedMS int64          `json:"elapsedMs"`
+	PerSecond float64        `json:"perSecond"`
+	// Latency is set when the phases are recorded.
+	Latency   []LatencyStats `json:"latency,omitempty"`
+	Commits   *CommitReport  `json:"commits,omitempty"`
+	Conflicts *ConflictStats `json:"conflicts,omitempty"`
+}
+
+// RunPipeline runs the scenario of opts and records the phases of the
+// transfers with latency, if not nil.
+func RunPipeline(ctx context.Context, clients []*paymentclient.Client, opts PipelineOptions, latency *LatencyRecorder) (*PipelineResult, error) {
+	pickers, err := opts.Selection.pickers(opts.Accounts, len(clients))
+	if err != nil {
+		return nil, err
+	}
+	result := &PipelineResult{Clients: len(clients), Selection: opts.Selection.String(), Transfers: opts.Transfers,
+		Invalid: map[string]int{}}
+	var lock sync.Mutex
+	done := func(s *paymentclient.Submission) {
+		if s.Accounts != nil {
+			opts.scheduler.release(s.Accounts[0], s.Accounts[1])
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		if !s.Endorsed.IsZero() {
+			result.Endorsed++
+		}
+		switch {
+		case s.Code == validCode:
+			result.Committed++
+		case s.Code != "":
+			result.Invalid[s.Code]++
+		default:
+			result.Failures++
+		}
+		if s.Err != nil {
+			logger.Debugf("%s", s.Err)
+		}
+		if latency == nil {
+			return
+		}
+		if s.Code != validCode {
+			latency.Record(&paymentclient.Timing{Fcn: s.Fcn, Err: s.Err})
+			return
+		}
+		latency.RecordDuration(s.Fcn, "endorse", s.Endorsed.Sub(s.Submitted))
+		latency.RecordDuration(s.Fcn, "batch", s.Broadcast.Sub(s.Endorsed))
+		latency.RecordDuration(s.Fcn, "commit", s.Committed.Sub(s.Broadcast))
+		latency.RecordDuration(s.Fcn, "total", s.Committed.Sub(s.Submitted))
+	}
+
+	pipelines := make([]*paymentclient.Pipeline, len(clients))
+	for i, c := range clients {
+		p, err := c.NewPipeline(opts.PipelineOptions, done)
+		if err != nil {
+			for _, p := range pipelines[:i] {
+				p.Close()
+			}
+			return nil, err
+		}
+		pipelines[i] = p
+	}
+
+	start := time.Now()
+	var w sync.WaitGroup
+	for i := range pipelines {
+		w.Add(1)
+		go func(pp int) {
+			defer w.Done()
+			defer pipelines[pp].Close()
+			for i := pp; i < opts.Transfers && ctx.Err() == nil; i += len(pipelines) {
+				a, b := pickers[pp]()
+				from, to, err := opts.scheduler.take(ctx, strconv.Itoa(a), strconv.Itoa(b))
+				if err != nil {
+					break
+				}
+				if err := pipelines[pp].Transfer(ctx, from, to, "", opts.Amount); err != nil {
+					opts.scheduler.release(from, to)
+					logger.Errorf("%s", err)
+				}
+			}
+		}(i)
+	}
+	w.Wait()
+
+	elapsed := time.Since(start)
+	result.ElapsedMS = int64(elapsed / time.Millisecond)
+	if elapsed > 0 {
+		result.PerSecond = float64(result.Committed) / elapsed.Seconds()
+	}
+	result.Conflicts = opts.scheduler.report()
+	if len(result.Invalid) == 0 {
+		result.Invalid = nil
+	}
+	return result, ctx.Err()
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/schema"
+	"github.com/GingerMoon/fabric_demo/payment-demo/paymentclient"
+	"github.com/pkg/errors"
+)
+
+// ValueProfile gives the padding size of every account created by populate.
+// Sizes only depend on the account index so a resumed run plans the same ledger.
+type ValueProfile struct {
+	Min, Max int
+}
+
+// ParseValueProfile parses "fixed:N" or "uniform:MIN-MAX" (sizes in bytes, K/M suffixes allowed).
+func ParseValueProfile(s string) (ValueProfile, error) {
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 {
+		return ValueProfile{}, errors.Errorf("malformed value profile %q, expecting fixed:N or uniform:MIN-MAX", s)
+	}
+	switch parts[0] {
+	case "fixed":
+		n, err := parseBytes(parts[1])
+		if err != nil {
+			return ValueProfile{}, err
+		}
+		return ValueProfile{int(n), int(n)}, nil
+	case "uniform":
+		bounds := strings.SplitN(parts[1], "-", 2)
+		if len(bounds) != 2 {
+			return ValueProfile{}, errors.Errorf("malformed uniform profile %q, expecting uniform:MIN-MAX", s)
+		}
+		min, err := parseBytes(bounds[0])
+		if err != nil {
+			return ValueProfile{}, err
+		}
+		max, err := parseBytes(bounds[1])
+		if err != nil {
+			return ValueProfile{}, err
+		}
+		if min > max {
+			return ValueProfile{}, errors.Errorf("uniform profile %q: min is greater than max", s)
+		}
+		return ValueProfile{int(min), int(max)}, nil
+	default:
+		return ValueProfile{}, errors.Errorf("unknown value profile %q", parts[0])
+	}
+}
+
+// Size returns the padding size of account index.
+func (p ValueProfile) Size(index int64) int {
+	if p.Min == p.Max {
+		return p.Min
+	}
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(index))
+	sum := sha256.Sum256(b[:])
+	return p.Min + int(binary.BigEndian.Uint64(sum[:8])%uint64(p.Max-p.Min+1))
+}
+
+// parseBytes parses a byte count with an optional K, M or G suffix.
+func parseBytes(s string) (int64, error) {
+	mult := int64(1)
+	switch {
+	case strings.HasSuffix(s, "K"):
+		mult, s = 1<<10, strings.TrimSuffix(s, "K")
+	case strings.HasSuffix(s, "M"):
+		mult, s = 1<<20, strings.TrimSuffix(s, "M")
+	case strings.HasSuffix(s, "G"):
+		mult, s = 1<<30, strings.TrimSuffix(s, "G")
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, errors.Errorf("malformed size %q", s)
+	}
+	return n * mult, nil
+}
+
+// PopulateOptions configures Populate. At least one of Accounts and Bytes is
+// the target, populate stops at whichever is reached first.
+type PopulateOptions struct {
+	First    int64
+	Accounts int64
+	Bytes    int64
+	Amount   int64
+	Batch    int
+	Profile  ValueProfile
+	Encoding schema.Encoding
+	Progress string
+	Resume   bool
+}
+
+// populateProgress is saved after every batch that extends the contiguous
+// range of created accounts, so that a resumed run starts at Next.
+type populateProgress struct {
+	First int64 `json:"first"`
+	Next  int64 `json:"next"`
+	Bytes int64 `json:"bytes"`
+}
+
+func (p *populateProgress) save(path string) error {
+	d, err := json.Marshal(p)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, d, 0644); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(os.Rename(tmp, path))
+}
+
+func loadProgress(path string) (*populateProgress, error) {
+	d, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var p populateProgress
+	if err := json.Unmarshal(d, &p); err != nil {
+		return nil, errors.Wrapf(err, "malformed progress file %s", path)
+	}
+	return &p, nil
+}
+
+type populateBatch struct {
+	first int64
+	sizes []int
+	bytes int64
+}
+
+// Populate grows the ledger with createBatch transactions, one goroutine per
+// client, until the account or size target is reached. It logs progress,
+// throughput and the estimated time remaining every interval.
+func Populate(ctx context.Context, clients []*paymentclient.Client, opts PopulateOptions, interval time.Duration) error {
+	if opts.Accounts <= 0 && opts.Bytes <= 0 {
+		return errors.New("populate needs a target account count or ledger size")
+	}
+	if opts.Batch < 1 || opts.Batch > schema.MaxBatchAccounts {
+		return errors.Errorf("batch size %d out of range [1, %d]", opts.Batch, schema.MaxBatchAccounts)
+	}
+	if opts.Profile.Max > schema.MaxBatchPadding {
+		return errors.Errorf("value size %d exceeds the batch limit %d", opts.Profile.Max, schema.MaxBatchPadding)
+	}
+
+	progress := &populateProgress{First: opts.First, Next: opts.First}
+	if opts.Resume {
+		saved, err := loadProgress(opts.Progress)
+		if err != nil {
+			return err
+		}
+		if saved.First != opts.First {
+			return errors.Errorf("progress file %s starts at account %d, not %d", opts.Progress, saved.First, opts.First)
+		}
+		progress = saved
+		logger.Infof("resuming at account %d, %d bytes already written", progress.Next, progress.Bytes)
+	}
+
+	// the state size of an account only depends on its padding size
+	stateSizes := map[int]int64{}
+	stateSize := func(key int64, padding int) int64 {
+		n, ok := stateSizes[padding]
+		if !ok {
+			account := schema.Account{Balance: opts.Amount, Padding: make([]byte, padding)}
+			d, _ := account.Encode(opts.Encoding)
+			n = int64(len(d))
+			stateSizes[padding] = n
+		}
+		return n + int64(len(strconv.FormatInt(key, 10)))
+	}
+
+	done := func(next, bytes int64) bool {
+		return (opts.Accounts > 0 && next >= opts.First+opts.Accounts) || (opts.Bytes > 0 && bytes >= opts.Bytes)
+	}
+	remaining := func(next, bytes int64) float64 {
+		var r float64
+		if opts.Accounts > 0 {
+			r = float64(opts.First+opts.Accounts-next) / float64(opts.Accounts)
+		}
+		if opts.Bytes > 0 {
+			if rb := float64(opts.Bytes-bytes) / float64(opts.Bytes); opts.Accounts <= 0 || rb < r {
+				r = rb
+			}
+		}
+		return r
+	}
+
+	batches := make(chan *populateBatch)
+	go func() {
+		defer close(batches)
+		next, bytes := progress.Next, progress.Bytes
+		for !done(next, bytes) {
+			b := &populateBatch{first: next}
+			padded := 0
+			for len(b.sizes) < opts.Batch && !done(next, bytes) {
+				size := opts.Profile.Size(next)
+				if padded+size > schema.MaxBatchPadding {
+					break
+				}
+				padded += size
+				b.sizes = append(b.sizes, size)
+				b.bytes += stateSize(next, size)
+				bytes += stateSize(next, size)
+				next++
+			}
+			batches <- b
+		}
+	}()
+
+	results := make(chan *populateBatch)
+	var failed int
+	var w sync.WaitGroup
+	for c := range clients {
+		w.Add(1)
+		go func(cc int) {
+			defer w.Done()
+			for b := range batches {
+				batch := schema.Batch{First: b.first, Amount: opts.Amount, Paddings: b.sizes, Encoding: opts.Encoding.String()}
+				tx, err := clients[cc].CreateBatch(ctx, &batch)
+				if err != nil {
+					logger.Errorf("%s", err)
+					b.sizes = nil
+				} else {
+					logger.Debugf("createBatch(%s) succeeded. accounts %d-%d", tx.TxID, b.first, b.first+int64(len(b.sizes))-1)
+				}
+				results <- b
+			}
+		}(c)
+	}
+	go func() {
+		w.Wait()
+		close(results)
+	}()
+
+	// completed batches beyond a gap wait here until the gap is filled
+	pending := map[int64]*populateBatch{}
+	var accounts, bytes int64
+	start, last := time.Now(), time.Now()
+	startRemaining := remaining(progress.Next, progress.Bytes)
+	for b := range results {
+		if b.sizes == nil {
+			failed++
+			continue
+		}
+		accounts += int64(len(b.sizes))
+		bytes += b.bytes
+		pending[b.first] = b
+
+		advanced := false
+		for p, ok := pending[progress.Next]; ok; p, ok = pending[progress.Next] {
+			delete(pending, p.first)
+			progress.Next += int64(len(p.sizes))
+			progress.Bytes += p.bytes
+			advanced = true
+		}
+		if advanced {
+			if err := progress.save(opts.Progress); err != nil {
+				logger.Errorf("save progress to %s failed: %s", opts.Progress, err)
+			}
+		}
+
+		if time.Since(last) >= interval {
+			last = time.Now()
+			elapsed := time.Since(start).Seconds()
+			eta := "unknown"
+			if left := remaining(progress.Next, progress.Bytes); startRemaining > left {
+				eta = (time.Duration(elapsed*left/(startRemaining-left)) * time.Second).String()
+			}
+			logger.Infof("populate: next account %d, %d accounts and %.1f MB this run, %.0f accounts/s, %.2f MB/s, ETA %s",
+				progress.Next, accounts, float64(bytes)/(1<<20), float64(accounts)/elapsed, float64(bytes)/(1<<20)/elapsed, eta)
+		}
+	}
+
+	elapsed := time.Since(start)
+	logger.Infof("populate: %d accounts, %.1f MB in %v, ledger state now %.1f MB up to account %d",
+		accounts, float64(bytes)/(1<<20), elapsed, float64(progress.Bytes)/(1<<20), progress.Next)
+	if failed != 0 {
+		return errors.Errorf("%d batches failed, run populate with -resume to continue from account %d", failed, progress.Next)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/GingerMoon/fabric_demo/payment-demo/paymentclient"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/status"
+	pb "github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/peer"
+)
+
+// RetryStats separates the invokes of an operation committed by their first
+// transaction from the ones committed after retrying invalidated ones.
+// Retries counts the transactions retried by validation code, GaveUp the
+// invokes still invalid once their retries were exhausted.
+type RetryStats struct {
+	Operation string         `json:"operation"`
+	FirstTry  int            `json:"firstTry"`
+	Eventual  int            `json:"eventual"`
+	GaveUp    int            `json:"gaveUp"`
+	Retries   map[string]int `json:"retries,omitempty"`
+}
+
+// record counts the outcome of one attempt of an invoke.
+func (r *RetryStats) record(t *paymentclient.Timing) {
+	switch {
+	case t.Retried:
+		code := "unknown"
+		if s, ok := status.FromError(t.Err); ok {
+			code = pb.TxValidationCode(s.Code).String()
+		}
+		if r.Retries == nil {
+			r.Retries = map[string]int{}
+		}
+		r.Retries[code]++
+	case t.Err == nil && t.Attempt == 1:
+		r.FirstTry++
+	case t.Err == nil:
+		r.Eventual++
+	case t.Attempt > 1:
+		r.GaveUp++
+	}
+}
+
+// Retries returns the retry stats of every operation invoked, sorted by
+// operation.
+func (l *LatencyRecorder) Retries() []RetryStats {
+	l.Lock()
+	defer l.Unlock()
+	var stats []RetryStats
+	for _, r := range l.retries {
+		s := *r
+		if r.Retries != nil {
+			s.Retries = map[string]int{}
+			for code, n := range r.Retries {
+				s.Retries[code] = n
+			}
+		}
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Operation < stats[j].Operation
+	})
+	return stats
+}
+
+// printRetries prints the retry stats after the latency table, if any.
+func printRetries(out *printer, stats []RetryStats) error {
+	if len(stats) == 0 {
+		return nil
+	}
+	rows := make([][]string, len(stats))
+	for i, s := range stats {
+		var codes []string
+		for code, n := range s.Retries {
+			codes = append(codes, code+"="+strconv.Itoa(n))
+		}
+		sort.Strings(codes)
+		rows[i] = []string{s.Operation, strconv.Itoa(s.FirstTry), strconv.Itoa(s.Eventual), strconv.Itoa(s.GaveUp),
+			strings.Join(codes, " ")}
+	}
+	return out.table([]string{"operation", "first_try", "eventual", "gave_up", "retries"}, rows)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/GingerMoon/fabric_demo/payment-demo/paymentclient"
+)
+
+// RunScheduler invokes tick every interval until ctx is done, ticking again
+// right away while more payments are due.
+func RunScheduler(ctx context.Context, c *paymentclient.Client, interval time.Duration, limit int) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	logger.Infof("scheduler: ticking every %v, at most %d payments per tick", interval, limit)
+	for {
+		for {
+			result, err := c.Tick(ctx, limit)
+			if err != nil {
+				// the next tick retries, a failed tick changes nothing
+				logger.Errorf("%s", err)
+				break
+			}
+			for _, e := range result.Executions {
+				if e.Error != "" {
+					logger.Warningf("scheduled payment %s occurrence %d failed: %s", e.ID, e.Occurrence, e.Error)
+				} else {
+					logger.Infof("scheduled payment %s occurrence %d: transfer %s, %d from %s to %s, fee %d",
+						e.ID, e.Occurrence, e.Transfer, e.Receipt.Amount, e.Receipt.From, e.Receipt.To, e.Receipt.Fee)
+				}
+			}
+			if !result.More {
+				break
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			logger.Infof("scheduler: interrupted")
+			return nil
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	mrand "math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// AccountSelection is the strategy drawing the accounts of the transfers
+// among the accounts "0" to "n-1", and the seed of the generators of the
+// workers:
+//
+//	uniform    every account alike
+//	zipf:S     the account of rank k with a probability in 1/(1+k)^S, S > 1
+//	hot:H/T    each account of a transfer among the first H% of the
+//	           accounts with a probability of T%
+//	partition  each worker uniformly in its own slice of the accounts, so
+//	           that no two workers touch the same account
+//
+// A run is reproduced by the same selection, seed and number of workers.
+type AccountSelection struct {
+	Kind string
+	Skew float64
+	// HotAccounts and HotTraffic are the percentages of a hot-set selection.
+	HotAccounts, HotTraffic float64
+	Seed                    int64
+}
+
+// accountPicker draws the sender and the receiver of a transfer, two
+// different accounts.
+type accountPicker func() (from, to int)
+
+// ParseAccountSelection parses "uniform", "zipf:S", "hot:H/T" or
+// "partition".
+func ParseAccountSelection(s string, seed int64) (AccountSelection, error) {
+	parts := strings.SplitN(s, ":", 2)
+	sel := AccountSelection{Kind: parts[0], Seed: seed}
+	arg := ""
+	if len(parts) == 2 {
+		arg = parts[1]
+	}
+	switch sel.Kind {
+	case "uniform", "partition":
+		if arg != "" {
+			return AccountSelection{}, errors.Errorf("malformed account selection %q, %s takes no parameter", s, sel.Kind)
+		}
+	case "zipf":
+		skew, err := strconv.ParseFloat(arg, 64)
+		if err != nil || skew <= 1 {
+			return AccountSelection{}, errors.Errorf("malformed zipf skew %q, expecting a number greater than 1", arg)
+		}
+		sel.Skew = skew
+	case "hot":
+		percents := strings.SplitN(arg, "/", 2)
+		if len(percents) != 2 {
+			return AccountSelection{}, errors.Errorf("malformed hot-set selection %q, expecting hot:H/T", s)
+		}
+		var err error
+		if sel.HotAccounts, err = strconv.ParseFloat(percents[0], 64); err != nil || sel.HotAccounts <= 0 || sel.HotAccounts >= 100 {
+			return AccountSelection{}, errors.Errorf("malformed hot accounts %q, expecting a percentage in (0, 100)", percents[0])
+		}
+		if sel.HotTraffic, err = strconv.ParseFloat(percents[1], 64); err != nil || sel.HotTraffic < 0 || sel.HotTraffic > 100 {
+			return AccountSelection{}, errors.Errorf("malformed hot traffic %q, expecting a percentage in [0, 100]", percents[1])
+		}
+	default:
+		return AccountSelection{}, errors.Errorf("unknown account selection %q", sel.Kind)
+	}
+	return sel, nil
+}
+
+// selectionFlags defines the -select and -seed flags of a command, the
+// returned function parses them once the flags are. A zero seed is taken from
+// the clock and logged.
+func selectionFlags(fs *commandFlags) func() (AccountSelection, error) {
+	s := fs.String("select", "uniform", "account selection: uniform, zipf:S, hot:H/T or partition")
+	seed := fs.Int64("seed", 0, "seed of the account selection, 0 for the clock")
+	return func() (AccountSelection, error) {
+		if *seed == 0 {
+			*seed = time.Now().UnixNano()
+		}
+		sel, err := ParseAccountSelection(*s, *seed)
+		if err != nil {
+			return AccountSelection{}, err
+		}
+		logger.Infof("account selection %s", sel)
+		return sel, nil
+	}
+}
+
+// String describes the selection with its seed.
+func (s AccountSelection) String() string {
+	kind := s.Kind
+	switch s.Kind {
+	case "zipf":
+		kind += ":" + strconv.FormatFloat(s.Skew, 'f', -1, 64)
+	case "hot":
+		kind += ":" + strconv.FormatFloat(s.HotAccounts, 'f', -1, 64) + "/" + strconv.FormatFloat(s.HotTraffic, 'f', -1, 64)
+	}
+	return kind + " seed " + strconv.FormatInt(s.Seed, 10)
+}
+
+// rand returns the generator of worker w, seeded from the seed of the
+// selection.
+func (s AccountSelection) rand(w int) *mrand.Rand {
+	return mrand.New(mrand.NewSource(s.Seed + int64(w)))
+}
+
+// pickers returns a picker per worker, each with its own generator: a picker
+// is not safe for concurrent use.
+func (s AccountSelection) pickers(accounts, workers int) ([]accountPicker, error) {
+	if accounts < 2 {
+		return nil, errors.Errorf("transfers need at least 2 accounts, got %d", accounts)
+	}
+	if s.Kind == "partition" && accounts < 2*workers {
+		return nil, errors.Errorf("%d accounts cannot be partitioned between %d workers, at least 2 each", accounts, workers)
+	}
+	pickers := make([]accountPicker, workers)
+	for w := range pickers {
+		r := s.rand(w)
+		var draw func() int
+		switch s.Kind {
+		case "zipf":
+			zipf := mrand.NewZipf(r, s.Skew, 1, uint64(accounts-1))
+			draw = func() int {
+				return int(zipf.Uint64())
+			}
+		case "hot":
+			hot := int(float64(accounts) * s.HotAccounts / 100)
+			if hot < 1 {
+				hot = 1
+			}
+			if (hot < 2 && s.HotTraffic == 100) || (accounts-hot < 2 && s.HotTraffic == 0) {
+				return nil, errors.Errorf("hot-set selection %s leaves a single account to draw from %d", s, accounts)
+			}
+			draw = func() int {
+				if r.Float64()*100 < s.HotTraffic {
+					return r.Intn(hot)
+				}
+				return hot + r.Intn(accounts-hot)
+			}
+		case "partition":
+			// the last worker takes the remainder
+			first, n := w*(accounts/workers), accounts/workers
+			if w == workers-1 {
+				n = accounts - first
+			}
+			pickers[w] = uniformPicker(r, first, n)
+			continue
+		default:
+			pickers[w] = uniformPicker(r, 0, accounts)
+			continue
+		}
+		pickers[w] = func() (from, to int) {
+			// a transfer to the sender is rejected
+			for from, to = draw(), draw(); from == to; to = draw() {
+			}
+			return from, to
+		}
+	}
+	return pickers, nil
+}
+
+// uniformPicker draws two different accounts among the n from first.
+func uniformPicker(r *mrand.Rand, first, n int) accountPicker {
+	return func() (from, to int) {
+		from = r.Intn(n)
+		// a transfer to the sender is rejected
+		to = (from + 1 + r.Intn(n-1)) % n
+		return first + from, first + to
+	}
+}
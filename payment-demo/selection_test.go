@@ -0,0 +1,153 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseAccountSelection(t *testing.T) {
+	tests := []struct {
+		in   string
+		want AccountSelection // zero Kind when parsing must fail
+	}{
+		{"uniform", AccountSelection{Kind: "uniform", Seed: 7}},
+		{"partition", AccountSelection{Kind: "partition", Seed: 7}},
+		{"zipf:1.2", AccountSelection{Kind: "zipf", Skew: 1.2, Seed: 7}},
+		{"hot:10/90", AccountSelection{Kind: "hot", HotAccounts: 10, HotTraffic: 90, Seed: 7}},
+		{"hot:0.5/100", AccountSelection{Kind: "hot", HotAccounts: 0.5, HotTraffic: 100, Seed: 7}},
+		{"hot:10/0", AccountSelection{Kind: "hot", HotAccounts: 10, HotTraffic: 0, Seed: 7}},
+		{"uniform:1", AccountSelection{}},
+		{"partition:2", AccountSelection{}},
+		{"zipf", AccountSelection{}},
+		{"zipf:1", AccountSelection{}},
+		{"zipf:x", AccountSelection{}},
+		{"hot:10", AccountSelection{}},
+		{"hot:0/90", AccountSelection{}},
+		{"hot:100/90", AccountSelection{}},
+		{"hot:10/101", AccountSelection{}},
+		{"hot:10/-1", AccountSelection{}},
+		{"random", AccountSelection{}},
+		{"", AccountSelection{}},
+	}
+	for _, tt := range tests {
+		got, err := ParseAccountSelection(tt.in, 7)
+		if tt.want.Kind == "" {
+			if err == nil {
+				t.Errorf("ParseAccountSelection(%q) = %+v, want an error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseAccountSelection(%q): %v", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseAccountSelection(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func mustSelection(t *testing.T, s string, seed int64) AccountSelection {
+	t.Helper()
+	sel, err := ParseAccountSelection(s, seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sel
+}
+
+func TestPickers(t *testing.T) {
+	const accounts, workers, draws = 100, 4, 2000
+	tests := []struct {
+		selection string
+		// hot is the share of the accounts drawn among the first 10, -1 to skip
+		hotMin, hotMax float64
+	}{
+		{"uniform", 0.05, 0.15},
+		{"partition", -1, -1},
+		{"zipf:1.2", 0.5, 1},
+		{"hot:10/90", 0.85, 0.95},
+		{"hot:10/0", 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.selection, func(t *testing.T) {
+			pickers, err := mustSelection(t, tt.selection, 42).pickers(accounts, workers)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(pickers) != workers {
+				t.Fatalf("%d pickers, want %d", len(pickers), workers)
+			}
+			hot, total := 0, 0
+			owner := map[int]int{}
+			for w, pick := range pickers {
+				for i := 0; i < draws; i++ {
+					from, to := pick()
+					if from == to {
+						t.Fatalf("worker %d drew a transfer from %d to itself", w, from)
+					}
+					for _, a := range []int{from, to} {
+						if a < 0 || a >= accounts {
+							t.Fatalf("worker %d drew account %d out of [0, %d)", w, a, accounts)
+						}
+						if a < accounts/10 {
+							hot++
+						}
+						total++
+						if o, ok := owner[a]; tt.selection == "partition" && ok && o != w {
+							t.Fatalf("account %d drawn by workers %d and %d", a, o, w)
+						}
+						owner[a] = w
+					}
+				}
+			}
+			if tt.hotMin >= 0 {
+				if share := float64(hot) / float64(total); share < tt.hotMin || share > tt.hotMax {
+					t.Errorf("%.3f of the accounts drawn among the first 10%%, want [%.2f, %.2f]", share, tt.hotMin, tt.hotMax)
+				}
+			}
+		})
+	}
+}
+
+func TestPickersReproducible(t *testing.T) {
+	for _, s := range []string{"uniform", "partition", "zipf:1.5", "hot:20/80"} {
+		draw := func(seed int64) [][2]int {
+			pickers, err := mustSelection(t, s, seed).pickers(50, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var seq [][2]int
+			for i := 0; i < 20; i++ {
+				for _, pick := range pickers {
+					from, to := pick()
+					seq = append(seq, [2]int{from, to})
+				}
+			}
+			return seq
+		}
+		if a, b := draw(1), draw(1); !reflect.DeepEqual(a, b) {
+			t.Errorf("%s: the same seed drew %v and %v", s, a, b)
+		}
+		if a, b := draw(1), draw(2); reflect.DeepEqual(a, b) {
+			t.Errorf("%s: seeds 1 and 2 drew the same transfers", s)
+		}
+	}
+}
+
+func TestPickersErrors(t *testing.T) {
+	tests := []struct {
+		selection         string
+		accounts, workers int
+	}{
+		{"uniform", 1, 1},
+		{"partition", 7, 4},
+		{"hot:10/100", 10, 1},
+		{"hot:90/0", 10, 1},
+	}
+	for _, tt := range tests {
+		if _, err := mustSelection(t, tt.selection, 1).pickers(tt.accounts, tt.workers); err == nil {
+			t.Errorf("%s with %d accounts and %d workers succeeded, want an error", tt.selection, tt.accounts, tt.workers)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/GingerMoon/fabric_demo/payment-common/schema"
+	"github.com/GingerMoon/fabric_demo/payment-demo/paymentclient"
+)
+
+// RunSettle settles the window ending at to, running settle until the
+// settlement is complete.
+func RunSettle(ctx context.Context, c *paymentclient.Client, to time.Time, limit int) (*schema.Settlement, error) {
+	for {
+		settlement, err := c.Settle(ctx, to, limit)
+		if err != nil {
+			return nil, err
+		}
+		if !settlement.More {
+			return settlement, nil
+		}
+		logger.Infof("settlement %s: %d records netted, continuing", settlement.ID, settlement.Entries)
+	}
+}
+
+func logPositions(positions []schema.NetPosition) {
+	if len(positions) == 0 {
+		logger.Infof("no open position")
+	}
+	for _, p := range positions {
+		asset := p.Asset
+		if asset == "" {
+			asset = "(default asset)"
+		}
+		logger.Infof("%s owes %s %d %s", p.Debtor, p.Creditor, p.Amount, asset)
+	}
+}
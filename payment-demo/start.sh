@@ -1,12 +1,8 @@
 #dep init
 go build
 
-export CLIENT_AMOUNT=10 # not used in benchmark test
-export ACCOUNTS=100000 # not used in benchmark test
-export AMOUNT=80
-
 for (( ; ; ))
 do
-    ./payment-demo
+    ./payment-demo bench -clients 10 -accounts 100000 -amount 80 -encoding json # json or proto
     sleep 10
 done